@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dutt23/lms/cache"
+	"github.com/dutt23/lms/middleware"
+	"github.com/dutt23/lms/model"
+	service "github.com/dutt23/lms/services"
+	"github.com/dutt23/lms/token"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// authPayload is the token the caller logged in with. Only routes behind
+// middleware.AuthMiddleware have one.
+func authPayload(ctx *gin.Context) *token.Payload {
+	return ctx.MustGet(middleware.AuthPayloadKey).(*token.Payload)
+}
+
+// isStaff tells whether the caller works at the library, staff act for any
+// member.
+func isStaff(ctx *gin.Context) bool {
+	return model.HasRole(authPayload(ctx).Role, model.RoleLibrarian)
+}
+
+// callerMember finds the member the caller logged in as.
+func callerMember(ctx *gin.Context, memberService service.MemberService) (*model.Member, error) {
+	member, err := memberService.GetMemberByEmail(ctx, authPayload(ctx).Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if member == nil || member.Id == 0 {
+		return nil, errors.New("unable to locate logged in member")
+	}
+	return member, nil
+}
+
+// forbidOtherMember answers with a 403 when a caller who is not staff acts
+// for a member other than themselves, reporting whether it did.
+func forbidOtherMember(ctx *gin.Context, memberService service.MemberService, memberId uint64) bool {
+	if isStaff(ctx) {
+		return false
+	}
+
+	caller, err := callerMember(ctx, memberService)
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return true
+	}
+
+	if caller.Id != memberId {
+		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("members can only act for themselves")))
+		return true
+	}
+	return false
+}
+
+// revokeAccessTokens revokes the access tokens of the sessions that are still
+// valid.
+func revokeAccessTokens(ctx *gin.Context, tokenCache cache.TokenCache, sessions []*model.Session) {
+	for _, session := range sessions {
+		if session.AccessExpiresAt != nil {
+			revokeToken(ctx, tokenCache, session.AccessTokenId, *session.AccessExpiresAt)
+		}
+	}
+}
+
+// revokeToken keeps the token out until it expires. The sessions are revoked
+// regardless, so without the cache the token only lasts until its expiry.
+func revokeToken(ctx *gin.Context, tokenCache cache.TokenCache, tokenId uuid.UUID, expiredAt time.Time) {
+	if err := tokenCache.RevokeToken(ctx, tokenId, time.Until(expiredAt)); err != nil {
+		fmt.Println("unable to revoke token ", err)
+	}
+}
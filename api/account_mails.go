@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	service "github.com/dutt23/lms/services"
+	"github.com/dutt23/lms/token"
+	"github.com/dutt23/lms/workers"
+	"github.com/hibiken/asynq"
+)
+
+const verificationMail = `Hello %s,
+
+Follow the link below to verify the email of your library account. It works
+once, until %s.
+
+%s
+`
+
+const passwordResetMail = `Hello %s,
+
+Follow the link below to choose a new password for your library account. It
+works once, until %s. If you did not ask for it, ignore this mail and your
+password stays as it is.
+
+%s
+`
+
+// accountMails mails members the links to verify their email and reset their
+// password, each carrying a one time purpose token.
+type accountMails struct {
+	config          *config.AppConfig
+	memberService   service.MemberService
+	tokenService    service.OneTimeTokenService
+	purposeMaker    token.PurposeMaker
+	taskDistributor workers.TaskDistributor
+}
+
+func NewAccountMails(config *config.AppConfig, memberService service.MemberService, tokenService service.OneTimeTokenService, purposeMaker token.PurposeMaker, taskDistributor workers.TaskDistributor) *accountMails {
+	return &accountMails{config, memberService, tokenService, purposeMaker, taskDistributor}
+}
+
+func (mails *accountMails) SendVerification(ctx context.Context, member *model.Member) error {
+	return mails.send(ctx, member, token.PurposeVerifyEmail, mails.config.EmailVerificationDuration,
+		"Verify your email", "verify-email", verificationMail)
+}
+
+func (mails *accountMails) SendPasswordReset(ctx context.Context, member *model.Member) error {
+	return mails.send(ctx, member, token.PurposeResetPassword, mails.config.PasswordResetDuration,
+		"Reset your password", "reset-password", passwordResetMail)
+}
+
+// send records a token for the purpose and queues the mail with the link
+// carrying it, the link opening path on the site of the mail config.
+func (mails *accountMails) send(ctx context.Context, member *model.Member, purpose string, duration time.Duration, subject, path, text string) error {
+	purposeToken, payload, err := mails.purposeMaker.CreatePurposeToken(member.Email, purpose, duration)
+	if err != nil {
+		return err
+	}
+
+	record := &model.OneTimeToken{Id: payload.ID, MemberId: member.Id, Purpose: purpose, ExpiresAt: payload.ExpiredAt}
+	if err := mails.tokenService.AddToken(ctx, record); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/%s?token=%s", strings.TrimSuffix(mails.config.MailConfig.LinkBaseURL, "/"), path, url.QueryEscape(purposeToken))
+	msg := &workers.MailPayload{
+		To:      member.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf(text, member.Name, payload.ExpiredAt.Format(time.RFC1123), link),
+	}
+	return mails.taskDistributor.DistributeMail(ctx, msg, asynq.MaxRetry(5), asynq.Queue(workers.MailQueue))
+}
+
+// redeem uses up a mailed token, returning the member it was mailed to. It
+// fails for a token mailed to an email the member no longer has. It must run
+// inside the unit of work applying the change the token is for, so the token
+// stays usable when the change fails.
+func (mails *accountMails) redeem(ctx context.Context, purposeToken, purpose string) (*model.Member, error) {
+	payload, err := mails.purposeMaker.ValidatePurpose(purposeToken, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := mails.tokenService.UseToken(ctx, payload.ID, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := mails.memberService.GetMemberByEmail(ctx, payload.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if member == nil || member.Id != record.MemberId {
+		return nil, errors.New("token was mailed to an email the member no longer has")
+	}
+	return member, nil
+}
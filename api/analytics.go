@@ -13,17 +13,20 @@ import (
 type analyticsApi struct {
 	config           *config.AppConfig
 	bookService      service.BookService
+	workService      service.WorkService
 	memberService    service.MemberService
 	analyticsService service.AnalyticsService
 }
 
 func NewAnalyticsApi(config *config.AppConfig,
 	bookService service.BookService,
+	workService service.WorkService,
 	memberService service.MemberService,
 	analyticsService service.AnalyticsService) *analyticsApi {
 	return &analyticsApi{
 		config,
 		bookService,
+		workService,
 		memberService,
 		analyticsService,
 	}
@@ -34,12 +37,13 @@ type getAnalyticsRequestBody struct {
 
 type getAnalyticsResponseBody struct {
 	BookAnalytics   *cache.BookAnalytics   `json:"book_month_analytics"`
+	WorkAnalytics   *cache.WorkAnalytics   `json:"work_month_analytics"`
 	MemberAnalytics *cache.MemberAnalytics `json:"member_week_analytics"`
 }
 
 // GetAnalytics godoc
-// @Summary endpoint to ten latest books and members
-// @Description get analytics
+// @Summary endpoint to ten latest books, works and members
+// @Description get analytics, those of a work add up the analytics of all its editions
 // @Tags analytics
 // @Produce json
 // @Success 200 {object} getAnalyticsResponseBody
@@ -55,6 +59,15 @@ func (api *analyticsApi) GetAnalytics(ctx *gin.Context) {
 		BookAnalytics: bookResp,
 	}
 
+	workResp, err := api.getWorkAnalytics(ctx)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp.WorkAnalytics = workResp
+
 	memberResp, err := api.getMemberAnalytics(ctx)
 
 	if err != nil {
@@ -87,6 +100,26 @@ func (api *analyticsApi) getBookAnalytics(ctx context.Context) (*cache.BookAnaly
 	return bookResp, nil
 }
 
+func (api *analyticsApi) getWorkAnalytics(ctx context.Context) (*cache.WorkAnalytics, error) {
+	works, err := api.workService.GetWorks(ctx, "", 0, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	workIds := make([]uint64, len(works))
+
+	for idx, work := range works {
+		workIds[idx] = work.Id
+	}
+
+	editions, err := api.workService.GetEditionIds(ctx, workIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.analyticsService.GetWorkListAnalytics(ctx, editions)
+}
+
 func (api *analyticsApi) getMemberAnalytics(ctx context.Context) (*cache.MemberAnalytics, error) {
 	members, err := api.memberService.GetMembers(ctx, 0, 10)
 	if err != nil {
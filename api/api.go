@@ -1 +1,31 @@
 package api
+
+import (
+	"errors"
+	"net/http"
+
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+)
+
+// conflictCodes are the refusals of a change raced by another one.
+var conflictCodes = map[string]bool{
+	service.ErrCodeHoldChanged: true,
+	service.ErrCodeLoanChanged: true,
+}
+
+// circulationErrorResponse answers with the code of a policy refusal, as a
+// conflict when another change got there first, or as an internal error for
+// anything else.
+func circulationErrorResponse(ctx *gin.Context, err error) {
+	var circulationErr *service.CirculationError
+	if errors.As(err, &circulationErr) {
+		status := http.StatusBadRequest
+		if conflictCodes[circulationErr.Code] {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, circulationErr)
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+}
@@ -1,30 +1,66 @@
 package api
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/dutt23/lms/cache"
 	"github.com/dutt23/lms/config"
 	"github.com/dutt23/lms/middleware"
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
 	service "github.com/dutt23/lms/services"
 	"github.com/dutt23/lms/token"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type authApi struct {
 	config *config.AppConfig
+  db connectors.SqliteConnector
   memberService service.MemberService
+  sessionService service.SessionService
+  credentialService service.CredentialService
   tokenMaker token.Maker
+  tokenCache cache.TokenCache
+  accountMails *accountMails
 }
 
-func NewAuthApi(config *config.AppConfig, memberService service.MemberService, tokenMaker token.Maker) *authApi {
-	return &authApi{config, memberService, tokenMaker}
+func NewAuthApi(config *config.AppConfig, db connectors.SqliteConnector, memberService service.MemberService, sessionService service.SessionService, credentialService service.CredentialService, tokenMaker token.Maker, tokenCache cache.TokenCache, accountMails *accountMails) *authApi {
+	return &authApi{config, db, memberService, sessionService, credentialService, tokenMaker, tokenCache, accountMails}
 }
 
 type loginUserRequestBody struct {
   Email string `json:"email" binding:"required,email"`
+  Password string `json:"password" binding:"required"`
+}
+
+type signupRequestBody struct {
+	Email    string `json:"email" binding:"required,email"`
+	Name     string `json:"name" binding:"required,gt=1"`
+	Password string `json:"password" binding:"required,min=8,max=128"`
+}
+
+type mailedTokenRequestBody struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type forgotPasswordRequestBody struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type resetPasswordRequestBody struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=128"`
+}
+
+type changePasswordRequestBody struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,max=128"`
 }
 
 type loginUserResponseBody struct {
@@ -34,9 +70,21 @@ type loginUserResponseBody struct {
 	RefreshTokenExpiresAt time.Time `json:"refresh_token_expired_at"`
 }
 
+type renewTokensRequestBody struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type getSessionRequestBody struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+type getSessionsResponseBody struct {
+	Sessions []*model.Session `json:"sessions"`
+}
+
 // LoginUser godoc
 // @Summary endpoint to login a user
-// @Description login a user
+// @Description login a user with their email and password. Too many failed logins from a client IP hold its logins off for a while
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -51,44 +99,563 @@ func (api *authApi) LoginUser(ctx *gin.Context) {
 		return
 	}
 
-	_, err := api.memberService.GetMemberByEmail(ctx, req.Email)
+	member, err := api.memberService.GetMemberByEmail(ctx, req.Email)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// an unknown email is checked like a wrong password, member id 0 having
+	// no credential
+	var memberId uint64
+	if member != nil {
+		memberId = member.Id
+	}
+
+	if err := api.credentialService.Authenticate(ctx, memberId, req.Password); err != nil {
+		credentialErrorResponse(ctx, err)
+		return
+	}
+
+	role, ok := api.loginRole(member)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(service.ErrInvalidCredentials))
+		return
+	}
+
+	resp, session, err := api.issueTokens(ctx, member, req.Email, role)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// a login starts a new family of sessions
+	session.FamilyId = session.Id
+	if err := api.sessionService.AddSession(ctx, session); err != nil {
+		fmt.Println("unable to add session ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to start session")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// RenewTokens godoc
+// @Summary endpoint to renew the tokens of a user
+// @Description exchange a refresh token for a new access and refresh token pair. Each refresh token is good for one exchange, replaying a used one revokes every session of its login
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param tokens body renewTokensRequestBody true "Refresh token"
+// @Success 200 {object} loginUserResponseBody
+// @Router /v1/tokens/renew [post]
+func (api *authApi) RenewTokens(ctx *gin.Context) {
+	var req renewTokensRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	payload, err := api.tokenMaker.Validate(req.RefreshToken, token.KindRefresh)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	session, err := api.sessionService.GetSession(ctx, payload.ID)
+	if err != nil || session.Username != payload.Username {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("unknown refresh token")))
+		return
+	}
+
+	if session.IsRevoked {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("session has been revoked")))
+		return
+	}
+
+	if session.RotatedAt != nil {
+		api.revokeReplayed(ctx, session)
+		return
+	}
+
+	member, err := api.memberService.GetMemberByEmail(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// the role is looked up again, it may have changed since the login
+	role, ok := api.loginRole(member)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("unable to locate member with email %s", payload.Username)))
+		return
+	}
 
+	resp, next, err := api.issueTokens(ctx, member, payload.Username, role)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := api.sessionService.RotateSession(ctx, session, next); err != nil {
+		if errors.Is(err, service.ErrSessionRotated) {
+			api.revokeReplayed(ctx, session)
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		fmt.Println("unable to rotate session ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to renew session")))
 		return
 	}
 
-	token, payload, err := api.tokenMaker.CreateToken(req.Email, api.config.AccessTokenDuration)
+	ctx.JSON(http.StatusOK, resp)
+}
 
+// revokeReplayed answers a refresh token used a second time, most likely
+// stolen, by revoking every session of its login.
+func (api *authApi) revokeReplayed(ctx *gin.Context, session *model.Session) {
+	sessions, err := api.sessionService.RevokeFamily(ctx, session.FamilyId)
+	if err != nil {
+		fmt.Println("unable to revoke sessions ", err)
+	}
+	revokeAccessTokens(ctx, api.tokenCache, sessions)
+	ctx.JSON(http.StatusUnauthorized, errorResponse(service.ErrSessionRotated))
+}
+
+// Signup godoc
+// @Summary endpoint to signup a member
+// @Description register as a member with a password to login with, mailing them a link to verify their email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param member body signupRequestBody true "Signup data"
+// @Success 201 {object} model.Member
+// @Router /v1/signup [post]
+func (api *authApi) Signup(ctx *gin.Context) {
+	var req signupRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	existing, err := api.memberService.GetMemberByEmail(ctx, req.Email)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	refreshToken, refreshTokenPayload, err := api.tokenMaker.CreateToken(req.Email, api.config.RefreshTokenDuration)
+	if existing != nil && existing.Id != 0 {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("please give a unique email")))
+		return
+	}
+
+	member := &model.Member{
+		Email:      req.Email,
+		Name:       req.Name,
+		MemberType: model.DefaultMemberType,
+		Role:       model.RoleMember,
+		JoinDate:   time.Now(),
+	}
 
+	err = api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := api.memberService.AddMember(ctx, member); err != nil {
+			return err
+		}
+		return api.credentialService.SetPassword(ctx, member.Id, req.Password)
+	})
+	if err != nil {
+		fmt.Println("unable to signup member ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to add member to library")))
+		return
+	}
+
+	// the member asks for another mail when this one does not go out
+	if err := api.accountMails.SendVerification(ctx, member); err != nil {
+		fmt.Println("unable to mail email verification ", err)
+	}
+
+	ctx.JSON(http.StatusCreated, member)
+}
+
+// ChangePassword godoc
+// @Summary endpoint to change the password of the logged in member
+// @Description change the password of the authenticated member, logging out their other devices
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param password body changePasswordRequestBody true "Passwords"
+// @Success 200
+// @Router /v1/me/password [put]
+func (api *authApi) ChangePassword(ctx *gin.Context) {
+	var req changePasswordRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	member, err := callerMember(ctx, api.memberService)
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	err = api.credentialService.Authenticate(ctx, member.Id, req.CurrentPassword)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("current password is incorrect")))
+		return
+	}
+
+	if err != nil {
+		credentialErrorResponse(ctx, err)
+		return
+	}
+
+	if err := api.credentialService.SetPassword(ctx, member.Id, req.NewPassword); err != nil {
+		fmt.Println("unable to set password ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to change password")))
+		return
+	}
+
+	payload := authPayload(ctx)
+	keepFamilyId := uuid.Nil
+	if session, err := api.sessionService.GetSessionByAccessToken(ctx, payload.ID); err == nil {
+		keepFamilyId = session.FamilyId
+	}
+
+	sessions, err := api.sessionService.RevokeUserSessions(ctx, payload.Username, keepFamilyId)
+	if err != nil {
+		fmt.Println("unable to revoke sessions ", err)
+	}
+	revokeAccessTokens(ctx, api.tokenCache, sessions)
+	ctx.Status(http.StatusOK)
+}
+
+// VerifyEmail godoc
+// @Summary endpoint to verify the email of a member
+// @Description verify an email with the token of the link mailed to it, each token working once
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body mailedTokenRequestBody true "Mailed token"
+// @Success 200 {object} model.Member
+// @Router /v1/email/verify [post]
+func (api *authApi) VerifyEmail(ctx *gin.Context) {
+	var req mailedTokenRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var member *model.Member
+	var redeemErr error
+	err := api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		member, redeemErr = api.accountMails.redeem(ctx, req.Token, token.PurposeVerifyEmail)
+		if redeemErr != nil {
+			return redeemErr
+		}
+		return api.memberService.VerifyEmail(ctx, member.Id)
+	})
+	if redeemErr != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(redeemErr))
+		return
+	}
+
+	if err != nil {
+		fmt.Println("unable to verify email ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to verify email")))
+		return
+	}
+
+	member, err = api.memberService.GetMemberByEmail(ctx, member.Email)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
+	ctx.JSON(http.StatusOK, member)
+}
+
+// ResendVerification godoc
+// @Summary endpoint to mail the logged in member another verification link
+// @Description mail the authenticated member a new link to verify their email, the links mailed before no longer working
+// @Tags auth
+// @Produce json
+// @Success 202
+// @Router /v1/me/email/verify [post]
+func (api *authApi) ResendVerification(ctx *gin.Context) {
+	member, err := callerMember(ctx, api.memberService)
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	if member.EmailVerifiedAt != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("email is already verified")))
+		return
+	}
+
+	if err := api.accountMails.SendVerification(ctx, member); err != nil {
+		fmt.Println("unable to mail email verification ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to mail email verification")))
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+// ForgotPassword godoc
+// @Summary endpoint to ask for a password reset
+// @Description mail a member a link to choose a new password. The answer is the same whether the email belongs to a member or not
+// @Tags auth
+// @Accept json
+// @Param email body forgotPasswordRequestBody true "Email"
+// @Success 202
+// @Router /v1/password/forgot [post]
+func (api *authApi) ForgotPassword(ctx *gin.Context) {
+	var req forgotPasswordRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	member, err := api.memberService.GetMemberByEmail(ctx, req.Email)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	resp := loginUserResponseBody{
+	if member != nil && member.Id != 0 {
+		if err := api.accountMails.SendPasswordReset(ctx, member); err != nil {
+			fmt.Println("unable to mail password reset ", err)
+		}
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+// ResetPassword godoc
+// @Summary endpoint to reset a password
+// @Description choose a new password with the token of the link mailed to the member, logging them out of every device. It also verifies their email
+// @Tags auth
+// @Accept json
+// @Param password body resetPasswordRequestBody true "Mailed token and new password"
+// @Success 200
+// @Router /v1/password/reset [post]
+func (api *authApi) ResetPassword(ctx *gin.Context) {
+	var req resetPasswordRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// following the link proved the member reads mail at the address
+	var member *model.Member
+	var redeemErr error
+	err := api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		member, redeemErr = api.accountMails.redeem(ctx, req.Token, token.PurposeResetPassword)
+		if redeemErr != nil {
+			return redeemErr
+		}
+		if err := api.credentialService.SetPassword(ctx, member.Id, req.Password); err != nil {
+			return err
+		}
+		return api.memberService.VerifyEmail(ctx, member.Id)
+	})
+	if redeemErr != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(redeemErr))
+		return
+	}
+
+	if err != nil {
+		fmt.Println("unable to reset password ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to reset password")))
+		return
+	}
+
+	sessions, err := api.sessionService.RevokeUserSessions(ctx, member.Email, uuid.Nil)
+	if err != nil {
+		fmt.Println("unable to revoke sessions ", err)
+	}
+	revokeAccessTokens(ctx, api.tokenCache, sessions)
+	ctx.Status(http.StatusOK)
+}
+
+// credentialErrorResponse answers a failed password check, with a 401 for a
+// wrong password.
+func credentialErrorResponse(ctx *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	fmt.Println("unable to check password ", err)
+	ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to check password")))
+}
+
+// Logout godoc
+// @Summary endpoint to logout a user
+// @Description revoke the access token of the request along with the session it was issued with
+// @Tags auth
+// @Produce json
+// @Success 200
+// @Router /v1/logout [post]
+func (api *authApi) Logout(ctx *gin.Context) {
+	payload := authPayload(ctx)
+
+	session, err := api.sessionService.GetSessionByAccessToken(ctx, payload.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Println("unable to find session ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to logout")))
+		return
+	}
+
+	if session != nil {
+		sessions, err := api.sessionService.RevokeFamily(ctx, session.FamilyId)
+		if err != nil {
+			fmt.Println("unable to revoke sessions ", err)
+			ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to logout")))
+			return
+		}
+		revokeAccessTokens(ctx, api.tokenCache, sessions)
+	}
+
+	revokeToken(ctx, api.tokenCache, payload.ID, payload.ExpiredAt)
+	ctx.Status(http.StatusOK)
+}
+
+// LogoutAll godoc
+// @Summary endpoint to logout a user from all devices
+// @Description revoke every session of the authenticated user along with the access tokens issued with them
+// @Tags auth
+// @Produce json
+// @Success 200
+// @Router /v1/logout/all [post]
+func (api *authApi) LogoutAll(ctx *gin.Context) {
+	payload := authPayload(ctx)
+
+	sessions, err := api.sessionService.RevokeUserSessions(ctx, payload.Username, uuid.Nil)
+	if err != nil {
+		fmt.Println("unable to revoke sessions ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("unable to logout")))
+		return
+	}
+
+	revokeAccessTokens(ctx, api.tokenCache, sessions)
+	revokeToken(ctx, api.tokenCache, payload.ID, payload.ExpiredAt)
+	ctx.Status(http.StatusOK)
+}
+
+// GetMySessions godoc
+// @Summary endpoint to list the sessions of the logged in user
+// @Description list the sessions of the authenticated user that can still be renewed, one for each device, newest first
+// @Tags auth
+// @Produce json
+// @Success 200 {object} getSessionsResponseBody
+// @Router /v1/me/sessions [get]
+func (api *authApi) GetMySessions(ctx *gin.Context) {
+	payload := authPayload(ctx)
+
+	sessions, err := api.sessionService.GetActiveSessions(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any sessions")))
+		return
+	}
+
+	for _, session := range sessions {
+		session.Current = session.AccessTokenId == payload.ID
+	}
+
+	ctx.JSON(http.StatusOK, getSessionsResponseBody{Sessions: sessions})
+}
+
+// DeleteMySession godoc
+// @Summary endpoint to revoke a session of the logged in user
+// @Description logout the device of a session of the authenticated user, revoking the session with its access tokens
+// @Tags auth
+// @param id path string true "session id"
+// @Success 200
+// @Router /v1/me/sessions/{id} [delete]
+func (api *authApi) DeleteMySession(ctx *gin.Context) {
+	var req getSessionRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	payload := authPayload(ctx)
+
+	// other users' sessions are as good as missing
+	session, err := api.sessionService.GetSession(ctx, uuid.MustParse(req.ID))
+	if err != nil || session.Username != payload.Username {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate session with Id %s", req.ID)))
+		return
+	}
+
+	sessions, err := api.sessionService.RevokeFamily(ctx, session.FamilyId)
+	if err != nil {
+		fmt.Println("unable to revoke sessions ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to revoke session %s", req.ID)))
+		return
+	}
+
+	revokeAccessTokens(ctx, api.tokenCache, sessions)
+	ctx.Status(http.StatusOK)
+}
+
+// loginRole is the role the member logs in with, the admin email logging in
+// as an admin once verified, so signing up with it is not enough.
+func (api *authApi) loginRole(member *model.Member) (string, bool) {
+	if member == nil || member.Id == 0 {
+		return "", false
+	}
+
+	if api.config.AdminEmail != "" && member.Email == api.config.AdminEmail && member.EmailVerifiedAt != nil {
+		return model.RoleAdmin, true
+	}
+	return member.Role, true
+}
+
+// issueTokens creates an access and refresh token pair along with the session
+// of the refresh token, for the caller to store.
+func (api *authApi) issueTokens(ctx *gin.Context, member *model.Member, email, role string) (*loginUserResponseBody, *model.Session, error) {
+	accessToken, payload, err := api.tokenMaker.CreateToken(email, role, token.KindAccess, api.config.AccessTokenDuration)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, refreshTokenPayload, err := api.tokenMaker.CreateToken(email, role, token.KindRefresh, api.config.RefreshTokenDuration)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session := &model.Session{
+		Id:              refreshTokenPayload.ID,
+		Username:        email,
+		UserAgent:       ctx.Request.UserAgent(),
+		ClientIp:        ctx.ClientIP(),
+		ExpiresAt:       refreshTokenPayload.ExpiredAt,
+		AccessTokenId:   payload.ID,
+		AccessExpiresAt: &payload.ExpiredAt,
+	}
+	if member != nil && member.Id != 0 {
+		session.MemberId = &member.Id
+	}
+
+	resp := &loginUserResponseBody{
 		RefreshToken:          refreshToken,
 		RefreshTokenExpiresAt: refreshTokenPayload.ExpiredAt,
-		AccessToken:           token,
+		AccessToken:           accessToken,
 		AccessTokenExpiresAt:  payload.ExpiredAt,
 	}
-
-	ctx.JSON(http.StatusOK, resp)
+	return resp, session, nil
 }
 
 // CheckAuth godoc
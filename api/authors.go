@@ -0,0 +1,220 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+)
+
+type authorsApi struct {
+	config             *config.AppConfig
+	contributorService service.ContributorService
+}
+
+func NewAuthorsApi(config *config.AppConfig, contributorService service.ContributorService) *authorsApi {
+	return &authorsApi{
+		config,
+		contributorService,
+	}
+}
+
+type addAuthorRequestBody struct {
+	Name string `json:"name" binding:"required,gt=1"`
+}
+
+type getAuthorRequestBody struct {
+	ID uint64 `uri:"id" binding:"required,min=1"`
+}
+
+type getAuthorsRequestBody struct {
+	Name     string `form:"name"`
+	LastId   uint64 `form:"last_id"`
+	PageSize int    `form:"page_size"`
+}
+
+type getAuthorsResponseBody struct {
+	Authors []*model.Contributor `json:"authors"`
+}
+
+type getAuthorResponseBody struct {
+	*model.Contributor
+	Bibliography []*model.ContributorCredit `json:"bibliography"`
+}
+
+// AddAuthor godoc
+// @Summary endpoint to create an author
+// @Description add a contributor that books can credit as author, editor, translator, illustrator and more
+// @Tags author
+// @Accept json
+// @Produce json
+// @Param author body addAuthorRequestBody true "Author data"
+// @Success 201 {object} model.Contributor
+// @Router /v1/authors [post]
+func (api *authorsApi) AddAuthor(ctx *gin.Context) {
+	var req addAuthorRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	contributor := &model.Contributor{Name: req.Name}
+	if err := api.contributorService.AddContributor(ctx, contributor); err != nil {
+		fmt.Println("unable to add contributor ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to add author to library")))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, contributor)
+}
+
+// GetAuthors godoc
+// @Summary endpoint to list authors
+// @Description get the contributors in id order, those whose name contains name when it is given
+// @Tags author
+// @Produce json
+// @Param name query string false "part of the name"
+// @Param last_id query integer false "id of the last author of the previous page"
+// @Param page_size query integer false "authors per page, 10 by default and at most 100"
+// @Success 200 {object} getAuthorsResponseBody
+// @Router /v1/authors [get]
+func (api *authorsApi) GetAuthors(ctx *gin.Context) {
+	var req getAuthorsRequestBody
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	pageSize := 10
+
+	if req.PageSize > 0 && req.PageSize <= 100 {
+		pageSize = req.PageSize
+	}
+
+	contributors, err := api.contributorService.GetContributors(ctx, req.Name, req.LastId, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any authors")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getAuthorsResponseBody{Authors: contributors})
+}
+
+// GetAuthor godoc
+// @Summary endpoint to get an author
+// @Description get a contributor with their bibliography, the books they are credited on and under which role
+// @Tags author
+// @Produce json
+// @param id path integer true "author id"
+// @Success 200 {object} getAuthorResponseBody
+// @Router /v1/authors/{id} [get]
+func (api *authorsApi) GetAuthor(ctx *gin.Context) {
+	var req getAuthorRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	contributor, err := api.contributorService.GetContributor(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate author with Id %d", req.ID)))
+		return
+	}
+
+	bibliography, err := api.contributorService.GetBibliography(ctx, req.ID)
+	if err != nil {
+		fmt.Println("unable to find the bibliography of contributor ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find the books of the author")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getAuthorResponseBody{Contributor: contributor, Bibliography: bibliography})
+}
+
+// UpdateAuthor godoc
+// @Summary endpoint to update an author
+// @Description rename a contributor, the author statement of the books crediting them follows
+// @Tags author
+// @Accept json
+// @Produce json
+// @param id path integer true "author id"
+// @Param author body addAuthorRequestBody true "Author data"
+// @Success 200 {object} model.Contributor
+// @Router /v1/authors/{id} [put]
+func (api *authorsApi) UpdateAuthor(ctx *gin.Context) {
+	var uri getAuthorRequestBody
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req addAuthorRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	contributor, err := api.contributorService.GetContributor(ctx, uri.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate author with Id %d", uri.ID)))
+		return
+	}
+
+	contributor.Name = req.Name
+	if err := api.contributorService.UpdateContributor(ctx, contributor); err != nil {
+		fmt.Println("unable to update contributor ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to update author %d", uri.ID)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, contributor)
+}
+
+// DeleteAuthor godoc
+// @Summary endpoint to delete an author
+// @Description delete a contributor no book credits anymore
+// @Tags author
+// @param id path integer true "author id"
+// @Success 200
+// @Router /v1/authors/{id} [delete]
+func (api *authorsApi) DeleteAuthor(ctx *gin.Context) {
+	var req getAuthorRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, err := api.contributorService.GetContributor(ctx, req.ID); err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate author with Id %d", req.ID)))
+		return
+	}
+
+	bibliography, err := api.contributorService.GetBibliography(ctx, req.ID)
+	if err != nil {
+		fmt.Println("unable to find the bibliography of contributor ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to delete author %d", req.ID)))
+		return
+	}
+
+	if len(bibliography) > 0 {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("author has %d book credits, change the contributors of those books first", len(bibliography))))
+		return
+	}
+
+	if err := api.contributorService.DeleteContributor(ctx, req.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to delete author %d", req.ID)))
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
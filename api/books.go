@@ -1,46 +1,97 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	cache "github.com/dutt23/lms/cache"
 	"github.com/dutt23/lms/config"
 	"github.com/dutt23/lms/model"
 	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/dutt23/lms/pkg/dublincore"
+	"github.com/dutt23/lms/pkg/filter"
+	"github.com/dutt23/lms/pkg/isbn"
+	"github.com/dutt23/lms/pkg/marc"
 	service "github.com/dutt23/lms/services"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type booksApi struct {
-	config  *config.AppConfig
-	db      connectors.SqliteConnector
-	cache   cache.BookCache
-	service service.BookService
+	config             *config.AppConfig
+	db                 connectors.SqliteConnector
+	cache              cache.BookCache
+	service            service.BookService
+	itemService        service.ItemService
+	holdService        service.HoldService
+	contributorService service.ContributorService
+	subjectService     service.SubjectService
+	workService        service.WorkService
+	metadataProvider   service.MetadataProvider
 }
 
-func NewBooksApi(config *config.AppConfig, db connectors.SqliteConnector, cache cache.BookCache, service service.BookService) *booksApi {
+func NewBooksApi(config *config.AppConfig, db connectors.SqliteConnector, cache cache.BookCache, service service.BookService, itemService service.ItemService, holdService service.HoldService, contributorService service.ContributorService, subjectService service.SubjectService, workService service.WorkService, metadataProvider service.MetadataProvider) *booksApi {
 	return &booksApi{
 		config,
 		db,
 		cache,
 		service,
+		itemService,
+		holdService,
+		contributorService,
+		subjectService,
+		workService,
+		metadataProvider,
 	}
 }
 
+// bookRequestBody credits the book to its contributors or, without them,
+// to the names of the author statement separated by ";". With contributors
+// the author statement is made up from their names. Subject ids and tags
+// left out keep those the book has. A new book naming no work joins the work
+// of its title and author, an updated one stays in its work.
+type bookRequestBody struct {
+	Title         string    `json:"title" binding:"required,gt=1"`
+	Author        string    `json:"author" binding:"required_without=Contributors,omitempty,gt=1"`
+	PublishedDate time.Time `json:"published_date" binding:"required" time_format:"2006-01-02"`
+	Isbn          string    `json:"isbn" binding:"required"`
+	NumberOfPages uint64    `json:"number_of_pages" binding:"required,numeric,gt=1"`
+	CoverURL      string    `json:"cover_url" binding:"gt=1"`
+	Language      string    `json:"language" binding:"required,alpha,gt=1"`
+	Subjects      string    `json:"subjects"`
+	WorkId        uint64    `json:"work_id"`
+
+	Contributors []*bookContributorRequestBody `json:"contributors" binding:"omitempty,dive"`
+	SubjectIds   []uint64                      `json:"subject_ids" binding:"omitempty,dive,min=1"`
+	Tags         []string                      `json:"tags" binding:"omitempty,dive,max=64"`
+}
+
+// addBookRequestBody catalogues a book with its first copies. Later on the
+// available copies follow from the status of the book's items.
 type addBookRequestBody struct {
-	Title           string    `json:"title" binding:"required,gt=1"`
-	Author          string    `json:"author" binding:"required,gt=1"`
-	PublishedDate   time.Time `json:"published_date" binding:"required" time_format:"2006-01-02"`
-	Isbn            string    `json:"isbn" binding:"required,alphanum,gt=1"`
-	NumberOfPages   uint64    `json:"number_of_pages" binding:"required,numeric,gt=1"`
-	CoverURL        string    `json:"cover_url" binding:"gt=1"`
-	Language        string    `json:"language" binding:"required,alpha,gt=1"`
-	AvailableCopies int64     `json:"available_copies" binding:"required,numeric,gt=0"`
+	bookRequestBody
+	AvailableCopies int64 `json:"available_copies" binding:"required,numeric,gt=0"`
+}
+
+// bookContributorRequestBody credits an existing contributor by id or one by
+// name, added when no contributor has that name yet.
+type bookContributorRequestBody struct {
+	ContributorId uint64 `json:"contributor_id"`
+	Name          string `json:"name" binding:"required_without=ContributorId"`
+	Role          string `json:"role" binding:"omitempty,oneof=aut edt trl ill aui nrt ctb"`
+}
+
+type lookupBookRequestBody struct {
+	Isbn string `json:"isbn" binding:"required"`
 }
 
 type updateBookRequestBody struct {
@@ -55,25 +106,58 @@ type deleteBookRequestBody struct {
 	getBookRequestBody
 }
 
-type Criteria struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
-	Logic string `json:"logic"`
+type getBooksRequestBody struct {
+	LastId   int32                 `json:"last_id"`
+	Filter   *filter.Expression    `json:"filter"`
+	Facets   *model.FacetSelection `json:"facets"`
+	PageSize int32                 `json:"page_size"`
 }
 
-type getBooksRequestBody struct {
-	LastId    int32       `json:"last_id"`
-	Criterias []*Criteria `json:"criterias"`
-	PageSize  int32       `json:"page_size"`
+var bookFilterFields = filter.Fields{
+	"title":            {Column: "title"},
+	"author":           {Column: "author"},
+	"contributor":      {Column: "name", Table: "contributors", Within: creditedContributor},
+	"contributor_id":   {Column: "contributor_id", Table: "book_contributors", Type: filter.Number, Within: creditedContributor},
+	"subject":          {Column: "name", Table: "subjects", Within: service.BooksUnderSubject},
+	"subject_id":       {Column: "id", Table: "subjects", Type: filter.Number, Within: service.BooksUnderSubject},
+	"tag":              {Column: "name", Table: "tags", Normalize: normalizeTag, Within: taggedWith},
+	"isbn":             {Column: "isbn", Normalize: isbn.Normalize},
+	"language":         {Column: "language"},
+	"published_date":   {Column: "published_date", Type: filter.Time},
+	"number_of_pages":  {Column: "number_of_pages", Type: filter.Number},
+	"available_copies": {Column: "available_copies", Type: filter.Number},
+	"subjects":         {Column: "subjects"},
+	"work_id":          {Column: "work_id", Type: filter.Number},
+}
+
+// creditedContributor matches the books with a credit meeting the condition.
+func creditedContributor(condition clause.Expression) clause.Expression {
+	return clause.Expr{
+		SQL:  "books.id IN (SELECT book_contributors.book_id FROM book_contributors JOIN contributors ON contributors.id = book_contributors.contributor_id WHERE ?)",
+		Vars: []interface{}{condition},
+	}
+}
+
+// taggedWith matches the books with a tag meeting the condition.
+func taggedWith(condition clause.Expression) clause.Expression {
+	return clause.Expr{
+		SQL:  "books.id IN (SELECT book_tags.book_id FROM book_tags JOIN tags ON tags.id = book_tags.tag_id WHERE ?)",
+		Vars: []interface{}{condition},
+	}
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
 }
 
 type getBooksResponseBody struct {
-	Books []*model.Book `json:"books"`
+	Books  []*model.Book     `json:"books"`
+	Facets *model.BookFacets `json:"facets,omitempty"`
 }
 
 // AddBook godoc
 // @Summary endpoint to create book
-// @Description add a book
+// @Description add a book. Fields left out are filled in from the bibliographic metadata found for the ISBN, so an ISBN and the number of copies can be enough
 // @Tags book
 // @Accept json
 // @Produce json
@@ -83,7 +167,21 @@ type getBooksResponseBody struct {
 func (api *booksApi) AddBook(ctx *gin.Context) {
 	var req addBookRequestBody
 
-	if err := ctx.ShouldBindJSON(&req); err != nil {
+	// the binding rules apply once the metadata filled in what it could
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	canonical, err := isbn.Parse(req.Isbn)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	req.Isbn = canonical
+
+	api.fillFromMetadata(ctx, &req)
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
@@ -102,20 +200,72 @@ func (api *booksApi) AddBook(ctx *gin.Context) {
 		CoverImage:      req.CoverURL,
 		Language:        req.Language,
 		AvailableCopies: req.AvailableCopies,
+		Subjects:        req.Subjects,
+		WorkId:          req.WorkId,
+		Contributors:    bookContributors(req.Contributors),
 	}
 
 	//TODO: Add retry logic here
-	err := api.db.DB(ctx).Create(book).Error
+	err = api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := api.service.AddBook(ctx, book); err != nil {
+			return err
+		}
+
+		if _, err := api.itemService.AddItems(ctx, book, req.AvailableCopies); err != nil {
+			return err
+		}
+		return classifyBook(ctx, api.subjectService, book, &req.bookRequestBody)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
 	if err != nil {
-		fmt.Errorf("error occurred %w", err)
+		fmt.Println("unable to add book ", err)
 		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to add book to library")))
 		return
 	}
 
-	go api.postProcessAddingBook(book)
 	ctx.JSON(http.StatusCreated, book)
 }
 
+// LookupBook godoc
+// @Summary endpoint to look up bibliographic metadata
+// @Description find the title, author, pages, cover and more of an edition by its ISBN, from the local Open Library dump or the metadata API. Nothing is added to the catalogue
+// @Tags book
+// @Accept json
+// @Produce json
+// @Param isbn body lookupBookRequestBody true "ISBN-10 or ISBN-13"
+// @Success 200 {object} model.BookMetadata
+// @Router /v1/books/lookup [post]
+func (api *booksApi) LookupBook(ctx *gin.Context) {
+	var req lookupBookRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	canonical, err := isbn.Parse(req.Isbn)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	metadata, err := api.metadataProvider.LookupIsbn(ctx, canonical)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to look up the isbn")))
+		return
+	}
+
+	if metadata == nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("no metadata found for isbn %s", canonical)))
+		return
+	}
+	ctx.JSON(http.StatusOK, metadata)
+}
+
 // AddBook godoc
 // @Summary endpoint to filter and get books
 // @Description get a list of books
@@ -123,7 +273,7 @@ func (api *booksApi) AddBook(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param bookListParams body getBooksRequestBody true "Book data"
-// @Success 200 {object} []model.Book
+// @Success 200 {object} getBooksResponseBody
 // @Router /v1/book [get]
 func (api *booksApi) GetBooks(ctx *gin.Context) {
 	var req getBooksRequestBody
@@ -145,10 +295,20 @@ func (api *booksApi) GetBooks(ctx *gin.Context) {
 		pageSize = 10
 	}
 
+	where, err := bookFilterFields.Compile(req.Filter)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
 	qry := api.db.DB(ctx).Model(model.Book{}).Where("id > ?", lastId).Limit(pageSize)
 
-	for _, ct := range req.Criterias {
-		qry.Where(fmt.Sprintf("%s %s ?", ct.Key, ct.Logic), ct.Value)
+	if where != nil {
+		qry = qry.Where(where)
+	}
+
+	if selected := service.BookFacetFilter(req.Facets); selected != nil {
+		qry = qry.Where(selected)
 	}
 
 	tx := qry.Order(clause.OrderByColumn{
@@ -162,17 +322,41 @@ func (api *booksApi) GetBooks(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, getBooksResponseBody{Books: books})
+	if err := api.contributorService.LoadContributors(ctx, books); err != nil {
+		fmt.Println("not able to find the contributors of books", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any books")))
+		return
+	}
+
+	if err := api.subjectService.LoadClassification(ctx, books); err != nil {
+		fmt.Println("not able to find the classification of books", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any books")))
+		return
+	}
+
+	res := getBooksResponseBody{Books: books}
+
+	// facets describe the whole filtered set, later pages don't repeat them
+	if lastId == 0 {
+		res.Facets, err = api.service.GetBookFacets(ctx, where, req.Facets)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to count book facets")))
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, res)
 
 }
 
 // GetBook godoc
 // @Summary endpoint to get book
-// @Description get a book
+// @Description get a book, as JSON or, going by the Accept header, as a MARCXML or Dublin Core record
 // @Tags book
-// @Produce json
+// @Produce json,application/marcxml+xml,application/dc+xml
 // @param id path integer false "book id"
 // @Success 200 {object} model.Book
+// @Failure 406 {object} nil "no acceptable representation"
 // @Router /v1/book/:id [get]
 func (api *booksApi) GetBook(ctx *gin.Context) {
 	var req getBookRequestBody
@@ -189,18 +373,55 @@ func (api *booksApi) GetBook(ctx *gin.Context) {
 		return
 	}
 
+	if err := api.contributorService.LoadContributors(ctx, []*model.Book{book}); err != nil {
+		fmt.Println("unable to find the contributors of book ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to locate book with Id %d", req.ID)))
+		return
+	}
+
+	if err := api.subjectService.LoadClassification(ctx, []*model.Book{book}); err != nil {
+		fmt.Println("unable to find the classification of book ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to locate book with Id %d", req.ID)))
+		return
+	}
+
 	c := context.Background()
 	go api.storeBookMeta(c, book)
-	ctx.JSON(http.StatusOK, book)
+
+	ctx.Header("Vary", "Accept")
+	switch ctx.NegotiateFormat(gin.MIMEJSON, marc.MediaType, dublincore.MediaType) {
+	case gin.MIMEJSON:
+		ctx.JSON(http.StatusOK, book)
+	case marc.MediaType:
+		api.renderRecord(ctx, marc.MediaType, func(w io.Writer) error {
+			return marc.WriteXML(w, marc.FromBook(book))
+		})
+	case dublincore.MediaType:
+		api.renderRecord(ctx, dublincore.MediaType, func(w io.Writer) error {
+			return dublincore.WriteXML(w, dublincore.FromBook(book))
+		})
+	default:
+		ctx.JSON(http.StatusNotAcceptable, errorResponse(fmt.Errorf("a book is available as %s, %s or %s", gin.MIMEJSON, marc.MediaType, dublincore.MediaType)))
+	}
+}
+
+func (api *booksApi) renderRecord(ctx *gin.Context, mediaType string, write func(w io.Writer) error) {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		fmt.Println("unable to render book record ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to render book")))
+		return
+	}
+	ctx.Data(http.StatusOK, mediaType+"; charset=utf-8", buf.Bytes())
 }
 
 // UpdateBook godoc
 // @Summary endpoint to update book
-// @Description update book data
+// @Description update book data, the available copies following from the status of its items
 // @Tags book
 // @Produce json
 // @Accept json
-// @Param book body addBookRequestBody true "Book data"
+// @Param book body bookRequestBody true "Book data"
 // @param id path integer false "book id"
 // @Success 200 {object} model.Book
 // @Router /v1/book/:id [put]
@@ -212,13 +433,20 @@ func (api *booksApi) UpdateBook(ctx *gin.Context) {
 		return
 	}
 
-	var body addBookRequestBody
+	var body bookRequestBody
 
 	if err := ctx.ShouldBindJSON(&body); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
+	canonical, err := isbn.Parse(body.Isbn)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	body.Isbn = canonical
+
 	if !api.cache.DoesBookExist(ctx, uint64(req.ID)) {
 		ctx.JSON(http.StatusNotFound, errorResponse(errors.New(fmt.Sprintf("unable to locate book with Id %d", req.ID))))
 		return
@@ -228,18 +456,61 @@ func (api *booksApi) UpdateBook(ctx *gin.Context) {
 		Audited: model.Audited{
 			Id: uint64(req.ID),
 		},
-		Title:           body.Title,
-		Author:          body.Author,
-		PublishedDate:   body.PublishedDate,
-		Isbn:            body.Isbn,
-		NumberOfPages:   body.NumberOfPages,
-		CoverImage:      body.CoverURL,
-		Language:        body.Language,
-		AvailableCopies: body.AvailableCopies,
+		Title:         body.Title,
+		Author:        body.Author,
+		PublishedDate: body.PublishedDate,
+		Isbn:          body.Isbn,
+		NumberOfPages: body.NumberOfPages,
+		CoverImage:    body.CoverURL,
+		Language:      body.Language,
+		Subjects:      body.Subjects,
+		WorkId:        body.WorkId,
+		Contributors:  bookContributors(body.Contributors),
+	}
+
+	if len(book.Contributors) == 0 {
+		book.Contributors = model.AuthorCredits(body.Author)
+	}
+
+	// available copies are derived from the status of the book's items
+	omit := []string{"available_copies"}
+	if book.WorkId == 0 {
+		omit = append(omit, "work_id")
+	} else if _, err := api.workService.GetWork(ctx, book.WorkId); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("unable to locate work with Id %d", book.WorkId)))
+		return
+	}
+
+	err = api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := api.db.DB(ctx).Omit(omit...).Save(book).Error; err != nil {
+			return err
+		}
+		if err := api.contributorService.SetBookContributors(ctx, book); err != nil {
+			return err
+		}
+		return classifyBook(ctx, api.subjectService, book, &body)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
 	}
 
-	if err := api.db.DB(ctx).Save(book).Error; err != nil {
+	credits := book.Contributors
+	book, err = api.service.RefreshAvailableCopies(ctx, book.Id)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	book.Contributors = credits
+
+	if err := api.subjectService.LoadClassification(ctx, []*model.Book{book}); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
 	}
 	go api.postProcessAddingBook(book)
 	ctx.JSON(http.StatusOK, book)
@@ -247,7 +518,7 @@ func (api *booksApi) UpdateBook(ctx *gin.Context) {
 
 // DeleteBook godoc
 // @Summary endpoint to delete book
-// @Description delete a book
+// @Description delete a book with its copies and the holds waiting on it, refused while a copy is on loan or on the hold shelf
 // @Tags book
 // @param id path integer false "book id"
 // @Success 200
@@ -265,7 +536,28 @@ func (api *booksApi) DeleteBook(ctx *gin.Context) {
 		return
 	}
 
-	if err := api.db.DB(ctx).Delete(&model.Book{}, req.ID).Error; err != nil {
+	// foreign keys are not enforced, so nothing cascades from the book
+	err := api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := api.itemService.RemoveBookItems(ctx, req.ID); err != nil {
+			return err
+		}
+		if err := api.holdService.RemoveBookHolds(ctx, req.ID); err != nil {
+			return err
+		}
+		if err := api.db.DB(ctx).Delete(&model.Book{}, req.ID).Error; err != nil {
+			return err
+		}
+		if err := api.contributorService.RemoveBookContributors(ctx, req.ID); err != nil {
+			return err
+		}
+		return api.subjectService.RemoveBookClassification(ctx, req.ID)
+	})
+	var circulationErr *service.CirculationError
+	if errors.As(err, &circulationErr) {
+		ctx.JSON(http.StatusBadRequest, circulationErr)
+		return
+	}
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New(fmt.Sprintf("unable to delete book %d", req.ID))))
 		return
 	}
@@ -274,6 +566,69 @@ func (api *booksApi) DeleteBook(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 }
 
+func bookContributors(reqs []*bookContributorRequestBody) []*model.BookContributor {
+	credits := make([]*model.BookContributor, len(reqs))
+	for idx, req := range reqs {
+		credits[idx] = &model.BookContributor{
+			ContributorId: req.ContributorId,
+			Name:          req.Name,
+			Role:          req.Role,
+		}
+	}
+	return credits
+}
+
+// classifyBook sets the subjects and tags of a saved book, those left out of
+// the request stay as they are.
+func classifyBook(ctx context.Context, subjectService service.SubjectService, book *model.Book, req *bookRequestBody) error {
+	if req.SubjectIds != nil {
+		if err := subjectService.SetBookSubjects(ctx, book, req.SubjectIds); err != nil {
+			return err
+		}
+	}
+
+	if req.Tags != nil {
+		return subjectService.SetBookTags(ctx, book, req.Tags)
+	}
+	return nil
+}
+
+// fillFromMetadata completes a book entered with little more than its ISBN.
+// Fields given are never overridden and a failed lookup leaves the book as
+// it is, for the binding rules to report what is missing.
+func (api *booksApi) fillFromMetadata(ctx *gin.Context, req *addBookRequestBody) {
+	if req.Title != "" && req.Author != "" && !req.PublishedDate.IsZero() && req.NumberOfPages > 0 && req.CoverURL != "" && req.Language != "" {
+		return
+	}
+
+	metadata, err := api.metadataProvider.LookupIsbn(ctx, req.Isbn)
+	if err != nil || metadata == nil {
+		return
+	}
+
+	if req.Title == "" {
+		req.Title = metadata.Title
+	}
+	if req.Author == "" {
+		req.Author = metadata.Author
+	}
+	if req.PublishedDate.IsZero() && metadata.PublishedDate != nil {
+		req.PublishedDate = *metadata.PublishedDate
+	}
+	if req.NumberOfPages == 0 {
+		req.NumberOfPages = metadata.NumberOfPages
+	}
+	if req.CoverURL == "" {
+		req.CoverURL = metadata.CoverURL
+	}
+	if req.Language == "" {
+		req.Language = metadata.Language
+	}
+	if req.Subjects == "" {
+		req.Subjects = metadata.Subjects
+	}
+}
+
 func (api *booksApi) postProcessAddingBook(book *model.Book) {
 	ctx := context.Background()
 	api.storeBookMeta(ctx, book)
@@ -0,0 +1,396 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/bulk"
+	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/dutt23/lms/pkg/dublincore"
+	"github.com/dutt23/lms/pkg/isbn"
+	"github.com/dutt23/lms/pkg/marc"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// maxReportedRowErrors caps the row errors listed in an import response,
+// rows failing past it are only counted.
+const maxReportedRowErrors = 100
+
+type bulkApi struct {
+	config         *config.AppConfig
+	db             connectors.SqliteConnector
+	bookService    service.BookService
+	itemService    service.ItemService
+	subjectService service.SubjectService
+	memberService  service.MemberService
+	loanService    service.LoanService
+	accountMails   *accountMails
+}
+
+func NewBulkApi(config *config.AppConfig, db connectors.SqliteConnector, bookService service.BookService, itemService service.ItemService, subjectService service.SubjectService, memberService service.MemberService, loanService service.LoanService, accountMails *accountMails) *bulkApi {
+	return &bulkApi{
+		config,
+		db,
+		bookService,
+		itemService,
+		subjectService,
+		memberService,
+		loanService,
+		accountMails,
+	}
+}
+
+type importRowsRequestBody struct {
+	Format string `form:"format"`
+	DryRun bool   `form:"dry_run"`
+}
+
+type exportRowsRequestBody struct {
+	Format string `form:"format"`
+}
+
+type importRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type importRowsResponseBody struct {
+	DryRun    bool              `json:"dry_run"`
+	Total     int64             `json:"total"`
+	Succeeded int64             `json:"succeeded"`
+	Failed    int64             `json:"failed"`
+	Errors    []*importRowError `json:"errors"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// ImportBooks godoc
+// @Summary endpoint to import books in bulk
+// @Description add books from a CSV (header line with the field names) or NDJSON body. Every row is validated like a single book and added with its copies on its own, so a failing row doesn't stop the others. With dry_run rows are only validated
+// @Tags book
+// @Accept text/csv,application/x-ndjson
+// @Produce json
+// @Param format query string false "csv or ndjson, taken from the Content-Type by default"
+// @Param dry_run query boolean false "only validate the rows"
+// @Success 200 {object} importRowsResponseBody
+// @Router /v1/books/import [post]
+func (api *bulkApi) ImportBooks(ctx *gin.Context) {
+	seen := map[string]bool{}
+
+	importRows(ctx, func(req *addBookRequestBody, dryRun bool) error {
+		canonical, err := isbn.Parse(req.Isbn)
+		if err != nil {
+			return err
+		}
+		req.Isbn = canonical
+
+		if seen[req.Isbn] {
+			return fmt.Errorf("isbn %s appears earlier in the file", req.Isbn)
+		}
+
+		_, err = api.bookService.GetBookByIsbn(ctx, req.Isbn)
+		if err == nil {
+			return errors.New("duplicate isbn provided")
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			fmt.Println("unable to look up isbn ", err)
+			return errors.New("Unable to add book to library")
+		}
+
+		// later rows see the ones added before them, only a dry run has to
+		// remember what it would have added
+		if dryRun {
+			seen[req.Isbn] = true
+			return nil
+		}
+
+		book := &model.Book{
+			Title:           req.Title,
+			Author:          req.Author,
+			PublishedDate:   req.PublishedDate,
+			Isbn:            req.Isbn,
+			NumberOfPages:   req.NumberOfPages,
+			CoverImage:      req.CoverURL,
+			Language:        req.Language,
+			AvailableCopies: req.AvailableCopies,
+			Subjects:        req.Subjects,
+			WorkId:          req.WorkId,
+			Contributors:    bookContributors(req.Contributors),
+		}
+
+		err = api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := api.bookService.AddBook(ctx, book); err != nil {
+				return err
+			}
+
+			if _, err := api.itemService.AddItems(ctx, book, req.AvailableCopies); err != nil {
+				return err
+			}
+			return classifyBook(ctx, api.subjectService, book, &req.bookRequestBody)
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err != nil {
+			fmt.Println("unable to import book ", err)
+			return errors.New("Unable to add book to library")
+		}
+		return nil
+	})
+}
+
+// ImportMembers godoc
+// @Summary endpoint to import members in bulk
+// @Description add members from a CSV (header line with the field names) or NDJSON body. Every row is validated like a single member and added on its own, mailing them a link to verify their email, so a failing row doesn't stop the others. With dry_run rows are only validated
+// @Tags member
+// @Accept text/csv,application/x-ndjson
+// @Produce json
+// @Param format query string false "csv or ndjson, taken from the Content-Type by default"
+// @Param dry_run query boolean false "only validate the rows"
+// @Success 200 {object} importRowsResponseBody
+// @Router /v1/members/import [post]
+func (api *bulkApi) ImportMembers(ctx *gin.Context) {
+	seen := map[string]bool{}
+
+	importRows(ctx, func(req *addMemberRequestBody, dryRun bool) error {
+		if seen[req.Email] {
+			return fmt.Errorf("email %s appears earlier in the file", req.Email)
+		}
+
+		existing, err := api.memberService.GetMemberByEmail(ctx, req.Email)
+		if err != nil {
+			fmt.Println("unable to look up email ", err)
+			return errors.New("Unable to add member to library")
+		}
+
+		if existing.Id != 0 {
+			return errors.New("please give a unique email")
+		}
+
+		if dryRun {
+			seen[req.Email] = true
+			return nil
+		}
+
+		member := &model.Member{
+			Email:      req.Email,
+			Name:       req.Name,
+			MemberType: memberType(req.MemberType),
+			Role:       model.RoleMember,
+			JoinDate:   time.Now(),
+		}
+
+		if err := api.memberService.AddMember(ctx, member); err != nil {
+			fmt.Println("unable to import member ", err)
+			return errors.New("Unable to add member to library")
+		}
+
+		if err := api.accountMails.SendVerification(ctx, member); err != nil {
+			fmt.Println("unable to mail email verification ", err)
+		}
+		return nil
+	})
+}
+
+// ExportBooks godoc
+// @Summary endpoint to export the catalogue
+// @Description stream every book as CSV or NDJSON rows, or as a MARCXML collection or Dublin Core records
+// @Tags book
+// @Produce text/csv,application/x-ndjson,application/marcxml+xml,application/dc+xml
+// @Param format query string false "csv (default), ndjson, marcxml or dc"
+// @Success 200
+// @Router /v1/books/export [get]
+func (api *bulkApi) ExportBooks(ctx *gin.Context) {
+	switch ctx.Query("format") {
+	case "marcxml":
+		writer := marc.NewXMLWriter(ctx.Writer)
+		api.exportBookRecords(ctx, marc.MediaType, writer, func(book *model.Book) error {
+			return writer.Write(marc.FromBook(book))
+		})
+	case "dc":
+		writer := dublincore.NewXMLWriter(ctx.Writer)
+		api.exportBookRecords(ctx, dublincore.MediaType, writer, func(book *model.Book) error {
+			return writer.Write(dublincore.FromBook(book))
+		})
+	default:
+		exportRows(ctx, "books", api.bookService.ExportBooks)
+	}
+}
+
+// ExportMembers godoc
+// @Summary endpoint to export members
+// @Description stream every member as CSV or NDJSON
+// @Tags member
+// @Produce text/csv,application/x-ndjson
+// @Param format query string false "csv (default) or ndjson"
+// @Success 200
+// @Router /v1/members/export [get]
+func (api *bulkApi) ExportMembers(ctx *gin.Context) {
+	exportRows(ctx, "members", api.memberService.ExportMembers)
+}
+
+// ExportLoans godoc
+// @Summary endpoint to export loans
+// @Description stream every loan, open or returned, as CSV or NDJSON
+// @Tags loan
+// @Produce text/csv,application/x-ndjson
+// @Param format query string false "csv (default) or ndjson"
+// @Success 200
+// @Router /v1/loans/export [get]
+func (api *bulkApi) ExportLoans(ctx *gin.Context) {
+	exportRows(ctx, "loans", api.loanService.ExportLoans)
+}
+
+type recordWriter interface {
+	Flush() error
+	Close() error
+}
+
+// exportBookRecords streams the catalogue as a single XML document, write
+// adding one book to it.
+func (api *bulkApi) exportBookRecords(ctx *gin.Context, mediaType string, writer recordWriter, write func(*model.Book) error) {
+	ctx.Header("Content-Type", mediaType+"; charset=utf-8")
+	ctx.Header("Content-Disposition", `attachment; filename="books.xml"`)
+	ctx.Status(http.StatusOK)
+
+	err := api.bookService.ExportBooks(ctx, func(books []*model.Book) error {
+		for _, book := range books {
+			if err := write(book); err != nil {
+				return err
+			}
+		}
+
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		ctx.Writer.Flush()
+		return nil
+	})
+
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if err != nil {
+		fmt.Println("export of book records stopped ", err)
+		ctx.Abort()
+	}
+}
+
+// importRows decodes the request body row by row, validates every row with
+// the binding rules of the single record endpoint and hands the valid ones
+// to importRow. Nothing but the current row is held in memory.
+func importRows[T any](ctx *gin.Context, importRow func(req *T, dryRun bool) error) {
+	var req importRowsRequestBody
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if req.Format == "" {
+		req.Format = ctx.ContentType()
+	}
+
+	format, err := bulk.ParseFormat(req.Format)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	res := &importRowsResponseBody{DryRun: req.DryRun, Errors: []*importRowError{}}
+	decoder := bulk.NewDecoder(format, ctx.Request.Body)
+
+	for {
+		var row T
+		line, err := decoder.Decode(&row)
+		if err == io.EOF {
+			break
+		}
+
+		var rowErr *bulk.RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			res.Error = err.Error()
+			ctx.JSON(http.StatusBadRequest, res)
+			return
+		}
+
+		res.Total++
+		if err == nil {
+			err = binding.Validator.ValidateStruct(&row)
+		}
+		if err == nil {
+			err = importRow(&row, req.DryRun)
+		}
+
+		if err == nil {
+			res.Succeeded++
+			continue
+		}
+
+		res.Failed++
+		if len(res.Errors) < maxReportedRowErrors {
+			if rowErr != nil {
+				err = rowErr.Err
+			}
+			res.Errors = append(res.Errors, &importRowError{Line: line, Error: err.Error()})
+		}
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+// exportRows streams the batches export hands out, flushing after each one
+// so no more than a batch is ever buffered.
+func exportRows[T any](ctx *gin.Context, name string, export func(ctx context.Context, fn func([]*T) error) error) {
+	var req exportRowsRequestBody
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	format := bulk.CSV
+	if req.Format != "" {
+		var err error
+		if format, err = bulk.ParseFormat(req.Format); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	ctx.Header("Content-Type", format.ContentType())
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	ctx.Status(http.StatusOK)
+
+	encoder := bulk.NewEncoder(format, ctx.Writer)
+	err := export(ctx, func(rows []*T) error {
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+
+		if err := encoder.Flush(); err != nil {
+			return err
+		}
+		ctx.Writer.Flush()
+		return nil
+	})
+
+	// the status is out already, a broken export can only be cut short
+	if err != nil {
+		fmt.Printf("export of %s stopped %v\n", name, err)
+		ctx.Abort()
+	}
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+)
+
+type holdsApi struct {
+	config        *config.AppConfig
+	db            connectors.SqliteConnector
+	bookService   service.BookService
+	workService   service.WorkService
+	memberService service.MemberService
+	holdService   service.HoldService
+}
+
+func NewHoldsApi(config *config.AppConfig,
+	db connectors.SqliteConnector,
+	bookService service.BookService,
+	workService service.WorkService,
+	memberService service.MemberService,
+	holdService service.HoldService,
+) *holdsApi {
+	return &holdsApi{
+		config:        config,
+		db:            db,
+		bookService:   bookService,
+		workService:   workService,
+		memberService: memberService,
+		holdService:   holdService,
+	}
+}
+
+// addHoldRequestBody holds a book, or any edition of a work.
+type addHoldRequestBody struct {
+	MemberId uint64 `json:"member_id" binding:"required,numeric"`
+	BookId   uint64 `json:"book_id" binding:"required_without=WorkId,omitempty,numeric"`
+	WorkId   uint64 `json:"work_id" binding:"required_without=BookId,excluded_with=BookId,omitempty,numeric"`
+}
+
+type getHoldRequestBody struct {
+	ID uint64 `uri:"id" binding:"required,min=1"`
+}
+
+type getHoldsRequestBody struct {
+	MemberId uint64 `json:"member_id"`
+	BookId   uint64 `json:"book_id"`
+	WorkId   uint64 `json:"work_id"`
+	LastId   uint64 `json:"last_id"`
+	PageSize int32  `json:"page_size"`
+}
+
+type holdResponseBody struct {
+	*model.Hold
+	QueuePosition int64 `json:"queue_position"`
+}
+
+type getHoldsResponseBody struct {
+	Holds []*model.Hold `json:"holds"`
+}
+
+// AddHold godoc
+// @Summary endpoint to place a hold on a book or a work
+// @Description queue a member for the next copy of a book with no available copies, or with work_id for the next copy of any edition of a work none of whose editions has one
+// @Tags hold
+// @Accept json
+// @Produce json
+// @Param hold body addHoldRequestBody true "Hold data"
+// @Success 201 {object} holdResponseBody
+// @Router /v1/holds [post]
+func (api *holdsApi) AddHold(ctx *gin.Context) {
+	var req addHoldRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, err := api.memberService.GetMember(ctx, req.MemberId); err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate member with Id %d", req.MemberId)))
+		return
+	}
+
+	// members place holds for themselves, librarians for anyone
+	if forbidOtherMember(ctx, api.memberService, req.MemberId) {
+		return
+	}
+
+	workId := req.WorkId
+	if req.BookId > 0 {
+		book, err := api.bookService.GetBook(ctx, req.BookId)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate book with Id %d", req.BookId)))
+			return
+		}
+
+		if book.AvailableCopies > 0 {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("copies of this book are available, check one out instead")))
+			return
+		}
+		workId = book.WorkId
+	} else {
+		if _, err := api.workService.GetWork(ctx, req.WorkId); err != nil {
+			ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate work with Id %d", req.WorkId)))
+			return
+		}
+
+		editions, err := api.workService.GetEditions(ctx, req.WorkId)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		if len(editions) == 0 {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("work has no editions to hold")))
+			return
+		}
+
+		for _, edition := range editions {
+			if edition.AvailableCopies > 0 {
+				ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("copies of edition %d of this work are available, check one out instead", edition.Id)))
+				return
+			}
+		}
+	}
+
+	exists, err := api.holdService.HasActiveHold(ctx, req.MemberId, workId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if exists {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("member already has a hold on this title")))
+		return
+	}
+
+	var hold *model.Hold
+	if req.BookId > 0 {
+		hold, err = api.holdService.PlaceHold(ctx, req.MemberId, req.BookId)
+	} else {
+		hold, err = api.holdService.PlaceWorkHold(ctx, req.MemberId, req.WorkId)
+	}
+	if err != nil {
+		fmt.Println("unable to place hold , ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	api.holdResponse(ctx, http.StatusCreated, hold)
+}
+
+// GetHold godoc
+// @Summary endpoint to get a hold
+// @Description get a hold with its position in the queue, members only get their own
+// @Tags hold
+// @Produce json
+// @param id path integer false "hold id"
+// @Success 200 {object} holdResponseBody
+// @Router /v1/holds/:id [get]
+func (api *holdsApi) GetHold(ctx *gin.Context) {
+	var req getHoldRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hold, err := api.holdService.GetHold(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate hold with Id %d", req.ID)))
+		return
+	}
+
+	if forbidOtherMember(ctx, api.memberService, hold.MemberId) {
+		return
+	}
+
+	api.holdResponse(ctx, http.StatusOK, hold)
+}
+
+// GetHolds godoc
+// @Summary endpoint to filter and get holds
+// @Description get a list of holds by member, book or work. Filtering by work lists the holds on the work and on each of its editions. Members only see their own
+// @Tags hold
+// @Accept json
+// @Produce json
+// @Param holdListParams body getHoldsRequestBody true "Hold data"
+// @Success 200 {object} getHoldsResponseBody
+// @Router /v1/holds [get]
+func (api *holdsApi) GetHolds(ctx *gin.Context) {
+	var req getHoldsRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	pageSize := 10
+
+	if req.PageSize > 0 && req.PageSize < 100 {
+		pageSize = int(req.PageSize)
+	}
+
+	if !isStaff(ctx) {
+		caller, err := callerMember(ctx, api.memberService)
+		if err != nil {
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		req.MemberId = caller.Id
+	}
+
+	holds, err := api.holdService.GetHolds(ctx, req.MemberId, req.BookId, req.WorkId, req.LastId, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any holds")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getHoldsResponseBody{Holds: holds})
+}
+
+// DeleteHold godoc
+// @Summary endpoint to cancel a hold
+// @Description cancel a hold, passing a trapped copy on to the next member in line. Members only cancel their own
+// @Tags hold
+// @param id path integer false "hold id"
+// @Success 200
+// @Router /v1/holds/:id [delete]
+func (api *holdsApi) DeleteHold(ctx *gin.Context) {
+	var req getHoldRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hold, err := api.holdService.GetHold(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate hold with Id %d", req.ID)))
+		return
+	}
+
+	if forbidOtherMember(ctx, api.memberService, hold.MemberId) {
+		return
+	}
+
+	if hold.Status != model.HoldStatusWaiting && hold.Status != model.HoldStatusReady {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("hold is already %s", hold.Status)))
+		return
+	}
+
+	// a checkout may fulfil the hold meanwhile, which the cancel then refuses
+	err = api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		item, err := api.holdService.CancelHold(ctx, hold)
+		if err != nil || item == nil {
+			return err
+		}
+
+		_, err = api.bookService.RefreshAvailableCopies(ctx, item.BookId)
+		return err
+	})
+	if err != nil {
+		circulationErrorResponse(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (api *holdsApi) holdResponse(ctx *gin.Context, status int, hold *model.Hold) {
+	position, err := api.holdService.QueuePosition(ctx, hold)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(status, holdResponseBody{Hold: hold, QueuePosition: position})
+}
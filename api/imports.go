@@ -0,0 +1,188 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	service "github.com/dutt23/lms/services"
+	"github.com/dutt23/lms/workers"
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+)
+
+type importsApi struct {
+	config          *config.AppConfig
+	importService   service.ImportService
+	taskDistributor workers.TaskDistributor
+}
+
+func NewImportsApi(config *config.AppConfig, importService service.ImportService, taskDistributor workers.TaskDistributor) *importsApi {
+	return &importsApi{
+		config,
+		importService,
+		taskDistributor,
+	}
+}
+
+type addMarcImportRequestBody struct {
+	Copies *int64 `form:"copies" binding:"omitempty,gte=0,lte=100"`
+}
+
+type getImportRequestBody struct {
+	ID uint64 `uri:"id" binding:"required,min=1"`
+}
+
+type getImportRecordsRequestBody struct {
+	Status   string `json:"status" binding:"omitempty,oneof=succeeded failed"`
+	LastId   uint64 `json:"last_id"`
+	PageSize int32  `json:"page_size"`
+}
+
+type getImportRecordsResponseBody struct {
+	Records []*model.ImportRecord `json:"records"`
+}
+
+// AddMarcImport godoc
+// @Summary endpoint to import MARC records
+// @Description upload a MARC21 (ISO 2709) or MARCXML file. Its records are catalogued in the background with the given number of copies each, follow the import for the outcome per record
+// @Tags import
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "MARC21 or MARCXML file"
+// @Param copies formData integer false "copies to add per book, 1 by default"
+// @Success 202 {object} model.Import
+// @Router /v1/imports/marc [post]
+func (api *importsApi) AddMarcImport(ctx *gin.Context) {
+	var req addMarcImportRequestBody
+
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("a MARC file has to be uploaded as file")))
+		return
+	}
+
+	copies := int64(1)
+	if req.Copies != nil {
+		copies = *req.Copies
+	}
+
+	if err := os.MkdirAll(api.config.ImportDir, 0o750); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to store uploaded file")))
+		return
+	}
+
+	path := filepath.Join(api.config.ImportDir, fmt.Sprintf("marc-%d", time.Now().UnixNano()))
+	if err := ctx.SaveUploadedFile(file, path); err != nil {
+		fmt.Println("unable to store uploaded file ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to store uploaded file")))
+		return
+	}
+
+	imp := &model.Import{
+		Format:    model.ImportFormatMarc,
+		FileName:  filepath.Base(file.Filename),
+		Path:      path,
+		Copies:    copies,
+		Status:    model.ImportStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	if err := api.importService.CreateImport(ctx, imp); err != nil {
+		os.Remove(path)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	opts := []asynq.Option{
+		asynq.MaxRetry(3),
+		asynq.Timeout(time.Hour),
+		asynq.Queue(workers.ImportQueue),
+	}
+	if err := api.taskDistributor.DistributeMarcImport(ctx, &workers.MarcImportPayload{ImportId: imp.Id}, opts...); err != nil {
+		fmt.Println("unable to queue marc import ", err)
+		imp.Status = model.ImportStatusFailed
+		imp.Error = "unable to queue the import"
+		api.importService.UpdateImport(ctx, imp)
+		os.Remove(path)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to queue the import")))
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, imp)
+}
+
+// GetImport godoc
+// @Summary endpoint to follow an import
+// @Description get the status and record counts of an import
+// @Tags import
+// @Produce json
+// @param id path integer true "import id"
+// @Success 200 {object} model.Import
+// @Router /v1/imports/{id} [get]
+func (api *importsApi) GetImport(ctx *gin.Context) {
+	var req getImportRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	imp, err := api.importService.GetImport(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate import with Id %d", req.ID)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, imp)
+}
+
+// GetImportRecords godoc
+// @Summary endpoint to list the outcome of imported records
+// @Description get the per record results of an import, optionally only the failed or succeeded ones
+// @Tags import
+// @Accept json
+// @Produce json
+// @param id path integer true "import id"
+// @Param recordListParams body getImportRecordsRequestBody true "Record list params"
+// @Success 200 {object} getImportRecordsResponseBody
+// @Router /v1/imports/{id}/records [get]
+func (api *importsApi) GetImportRecords(ctx *gin.Context) {
+	var uri getImportRequestBody
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req getImportRecordsRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	pageSize := 10
+
+	if req.PageSize > 0 && req.PageSize < 100 {
+		pageSize = int(req.PageSize)
+	}
+
+	records, err := api.importService.GetImportRecords(ctx, uri.ID, req.Status, req.LastId, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any import records")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getImportRecordsResponseBody{Records: records})
+}
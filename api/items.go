@@ -0,0 +1,320 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type itemsApi struct {
+	config      *config.AppConfig
+	db          connectors.SqliteConnector
+	bookService service.BookService
+	itemService service.ItemService
+}
+
+func NewItemsApi(config *config.AppConfig, db connectors.SqliteConnector, bookService service.BookService, itemService service.ItemService) *itemsApi {
+	return &itemsApi{
+		config,
+		db,
+		bookService,
+		itemService,
+	}
+}
+
+type addItemRequestBody struct {
+	Barcode         string     `json:"barcode" binding:"required,gt=1"`
+	ItemType        string     `json:"item_type" binding:"omitempty,gt=1"`
+	Status          string     `json:"status" binding:"omitempty,oneof=available damaged lost withdrawn"`
+	ShelfLocation   string     `json:"shelf_location"`
+	AcquisitionDate *time.Time `json:"acquisition_date" time_format:"2006-01-02"`
+	Condition       string     `json:"condition" binding:"required,gt=1"`
+}
+
+type getItemsRequestBody struct {
+	BookID uint64 `uri:"id" binding:"required,min=1"`
+}
+
+type getItemRequestBody struct {
+	BookID uint64 `uri:"id" binding:"required,min=1"`
+	ItemID uint64 `uri:"item_id" binding:"required,min=1"`
+}
+
+type getItemsListRequestBody struct {
+	LastId   uint64 `json:"last_id"`
+	PageSize int32  `json:"page_size"`
+}
+
+type getItemsResponseBody struct {
+	Items []*model.Item `json:"items"`
+}
+
+// AddItem godoc
+// @Summary endpoint to add a copy of a book
+// @Description add an item (physical copy) to a book
+// @Tags item
+// @Accept json
+// @Produce json
+// @param id path integer true "book id"
+// @Param item body addItemRequestBody true "Item data"
+// @Success 201 {object} model.Item
+// @Router /v1/books/{id}/items [post]
+func (api *itemsApi) AddItem(ctx *gin.Context) {
+	var uri getItemsRequestBody
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req addItemRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, err := api.bookService.GetBook(ctx, uri.BookID); err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate book with Id %d", uri.BookID)))
+		return
+	}
+
+	item := &model.Item{
+		Barcode:       req.Barcode,
+		BookId:        uri.BookID,
+		ItemType:      itemType(req.ItemType),
+		Status:        req.Status,
+		ShelfLocation: req.ShelfLocation,
+		Condition:     req.Condition,
+	}
+	if req.AcquisitionDate != nil {
+		item.AcquisitionDate = *req.AcquisitionDate
+	}
+
+	if err := api.itemService.AddItem(ctx, item); err != nil {
+		fmt.Println("unable to add item ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to add item to library")))
+		return
+	}
+
+	if _, err := api.bookService.RefreshAvailableCopies(ctx, uri.BookID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, item)
+}
+
+// GetItems godoc
+// @Summary endpoint to list copies of a book
+// @Description get the items of a book
+// @Tags item
+// @Accept json
+// @Produce json
+// @param id path integer true "book id"
+// @Param itemListParams body getItemsListRequestBody true "Item list params"
+// @Success 200 {object} getItemsResponseBody
+// @Router /v1/books/{id}/items [get]
+func (api *itemsApi) GetItems(ctx *gin.Context) {
+	var uri getItemsRequestBody
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req getItemsListRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	pageSize := 10
+
+	if req.PageSize > 0 && req.PageSize < 100 {
+		pageSize = int(req.PageSize)
+	}
+
+	items, err := api.itemService.GetItems(ctx, uri.BookID, req.LastId, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any items")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getItemsResponseBody{Items: items})
+}
+
+// GetItem godoc
+// @Summary endpoint to get a copy of a book
+// @Description get an item
+// @Tags item
+// @Produce json
+// @param id path integer true "book id"
+// @param item_id path integer true "item id"
+// @Success 200 {object} model.Item
+// @Router /v1/books/{id}/items/{item_id} [get]
+func (api *itemsApi) GetItem(ctx *gin.Context) {
+	var req getItemRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	item, err := api.itemService.GetItem(ctx, req.BookID, req.ItemID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate item with Id %d", req.ItemID)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, item)
+}
+
+// UpdateItem godoc
+// @Summary endpoint to update a copy of a book
+// @Description update item data such as shelf location, condition or status. The status of a copy on loan or on the hold shelf only changes through circulation
+// @Tags item
+// @Accept json
+// @Produce json
+// @param id path integer true "book id"
+// @param item_id path integer true "item id"
+// @Param item body addItemRequestBody true "Item data"
+// @Success 200 {object} model.Item
+// @Router /v1/books/{id}/items/{item_id} [put]
+func (api *itemsApi) UpdateItem(ctx *gin.Context) {
+	var uri getItemRequestBody
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req addItemRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// the status is checked in the unit of work so a checkout or a trap for a
+	// hold cannot slip in between
+	var item *model.Item
+	err := api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		item, err = api.itemService.GetItem(ctx, uri.BookID, uri.ItemID)
+		if err != nil {
+			return err
+		}
+
+		if req.Status != "" && req.Status != item.Status {
+			if err := circulatingItemError(item, "changing its status"); err != nil {
+				return err
+			}
+		}
+
+		item.Barcode = req.Barcode
+		item.ItemType = itemType(req.ItemType)
+		item.ShelfLocation = req.ShelfLocation
+		item.Condition = req.Condition
+		if req.Status != "" {
+			item.Status = req.Status
+		}
+		if req.AcquisitionDate != nil {
+			item.AcquisitionDate = *req.AcquisitionDate
+		}
+
+		if err := api.itemService.UpdateItem(ctx, item); err != nil {
+			return err
+		}
+
+		_, err = api.bookService.RefreshAvailableCopies(ctx, uri.BookID)
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate item with Id %d", uri.ItemID)))
+		return
+	}
+
+	if err != nil {
+		circulationErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, item)
+}
+
+// DeleteItem godoc
+// @Summary endpoint to delete a copy of a book
+// @Description delete an item, refused while it is on loan or on the hold shelf
+// @Tags item
+// @param id path integer true "book id"
+// @param item_id path integer true "item id"
+// @Success 200
+// @Router /v1/books/{id}/items/{item_id} [delete]
+func (api *itemsApi) DeleteItem(ctx *gin.Context) {
+	var req getItemRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	err := api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		item, err := api.itemService.GetItem(ctx, req.BookID, req.ItemID)
+		if err != nil {
+			return err
+		}
+
+		if err := circulatingItemError(item, "deleting it"); err != nil {
+			return err
+		}
+
+		if err := api.itemService.DeleteItem(ctx, req.BookID, req.ItemID); err != nil {
+			return err
+		}
+
+		_, err = api.bookService.RefreshAvailableCopies(ctx, req.BookID)
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate item with Id %d", req.ItemID)))
+		return
+	}
+
+	if err != nil {
+		fmt.Println("unable to delete item ", err)
+		circulationErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func itemType(requested string) string {
+	if requested == "" {
+		return model.DefaultItemType
+	}
+	return requested
+}
+
+// circulatingItemError refuses the change to a copy that is on loan or
+// trapped for a hold, which only circulation moves on.
+func circulatingItemError(item *model.Item, change string) error {
+	switch item.Status {
+	case model.ItemStatusOnLoan:
+		return &service.CirculationError{Code: service.ErrCodeItemUnavailable, Message: fmt.Sprintf("item is on loan, return it before %s", change)}
+	case model.ItemStatusOnHold:
+		return &service.CirculationError{Code: service.ErrCodeItemUnavailable, Message: fmt.Sprintf("item is on the hold shelf, cancel its hold before %s", change)}
+	}
+	return nil
+}
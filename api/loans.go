@@ -8,44 +8,57 @@ import (
 	"time"
 
 	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/middleware"
 	"github.com/dutt23/lms/model"
 	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/dutt23/lms/pkg/filter"
 	service "github.com/dutt23/lms/services"
+	"github.com/dutt23/lms/token"
 	"github.com/dutt23/lms/workers"
 	"github.com/gin-gonic/gin"
 	"github.com/hibiken/asynq"
+	"gorm.io/gorm/clause"
 )
 
 type loansApi struct {
 	config          *config.AppConfig
 	db              connectors.SqliteConnector
 	bookService     service.BookService
+	itemService     service.ItemService
 	memberService   service.MemberService
 	loanService     service.LoanService
+	holdService     service.HoldService
+	policyService   service.PolicyService
 	taskDistributor workers.TaskDistributor
 }
 
 func NewLoansApi(config *config.AppConfig,
 	db connectors.SqliteConnector,
 	bookService service.BookService,
+	itemService service.ItemService,
 	memberService service.MemberService,
 	loanService service.LoanService,
+	holdService service.HoldService,
+	policyService service.PolicyService,
 	taskDistributor workers.TaskDistributor,
 ) *loansApi {
 	return &loansApi{
 		config:          config,
 		db:              db,
 		bookService:     bookService,
+		itemService:     itemService,
 		memberService:   memberService,
 		loanService:     loanService,
+		holdService:     holdService,
+		policyService:   policyService,
 		taskDistributor: taskDistributor,
 	}
 }
 
 type addLoanRequestBody struct {
-	MemberId   uint64     `json:"member_id" binding:"required,numeric"`
-	BookId     uint64     `json:"book_id" binding:"required,numeric"`
-	ReturnDate *time.Time `json:"return_date"`
+	MemberId uint64     `json:"member_id" binding:"required,numeric"`
+	Barcode  string     `json:"barcode" binding:"required"`
+	DueDate  *time.Time `json:"due_date"`
 }
 
 type getLoanRequestBody struct {
@@ -56,13 +69,43 @@ type updateLoanRequestBody struct {
 	getLoanRequestBody
 }
 
+type returnLoanRequestBody struct {
+	Barcode string `json:"barcode" binding:"required"`
+}
+
 type deleteLoanRequestBody struct {
 	getLoanRequestBody
 }
 
+type renewLoanRequestBody struct {
+	getLoanRequestBody
+}
+
+type renewLoanResult struct {
+	LoanId  uint64                    `json:"loan_id"`
+	Renewed bool                      `json:"renewed"`
+	Loan    *model.BookLoan           `json:"loan,omitempty"`
+	Error   *service.CirculationError `json:"refusal,omitempty"`
+}
+
+type renewLoansResponseBody struct {
+	Results []*renewLoanResult `json:"results"`
+}
+
 type getLoansRequestBody struct {
-	LastId   uint64 `json:"last_id"`
-	PageSize int32  `json:"page_size"`
+	LastId   uint64             `json:"last_id"`
+	Filter   *filter.Expression `json:"filter"`
+	PageSize int32              `json:"page_size"`
+}
+
+var loanFilterFields = filter.Fields{
+	"book_id":       {Column: "book_id", Type: filter.Number},
+	"item_id":       {Column: "item_id", Type: filter.Number},
+	"member_id":     {Column: "member_id", Type: filter.Number},
+	"loan_date":     {Column: "loan_date", Type: filter.Time},
+	"due_date":      {Column: "due_date", Type: filter.Time},
+	"return_date":   {Column: "return_date", Type: filter.Time},
+	"renewal_count": {Column: "renewal_count", Type: filter.Number},
 }
 
 // AddLoadn godoc
@@ -77,34 +120,92 @@ type getLoansRequestBody struct {
 func (api *loansApi) AddLoan(ctx *gin.Context) {
 	var req addLoanRequestBody
 
-	fmt.Println("eheheh")
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	book, err := api.bookService.GetBook(ctx, req.BookId)
+	member, err := api.memberService.GetMember(ctx, req.MemberId)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate member with Id %d", req.MemberId)))
 		return
 	}
 
-	//TODO: Add cache check here
+	// members check out for themselves, librarians for anyone
+	if forbidOtherMember(ctx, api.memberService, member.Id) {
+		return
+	}
+
+	item, err := api.itemService.GetItemByBarcode(ctx, req.Barcode)
 
-	if book.AvailableCopies <= 0 {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("not enough copies available of this book")))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate item with barcode %s", req.Barcode)))
 		return
 	}
 
-	if err := api.bookService.ChangeAvailableCopies(ctx, req.BookId, -1); err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	switch item.Status {
+	case model.ItemStatusAvailable:
+	case model.ItemStatusOnHold:
+		hold, err := api.holdService.GetReadyHold(ctx, item.Id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		if hold.MemberId != req.MemberId {
+			ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("item %s is reserved for another member", item.Barcode)))
+			return
+		}
+	default:
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("item %s is not available for loan", item.Barcode)))
 		return
 	}
 
-	loan, err := api.loanService.SaveLoan(ctx, req.MemberId, req.BookId, req.ReturnDate)
+	var loan *model.BookLoan
+	err = api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		// the limits are checked in the unit of work, so concurrent checkouts
+		// for the member cannot both pass them
+		dueDate, err := api.policyService.CheckCheckout(ctx, member, item, req.DueDate)
+		if err != nil {
+			return err
+		}
+
+		if err := api.itemService.ChangeItemStatus(ctx, item.Id, item.Status, model.ItemStatusOnLoan); err != nil {
+			return err
+		}
+
+		// Copies on the hold shelf are not counted as available.
+		if item.Status == model.ItemStatusAvailable {
+			if err := api.bookService.ChangeAvailableCopies(ctx, item.BookId, -1); err != nil {
+				return err
+			}
+		}
+
+		loan, err = api.loanService.SaveLoan(ctx, req.MemberId, item, dueDate)
+		if err != nil {
+			return err
+		}
+		released, err := api.holdService.FulfillHold(ctx, req.MemberId, item)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range released {
+			if _, err := api.bookService.RefreshAvailableCopies(ctx, other.BookId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		fmt.Println("unable to loan book , ", err)
+		circulationErrorResponse(ctx, err)
+		return
+	}
+
+	book, err := api.bookService.GetBook(ctx, item.BookId)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
@@ -115,7 +216,7 @@ func (api *loansApi) AddLoan(ctx *gin.Context) {
 
 // GetLoan godoc
 // @Summary endpoint to get loan
-// @Description get a loan
+// @Description get a loan, members only get their own
 // @Tags loan
 // @Accept json
 // @Produce json
@@ -135,16 +236,21 @@ func (api *loansApi) GetLoan(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+
+	if forbidOtherMember(ctx, api.memberService, loan.MemberId) {
+		return
+	}
 	ctx.JSON(http.StatusOK, loan)
 }
 
 // UpdateLoan godoc
 // @Summary endpoint to mark a loan as completed
-// @Description mark loan as completed
+// @Description return the loaned item and mark loan as completed
 // @Tags loan
 // @Accept json
 // @Produce json
 // @param id path integer false "loan id"
+// @Param item body returnLoanRequestBody true "Returned item"
 // @Router /v1/loans/:id [put]
 func (api *loansApi) UpdateLoan(ctx *gin.Context) {
 	var req updateLoanRequestBody
@@ -154,29 +260,147 @@ func (api *loansApi) UpdateLoan(ctx *gin.Context) {
 		return
 	}
 
+	var body returnLoanRequestBody
+
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
 	loan, err := api.loanService.GetLoan(ctx, uint64(req.ID))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	err = api.loanService.CompleteLoan(ctx, uint64(req.ID))
+	if loan.ReturnDate != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("loan has already been returned")))
+		return
+	}
+
+	item, err := api.itemService.GetItemByBarcode(ctx, body.Barcode)
+	if err != nil || item.Id != loan.ItemId {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("item %s does not belong to loan %d", body.Barcode, req.ID)))
+		return
+	}
+
+	err = api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := api.loanService.CompleteLoan(ctx, uint64(req.ID)); err != nil {
+			return err
+		}
+		return api.returnItem(ctx, item)
+	})
+	if err != nil {
+		circulationErrorResponse(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// RenewLoan godoc
+// @Summary endpoint to renew a loan
+// @Description push the due date of a loan as allowed by the circulation policy, members only renew their own. A loan returned or renewed meanwhile answers with a conflict
+// @Tags loan
+// @Produce json
+// @param id path integer false "loan id"
+// @Success 200 {object} model.BookLoan
+// @Router /v1/loans/:id/renew [post]
+func (api *loansApi) RenewLoan(ctx *gin.Context) {
+	var req renewLoanRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	loan, err := api.loanService.GetLoan(ctx, uint64(req.ID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate loan with Id %d", req.ID)))
+		return
+	}
+
+	if forbidOtherMember(ctx, api.memberService, loan.MemberId) {
+		return
+	}
+
+	member, err := api.memberService.GetMember(ctx, loan.MemberId)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	err = api.bookService.ChangeAvailableCopies(ctx, loan.BookId, 1)
+	if err := api.renewLoan(ctx, member, loan); err != nil {
+		circulationErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, loan)
+}
+
+// RenewMyLoans godoc
+// @Summary endpoint to renew all loans of the logged in member
+// @Description renew every open loan of the authenticated member, reporting the loans that could not be renewed
+// @Tags loan
+// @Produce json
+// @Success 200 {object} renewLoansResponseBody
+// @Router /v1/me/loans/renew [post]
+func (api *loansApi) RenewMyLoans(ctx *gin.Context) {
+	authPayload := ctx.MustGet(middleware.AuthPayloadKey).(*token.Payload)
+
+	member, err := api.memberService.GetMemberByEmail(ctx, authPayload.Username)
+	if err != nil || member == nil || member.Id == 0 {
+		ctx.JSON(http.StatusNotFound, errorResponse(errors.New("unable to locate logged in member")))
+		return
+	}
+
+	loans, err := api.loanService.GetOpenLoans(ctx, member.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	ctx.Status(http.StatusOK)
+
+	results := make([]*renewLoanResult, len(loans))
+	for idx, loan := range loans {
+		result := &renewLoanResult{LoanId: loan.Id}
+		results[idx] = result
+
+		err := api.renewLoan(ctx, member, loan)
+		var circulationErr *service.CirculationError
+		if errors.As(err, &circulationErr) {
+			result.Error = circulationErr
+			continue
+		}
+
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		result.Renewed = true
+		result.Loan = loan
+	}
+
+	ctx.JSON(http.StatusOK, renewLoansResponseBody{Results: results})
+}
+
+func (api *loansApi) renewLoan(ctx context.Context, member *model.Member, loan *model.BookLoan) error {
+	return api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		item, err := api.itemService.GetItem(ctx, loan.BookId, loan.ItemId)
+		if err != nil {
+			return err
+		}
+
+		dueDate, err := api.policyService.CheckRenewal(ctx, member, item, loan)
+		if err != nil {
+			return err
+		}
+
+		return api.loanService.RenewLoan(ctx, loan, dueDate)
+	})
 }
 
 // GetLoans godoc
 // @Summary endpoint to filter and get loans
-// @Description get a list of loans
+// @Description get a list of loans, members only see their own
 // @Tags loan
 // @Accept json
 // @Produce json
@@ -202,7 +426,28 @@ func (api *loansApi) GetLoans(ctx *gin.Context) {
 		pageSize = 10
 	}
 
-	loans, err := api.loanService.GetLoans(ctx, uint64(lastId), pageSize)
+	where, err := loanFilterFields.Compile(req.Filter)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !isStaff(ctx) {
+		caller, err := callerMember(ctx, api.memberService)
+		if err != nil {
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+
+		own := clause.Eq{Column: clause.Column{Name: "member_id"}, Value: caller.Id}
+		if where == nil {
+			where = own
+		} else {
+			where = clause.And(where, own)
+		}
+	}
+
+	loans, err := api.loanService.GetLoans(ctx, where, uint64(lastId), pageSize)
 
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
@@ -232,18 +477,40 @@ func (api *loansApi) DeleteLoan(ctx *gin.Context) {
 		return
 	}
 
-	err = api.loanService.DeleteLoan(ctx, uint64(req.ID))
+	err = api.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := api.loanService.DeleteLoan(ctx, uint64(req.ID)); err != nil {
+			return err
+		}
+
+		if loan.ReturnDate != nil {
+			return nil
+		}
+
+		item, err := api.itemService.GetItem(ctx, loan.BookId, loan.ItemId)
+		if err != nil {
+			return err
+		}
+		return api.returnItem(ctx, item)
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	ctx.Status(http.StatusOK)
+}
 
-	err = api.bookService.ChangeAvailableCopies(ctx, loan.BookId, 1)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
+// returnItem traps a returned item for the next hold on its book or puts it
+// back on the shelf. It must run inside the unit of work closing the loan.
+func (api *loansApi) returnItem(ctx context.Context, item *model.Item) error {
+	if item.Status != model.ItemStatusOnLoan {
+		return fmt.Errorf("item %s is not on loan", item.Barcode)
 	}
-	ctx.Status(http.StatusOK)
+
+	hold, err := api.holdService.TrapItem(ctx, item)
+	if err != nil || hold != nil {
+		return err
+	}
+	return api.bookService.ChangeAvailableCopies(ctx, item.BookId, 1)
 }
 
 func (api *loansApi) startAnalytics(loan *model.BookLoan, book *model.Book) {
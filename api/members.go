@@ -11,30 +11,44 @@ import (
 	"github.com/dutt23/lms/config"
 	"github.com/dutt23/lms/model"
 	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/dutt23/lms/pkg/filter"
 	service "github.com/dutt23/lms/services"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm/clause"
 )
 
 type membersApi struct {
-	config  *config.AppConfig
-	db      connectors.SqliteConnector
-	cache   cache.MemberCache
-	service service.MemberService
+	config            *config.AppConfig
+	db                connectors.SqliteConnector
+	cache             cache.MemberCache
+	service           service.MemberService
+	credentialService service.CredentialService
+	sessionService    service.SessionService
+	tokenCache        cache.TokenCache
+	accountMails      *accountMails
 }
 
-func NewMembersApi(config *config.AppConfig, db connectors.SqliteConnector, cache cache.MemberCache, service service.MemberService) *membersApi {
+func NewMembersApi(config *config.AppConfig, db connectors.SqliteConnector, cache cache.MemberCache, service service.MemberService, credentialService service.CredentialService, sessionService service.SessionService, tokenCache cache.TokenCache, accountMails *accountMails) *membersApi {
 	return &membersApi{
 		config,
 		db,
 		cache,
 		service,
+		credentialService,
+		sessionService,
+		tokenCache,
+		accountMails,
 	}
 }
 
+// addMemberRequestBody gives a role only when an admin sends it, a new member
+// is a member by default and an updated one keeps their role.
 type addMemberRequestBody struct {
-	Email string `json:"email" binding:"required,email"`
-	Name  string `json:"name" binding:"required,gt=1"`
+	Email      string `json:"email" binding:"required,email"`
+	Name       string `json:"name" binding:"required,gt=1"`
+	MemberType string `json:"member_type" binding:"omitempty,gt=1"`
+	Role       string `json:"role" binding:"omitempty,oneof=member librarian admin"`
 }
 
 type getMemberRequestBody struct {
@@ -42,8 +56,16 @@ type getMemberRequestBody struct {
 }
 
 type getMembersRequestBody struct {
-	LastId   int32 `json:"last_id"`
-	PageSize int32 `json:"page_size"`
+	LastId   int32              `json:"last_id"`
+	Filter   *filter.Expression `json:"filter"`
+	PageSize int32              `json:"page_size"`
+}
+
+var memberFilterFields = filter.Fields{
+	"name":        {Column: "name"},
+	"email":       {Column: "email"},
+	"member_type": {Column: "member_type"},
+	"join_date":   {Column: "join_date", Type: filter.Time},
 }
 
 type getMembersResponse struct {
@@ -54,9 +76,25 @@ type updateMembersRequestBody struct {
 	ID int64 `uri:"id" binding:"required,min=1"`
 }
 
+type setPasswordRequestBody struct {
+	Password string `json:"password" binding:"required,min=8,max=128"`
+}
+
+type addPaymentRequestBody struct {
+	Amount    int64  `json:"amount" binding:"required,gt=0"`
+	Method    string `json:"method" binding:"required,oneof=cash card"`
+	Reference string `json:"reference"`
+}
+
+type addWaiverRequestBody struct {
+	Amount     int64  `json:"amount" binding:"required,gt=0"`
+	ReasonCode string `json:"reason_code" binding:"required,oneof=damaged_item_returned hardship staff_error goodwill other"`
+	Note       string `json:"note"`
+}
+
 // AddMember godoc
 // @Summary endpoint to create member
-// @Description add a member
+// @Description add a member, mailing them a link to verify their email
 // @Tags member
 // @Accept json
 // @Produce json
@@ -76,10 +114,16 @@ func (api *membersApi) AddMember(ctx *gin.Context) {
 		return
 	}
 
+	if req.Role != "" && req.Role != model.RoleMember && !api.allowRoleChange(ctx) {
+		return
+	}
+
 	member := &model.Member{
-		Email:    req.Email,
-		Name:     req.Name,
-		JoinDate: time.Now(),
+		Email:      req.Email,
+		Name:       req.Name,
+		MemberType: memberType(req.MemberType),
+		Role:       memberRole(req.Role),
+		JoinDate:   time.Now(),
 	}
 
 	err := api.db.DB(ctx).Create(member).Error
@@ -89,13 +133,17 @@ func (api *membersApi) AddMember(ctx *gin.Context) {
 		return
 	}
 
+	if err := api.accountMails.SendVerification(ctx, member); err != nil {
+		fmt.Println("unable to mail email verification ", err)
+	}
+
 	go api.postProcessAddingMember(member)
 	ctx.JSON(http.StatusOK, member)
 }
 
 // GetMember godoc
 // @Summary endpoint to get member
-// @Description get a member
+// @Description get a member, members only get themselves
 // @Tags member
 // @Produce json
 // @param id path integer false "member id"
@@ -109,6 +157,10 @@ func (api *membersApi) GetMember(ctx *gin.Context) {
 		return
 	}
 
+	if forbidOtherMember(ctx, api.service, req.ID) {
+		return
+	}
+
 	member, err := api.service.GetMember(ctx, req.ID)
 	if err != nil {
 		fmt.Errorf("error : %w", err)
@@ -180,8 +232,18 @@ func (api *membersApi) GetMembers(ctx *gin.Context) {
 		pageSize = 10
 	}
 
+	where, err := memberFilterFields.Compile(req.Filter)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
 	qry := api.db.DB(ctx).Model(model.Member{}).Where("id > ?", lastId).Limit(pageSize)
 
+	if where != nil {
+		qry = qry.Where(where)
+	}
+
 	tx := qry.Order(clause.OrderByColumn{
 		Column: clause.Column{Name: "join_date"},
 		Desc:   true,
@@ -198,7 +260,7 @@ func (api *membersApi) GetMembers(ctx *gin.Context) {
 
 // UpdateMembers godoc
 // @Summary endpoint to update member
-// @Description update member data
+// @Description update member data, a changed email having to be verified again. A changed role logs the member out of every device. Only admins update staff
 // @Tags member
 // @Produce json
 // @Accept json
@@ -230,20 +292,252 @@ func (api *membersApi) UpdateMember(ctx *gin.Context) {
 		return
 	}
 
+	var current model.Member
+	if err := api.db.DB(ctx).Select("email", "role").Take(&current, req.ID).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	emailChanged := current.Email != body.Email
+
+	// a librarian changing the email of staff could reset their password
+	isStaff := current.Role != model.RoleMember || current.Email == api.config.AdminEmail
+	if isStaff && !model.HasRole(authPayload(ctx).Role, model.RoleAdmin) {
+		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only admins update staff")))
+		return
+	}
+
+	// a kept email stays verified, a changed one is cleared by the save. The
+	// member type and role are kept unless given, the join date always
+	omit := []string{"join_date"}
+	if !emailChanged {
+		omit = append(omit, "email_verified_at")
+	}
+	if body.MemberType == "" {
+		omit = append(omit, "member_type")
+	}
+	if body.Role == "" {
+		omit = append(omit, "role")
+	} else if !api.allowRoleChange(ctx) {
+		return
+	}
+
 	member := &model.Member{
-		Email:    body.Email,
-		Name:     body.Name,
-		JoinDate: time.Now(),
+		Audited: model.Audited{
+			Id: uint64(req.ID),
+		},
+		Email:      body.Email,
+		Name:       body.Name,
+		MemberType: body.MemberType,
+		Role:       body.Role,
+	}
+
+	if err := api.db.DB(ctx).Omit(omit...).Save(member).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
 	}
 
-	if err := api.db.DB(ctx).Save(member).Error; err != nil {
+	if err := api.db.DB(ctx).Select(omit).Take(member).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if emailChanged {
+		if err := api.accountMails.SendVerification(ctx, member); err != nil {
+			fmt.Println("unable to mail email verification ", err)
+		}
+	}
+
+	// tokens carry the role they were issued with, so they are revoked for the
+	// member to login again with the new one
+	if member.Role != current.Role {
+		sessions, err := api.sessionService.RevokeUserSessions(ctx, current.Email, uuid.Nil)
+		if err != nil {
+			fmt.Println("unable to revoke sessions ", err)
+		}
+		revokeAccessTokens(ctx, api.tokenCache, sessions)
 	}
 
 	go api.postProcessAddingMember(member)
 	ctx.JSON(http.StatusOK, member)
 }
 
+// SetMemberPassword godoc
+// @Summary endpoint to set a member's password
+// @Description set the password of a member staff added. Only admins set the passwords of staff
+// @Tags member
+// @Accept json
+// @param id path integer true "member id"
+// @Param password body setPasswordRequestBody true "Password"
+// @Success 200
+// @Router /v1/members/{id}/password [put]
+func (api *membersApi) SetMemberPassword(ctx *gin.Context) {
+	var uri getMemberRequestBody
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req setPasswordRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	member, err := api.service.GetMember(ctx, uri.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate member with Id %d", uri.ID)))
+		return
+	}
+
+	// a librarian setting an admin's password could login as them
+	isStaff := member.Role != model.RoleMember || member.Email == api.config.AdminEmail
+	if isStaff && !model.HasRole(authPayload(ctx).Role, model.RoleAdmin) {
+		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only admins set the passwords of staff")))
+		return
+	}
+
+	if err := api.credentialService.SetPassword(ctx, member.Id, req.Password); err != nil {
+		fmt.Println("unable to set password ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to set the password of member %d", uri.ID)))
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// GetMemberAccount godoc
+// @Summary endpoint to get a member's account
+// @Description get the fines balance and ledger lines of a member, members only get their own
+// @Tags member
+// @Produce json
+// @param id path integer false "member id"
+// @Success 200 {object} model.MemberAccount
+// @Router /v1/members/:id/account [get]
+func (api *membersApi) GetMemberAccount(ctx *gin.Context) {
+	var req getMemberRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if forbidOtherMember(ctx, api.service, req.ID) {
+		return
+	}
+
+	if _, err := api.service.GetMember(ctx, req.ID); err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate member with Id %d", req.ID)))
+		return
+	}
+
+	account, err := api.service.GetAccount(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, account)
+}
+
+// AddMemberPayment godoc
+// @Summary endpoint to record a payment
+// @Description record a full or partial payment of a member's fines
+// @Tags member
+// @Accept json
+// @Produce json
+// @param id path integer false "member id"
+// @Param payment body addPaymentRequestBody true "Payment data"
+// @Success 201 {object} model.Fine
+// @Router /v1/members/:id/account/payments [post]
+func (api *membersApi) AddMemberPayment(ctx *gin.Context) {
+	var req getMemberRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var body addPaymentRequestBody
+
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !api.checkCredit(ctx, req.ID, body.Amount) {
+		return
+	}
+
+	line, err := api.service.RecordPayment(ctx, req.ID, body.Amount, body.Method, body.Reference)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to record payment")))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, line)
+}
+
+// AddMemberWaiver godoc
+// @Summary endpoint to waive fines
+// @Description waive part or all of a member's fines with a reason code
+// @Tags member
+// @Accept json
+// @Produce json
+// @param id path integer false "member id"
+// @Param waiver body addWaiverRequestBody true "Waiver data"
+// @Success 201 {object} model.Fine
+// @Router /v1/members/:id/account/waivers [post]
+func (api *membersApi) AddMemberWaiver(ctx *gin.Context) {
+	var req getMemberRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var body addWaiverRequestBody
+
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !api.checkCredit(ctx, req.ID, body.Amount) {
+		return
+	}
+
+	line, err := api.service.RecordWaiver(ctx, req.ID, body.Amount, body.ReasonCode, body.Note)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to record waiver")))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, line)
+}
+
+// checkCredit makes sure a payment or waiver does not exceed what the member
+// owes, answering the request otherwise.
+func (api *membersApi) checkCredit(ctx *gin.Context, memberId uint64, amount int64) bool {
+	if _, err := api.service.GetMember(ctx, memberId); err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate member with Id %d", memberId)))
+		return false
+	}
+
+	balance, err := api.service.GetBalance(ctx, memberId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+
+	if amount > balance {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("amount %d exceeds the outstanding balance of %d", amount, balance)))
+		return false
+	}
+	return true
+}
+
 func (api *membersApi) invalidateMemberCache(bookId uint64) {
 	ctx := context.Background()
 	if err := api.cache.DeleteMember(ctx, bookId); err != nil {
@@ -261,3 +555,28 @@ func (api *membersApi) storeMemberMeta(ctx context.Context, member *model.Member
 		fmt.Printf("Error occurred while adding book to cache %w", err)
 	}
 }
+
+// allowRoleChange answers with a 403 unless the caller is an admin,
+// reporting whether they are.
+func (api *membersApi) allowRoleChange(ctx *gin.Context) bool {
+	if model.HasRole(authPayload(ctx).Role, model.RoleAdmin) {
+		return true
+	}
+
+	ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only admins hand out roles")))
+	return false
+}
+
+func memberRole(requested string) string {
+	if requested == "" {
+		return model.RoleMember
+	}
+	return requested
+}
+
+func memberType(requested string) string {
+	if requested == "" {
+		return model.DefaultMemberType
+	}
+	return requested
+}
@@ -0,0 +1,453 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/dublincore"
+	"github.com/dutt23/lms/pkg/marc"
+	"github.com/dutt23/lms/pkg/oai"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// oaiPageSize is how many headers or records a list response holds before
+// it hands out a resumption token.
+const oaiPageSize = 100
+
+// languageSet prefixes the set specs, books are grouped into sets by language.
+const languageSet = "language:"
+
+type oaiApi struct {
+	config         *config.AppConfig
+	harvestService service.HarvestService
+}
+
+func NewOaiApi(config *config.AppConfig, harvestService service.HarvestService) *oaiApi {
+	return &oaiApi{
+		config,
+		harvestService,
+	}
+}
+
+// oaiArguments lists the arguments every verb takes besides the verb itself,
+// true marking the required ones.
+var oaiArguments = map[string]map[string]bool{
+	"Identify":            {},
+	"ListMetadataFormats": {"identifier": false},
+	"ListSets":            {"resumptionToken": false},
+	"GetRecord":           {"identifier": true, "metadataPrefix": true},
+	"ListIdentifiers":     {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+	"ListRecords":         {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+}
+
+type oaiFormat struct {
+	oai.MetadataFormat
+	render func(book *model.Book) any
+}
+
+var oaiFormats = []*oaiFormat{
+	{
+		MetadataFormat: oai.MetadataFormat{MetadataPrefix: "oai_dc", Schema: dublincore.Schema, MetadataNamespace: dublincore.OAINamespace},
+		render:         func(book *model.Book) any { return dublincore.FromBook(book) },
+	},
+	{
+		MetadataFormat: oai.MetadataFormat{MetadataPrefix: "marcxml", Schema: "http://www.loc.gov/standards/marcxml/schema/MARC21slim.xsd", MetadataNamespace: marc.Namespace},
+		render:         func(book *model.Book) any { return marc.FromBook(book) },
+	},
+}
+
+// Oai godoc
+// @Summary OAI-PMH 2.0 provider
+// @Description harvest the catalogue with the Identify, ListMetadataFormats, ListSets, ListIdentifiers, ListRecords and GetRecord verbs. Records come as oai_dc or marcxml, sets group books by language (language:eng) and from/until select by the date a book last changed
+// @Tags oai
+// @Produce xml
+// @Param verb query string true "OAI-PMH verb"
+// @Param identifier query string false "oai:<repository>:<book id>"
+// @Param metadataPrefix query string false "oai_dc or marcxml"
+// @Param from query string false "YYYY-MM-DD or YYYY-MM-DDThh:mm:ssZ"
+// @Param until query string false "YYYY-MM-DD or YYYY-MM-DDThh:mm:ssZ"
+// @Param set query string false "set spec"
+// @Param resumptionToken query string false "resumption token of the previous response"
+// @Success 200
+// @Router /oai [get]
+// @Router /oai [post]
+func (api *oaiApi) Oai(ctx *gin.Context) {
+	request := &oai.Request{BaseURL: baseURL(ctx)}
+	res := oai.NewResponse(request, time.Now())
+
+	if err := ctx.Request.ParseForm(); err != nil {
+		res.Errors = []*oai.Error{oai.NewError(oai.ErrBadArgument, "the request arguments could not be read")}
+		renderOai(ctx, res)
+		return
+	}
+
+	args := ctx.Request.Form
+	verb := args.Get("verb")
+	allowed, ok := oaiArguments[verb]
+	if !ok || len(args["verb"]) != 1 {
+		res.Errors = []*oai.Error{oai.NewError(oai.ErrBadVerb, "the verb is missing, repeated or not an OAI-PMH verb")}
+		renderOai(ctx, res)
+		return
+	}
+
+	if err := checkOaiArguments(args, allowed); err != nil {
+		res.Errors = []*oai.Error{err}
+		renderOai(ctx, res)
+		return
+	}
+
+	request.Verb = verb
+	request.Identifier = args.Get("identifier")
+	request.MetadataPrefix = args.Get("metadataPrefix")
+	request.From = args.Get("from")
+	request.Until = args.Get("until")
+	request.Set = args.Get("set")
+	request.ResumptionToken = args.Get("resumptionToken")
+
+	var err error
+	switch verb {
+	case "Identify":
+		res.Identify, err = api.identify(ctx, request)
+	case "ListMetadataFormats":
+		res.ListMetadataFormats, err = api.listMetadataFormats(ctx, request)
+	case "ListSets":
+		res.ListSets, err = api.listSets(ctx, request)
+	case "GetRecord":
+		res.GetRecord, err = api.getRecord(ctx, request)
+	case "ListIdentifiers":
+		res.ListIdentifiers, err = api.listIdentifiers(ctx, request)
+	case "ListRecords":
+		res.ListRecords, err = api.listRecords(ctx, request)
+	}
+
+	var oaiErr *oai.Error
+	if errors.As(err, &oaiErr) {
+		res.Errors = []*oai.Error{oaiErr}
+	} else if err != nil {
+		fmt.Println("unable to answer oai request ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to answer the OAI-PMH request")))
+		return
+	}
+
+	renderOai(ctx, res)
+}
+
+func (api *oaiApi) identify(ctx *gin.Context, request *oai.Request) (*oai.Identify, error) {
+	earliest, err := api.harvestService.EarliestDatestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oai.Identify{
+		RepositoryName:    api.config.OaiConfig.RepositoryName,
+		BaseURL:           request.BaseURL,
+		ProtocolVersion:   oai.ProtocolVersion,
+		AdminEmail:        []string{api.config.OaiConfig.AdminEmail},
+		EarliestDatestamp: oai.Datestamp(earliest),
+		DeletedRecord:     "no",
+		Granularity:       oai.Granularity,
+	}, nil
+}
+
+func (api *oaiApi) listMetadataFormats(ctx *gin.Context, request *oai.Request) (*oai.ListMetadataFormats, error) {
+	if request.Identifier != "" {
+		if _, err := api.getBook(ctx, request.Identifier); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &oai.ListMetadataFormats{}
+	for _, format := range oaiFormats {
+		res.MetadataFormats = append(res.MetadataFormats, &format.MetadataFormat)
+	}
+	return res, nil
+}
+
+func (api *oaiApi) listSets(ctx *gin.Context, request *oai.Request) (*oai.ListSets, error) {
+	// the sets fit in a single response, there is nothing to resume
+	if request.ResumptionToken != "" {
+		return nil, oai.NewError(oai.ErrBadResumptionToken, "the resumption token is invalid")
+	}
+
+	languages, err := api.harvestService.GetLanguages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(languages) == 0 {
+		return nil, oai.NewError(oai.ErrNoSetHierarchy, "the catalogue is empty, there are no sets")
+	}
+
+	res := &oai.ListSets{}
+	for _, language := range languages {
+		res.Sets = append(res.Sets, &oai.Set{
+			SetSpec: languageSet + marc.LanguageCode(language),
+			SetName: fmt.Sprintf("Books in %s", language),
+		})
+	}
+	return res, nil
+}
+
+func (api *oaiApi) getRecord(ctx *gin.Context, request *oai.Request) (*oai.GetRecord, error) {
+	format, err := findOaiFormat(request.MetadataPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := api.getBook(ctx, request.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	return &oai.GetRecord{Record: api.record(book, format)}, nil
+}
+
+func (api *oaiApi) listIdentifiers(ctx *gin.Context, request *oai.Request) (*oai.ListIdentifiers, error) {
+	books, token, _, err := api.list(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &oai.ListIdentifiers{ResumptionToken: token}
+	for _, book := range books {
+		res.Headers = append(res.Headers, api.header(book))
+	}
+	return res, nil
+}
+
+func (api *oaiApi) listRecords(ctx *gin.Context, request *oai.Request) (*oai.ListRecords, error) {
+	books, token, format, err := api.list(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &oai.ListRecords{ResumptionToken: token}
+	for _, book := range books {
+		res.Records = append(res.Records, api.record(book, format))
+	}
+	return res, nil
+}
+
+// list fetches the page of books a list request asks for. The resumption
+// token carries the request's arguments with the id of the last book handed
+// out, so the next page starts right after it.
+func (api *oaiApi) list(ctx *gin.Context, request *oai.Request) ([]*model.Book, *oai.ResumptionToken, *oaiFormat, error) {
+	token := &oai.Token{
+		MetadataPrefix: request.MetadataPrefix,
+		From:           request.From,
+		Until:          request.Until,
+		Set:            request.Set,
+	}
+
+	resumed := request.ResumptionToken != ""
+	if resumed {
+		var err error
+		if token, err = oai.DecodeToken(request.ResumptionToken); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	format, err := findOaiFormat(token.MetadataPrefix)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	query, err := harvestQuery(token)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	books, err := api.harvestService.GetBooks(ctx, query, token.LastId, oaiPageSize+1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(books) == 0 {
+		return nil, nil, nil, oai.NewError(oai.ErrNoRecordsMatch, "no books match the request")
+	}
+
+	more := len(books) > oaiPageSize
+	if !more && !resumed {
+		return books, nil, format, nil
+	}
+
+	total, err := api.harvestService.CountBooks(ctx, query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// the last page of a resumed list ends it with an empty token
+	res := &oai.ResumptionToken{CompleteListSize: total, Cursor: token.Cursor}
+	if more {
+		books = books[:oaiPageSize]
+		next := *token
+		next.LastId = books[len(books)-1].Id
+		next.Cursor += int64(len(books))
+		res.Token = next.Encode()
+	}
+	return books, res, format, nil
+}
+
+func (api *oaiApi) getBook(ctx *gin.Context, identifier string) (*model.Book, error) {
+	prefix := fmt.Sprintf("oai:%s:", api.config.OaiConfig.RepositoryIdentifier)
+	bookId, err := strconv.ParseUint(strings.TrimPrefix(identifier, prefix), 10, 64)
+	if err != nil || !strings.HasPrefix(identifier, prefix) {
+		return nil, oai.NewError(oai.ErrIdDoesNotExist, fmt.Sprintf("%s is not an identifier of this repository", identifier))
+	}
+
+	book, err := api.harvestService.GetBook(ctx, bookId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, oai.NewError(oai.ErrIdDoesNotExist, fmt.Sprintf("there is no record %s", identifier))
+	}
+	return book, err
+}
+
+func (api *oaiApi) header(book *model.Book) *oai.Header {
+	return &oai.Header{
+		Identifier: fmt.Sprintf("oai:%s:%d", api.config.OaiConfig.RepositoryIdentifier, book.Id),
+		Datestamp:  oai.Datestamp(book.UpdatedAt),
+		SetSpecs:   []string{languageSet + marc.LanguageCode(book.Language)},
+	}
+}
+
+func (api *oaiApi) record(book *model.Book, format *oaiFormat) *oai.Record {
+	return &oai.Record{
+		Header:   api.header(book),
+		Metadata: &oai.Metadata{Value: format.render(book)},
+	}
+}
+
+// checkOaiArguments refuses arguments the verb doesn't take, repeated ones
+// and missing required ones. A resumption token is exclusive, the arguments
+// it continues are inside it.
+func checkOaiArguments(args url.Values, allowed map[string]bool) *oai.Error {
+	for name, values := range args {
+		if name == "verb" {
+			continue
+		}
+
+		if _, ok := allowed[name]; !ok {
+			return oai.NewError(oai.ErrBadArgument, fmt.Sprintf("%s is not an argument of the verb", name))
+		}
+
+		if len(values) != 1 {
+			return oai.NewError(oai.ErrBadArgument, fmt.Sprintf("%s is repeated", name))
+		}
+	}
+
+	if _, ok := args["resumptionToken"]; ok {
+		if len(args) != 2 {
+			return oai.NewError(oai.ErrBadArgument, "resumptionToken is an exclusive argument")
+		}
+		return nil
+	}
+
+	for name, required := range allowed {
+		if _, ok := args[name]; required && !ok {
+			return oai.NewError(oai.ErrBadArgument, fmt.Sprintf("%s is required", name))
+		}
+	}
+	return nil
+}
+
+func findOaiFormat(prefix string) (*oaiFormat, error) {
+	for _, format := range oaiFormats {
+		if format.MetadataPrefix == prefix {
+			return format, nil
+		}
+	}
+	return nil, oai.NewError(oai.ErrCannotDisseminateFormat, fmt.Sprintf("%s is not a supported metadata format", prefix))
+}
+
+// harvestQuery turns the selective harvesting arguments into a query. Until
+// is inclusive at its granularity, a day or a second.
+func harvestQuery(token *oai.Token) (*service.HarvestQuery, error) {
+	query := &service.HarvestQuery{}
+
+	from, fromDay, err := parseDatestamp("from", token.From)
+	if err != nil {
+		return nil, err
+	}
+
+	until, untilDay, err := parseDatestamp("until", token.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	if from != nil && until != nil {
+		if fromDay != untilDay {
+			return nil, oai.NewError(oai.ErrBadArgument, "from and until have to have the same granularity")
+		}
+
+		if from.After(*until) {
+			return nil, oai.NewError(oai.ErrBadArgument, "from is later than until")
+		}
+	}
+
+	query.From = from
+	if until != nil {
+		end := until.Add(time.Second)
+		if untilDay {
+			end = until.AddDate(0, 0, 1)
+		}
+		query.Until = &end
+	}
+
+	if token.Set != "" {
+		code, ok := strings.CutPrefix(token.Set, languageSet)
+		if !ok || code == "" {
+			return nil, oai.NewError(oai.ErrNoRecordsMatch, fmt.Sprintf("there is no set %s", token.Set))
+		}
+		query.Language = marc.LanguageName(code)
+	}
+	return query, nil
+}
+
+func parseDatestamp(name, value string) (*time.Time, bool, error) {
+	if value == "" {
+		return nil, false, nil
+	}
+
+	if t, err := time.Parse(oai.DayFormat, value); err == nil {
+		return &t, true, nil
+	}
+
+	if t, err := time.Parse(oai.DatestampFormat, value); err == nil {
+		return &t, false, nil
+	}
+	return nil, false, oai.NewError(oai.ErrBadArgument, fmt.Sprintf("%s is neither YYYY-MM-DD nor YYYY-MM-DDThh:mm:ssZ", name))
+}
+
+// baseURL is the URL harvesters reached the provider on.
+func baseURL(ctx *gin.Context) string {
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+
+	if proto := ctx.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, ctx.Request.Host, ctx.Request.URL.Path)
+}
+
+func renderOai(ctx *gin.Context, res *oai.Response) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(res); err != nil {
+		fmt.Println("unable to render oai response ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to answer the OAI-PMH request")))
+		return
+	}
+	ctx.Data(http.StatusOK, "text/xml; charset=utf-8", buf.Bytes())
+}
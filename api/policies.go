@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+)
+
+type policiesApi struct {
+	config        *config.AppConfig
+	policyService service.PolicyService
+}
+
+func NewPoliciesApi(config *config.AppConfig, policyService service.PolicyService) *policiesApi {
+	return &policiesApi{
+		config,
+		policyService,
+	}
+}
+
+type addPolicyRequestBody struct {
+	MemberType     string `json:"member_type" binding:"required"`
+	ItemType       string `json:"item_type" binding:"required"`
+	LoanPeriodDays int64  `json:"loan_period_days" binding:"required,gt=0"`
+	MaxLoans       *int64 `json:"max_loans" binding:"required,gte=0"`
+	MaxRenewals    int64  `json:"max_renewals" binding:"gte=0"`
+	GraceDays      int64  `json:"grace_days" binding:"gte=0"`
+	DailyFine      int64  `json:"daily_fine" binding:"gte=0"`
+	MaxFine        int64  `json:"max_fine" binding:"gte=0"`
+}
+
+type getPolicyRequestBody struct {
+	ID uint64 `uri:"id" binding:"required,min=1"`
+}
+
+type getPoliciesResponseBody struct {
+	Policies []*model.CirculationPolicy `json:"policies"`
+}
+
+// AddPolicy godoc
+// @Summary endpoint to create or replace a circulation policy
+// @Description set the loan period and limits for a member type and item type, "*" matches any type
+// @Tags policy
+// @Accept json
+// @Produce json
+// @Param policy body addPolicyRequestBody true "Policy data"
+// @Success 200 {object} model.CirculationPolicy
+// @Router /v1/policies [post]
+func (api *policiesApi) AddPolicy(ctx *gin.Context) {
+	var req addPolicyRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	policy := &model.CirculationPolicy{
+		MemberType:     req.MemberType,
+		ItemType:       req.ItemType,
+		LoanPeriodDays: req.LoanPeriodDays,
+		MaxLoans:       *req.MaxLoans,
+		MaxRenewals:    req.MaxRenewals,
+		GraceDays:      req.GraceDays,
+		DailyFine:      req.DailyFine,
+		MaxFine:        req.MaxFine,
+	}
+
+	if err := api.policyService.SavePolicy(ctx, policy); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to save circulation policy")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, policy)
+}
+
+// GetPolicies godoc
+// @Summary endpoint to get circulation policies
+// @Description get all circulation policies
+// @Tags policy
+// @Produce json
+// @Success 200 {object} getPoliciesResponseBody
+// @Router /v1/policies [get]
+func (api *policiesApi) GetPolicies(ctx *gin.Context) {
+	policies, err := api.policyService.GetPolicies(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any circulation policies")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getPoliciesResponseBody{Policies: policies})
+}
+
+// DeletePolicy godoc
+// @Summary endpoint to delete a circulation policy
+// @Description delete a circulation policy
+// @Tags policy
+// @param id path integer false "policy id"
+// @Success 200
+// @Router /v1/policies/:id [delete]
+func (api *policiesApi) DeletePolicy(ctx *gin.Context) {
+	var req getPolicyRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err := api.policyService.DeletePolicy(ctx, req.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+)
+
+type searchApi struct {
+	config        *config.AppConfig
+	searchService service.SearchService
+}
+
+func NewSearchApi(config *config.AppConfig, searchService service.SearchService) *searchApi {
+	return &searchApi{
+		config,
+		searchService,
+	}
+}
+
+type searchRequestBody struct {
+	model.FacetSelection
+	Query    string `form:"q" binding:"required,max=256"`
+	Cursor   string `form:"cursor"`
+	PageSize int32  `form:"page_size"`
+}
+
+type searchResponseBody struct {
+	Hits       []*model.BookSearchHit `json:"hits"`
+	Facets     *model.BookFacets      `json:"facets,omitempty"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// SearchBooks godoc
+// @Summary endpoint to search the catalogue
+// @Description full text search over title, author, isbn, language and subjects ranked by relevance. Words are matched together, "quoted text" as a phrase and a trailing * as a prefix.
+// @Description Facet counts over all matches come with the first page, selected facet values narrow the hits down
+// @Tags search
+// @Produce json
+// @Param q query string true "search query"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param page_size query integer false "hits per page"
+// @Param author query []string false "selected author facet values" collectionFormat(multi)
+// @Param language query []string false "selected language facet values" collectionFormat(multi)
+// @Param decade query []integer false "selected decade facet values" collectionFormat(multi)
+// @Param availability query []string false "selected availability facet values" collectionFormat(multi)
+// @Success 200 {object} searchResponseBody
+// @Router /v1/search [get]
+func (api *searchApi) SearchBooks(ctx *gin.Context) {
+	var req searchRequestBody
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	pageSize := 10
+
+	if req.PageSize > 0 && req.PageSize < 100 {
+		pageSize = int(req.PageSize)
+	}
+
+	var after *service.SearchCursor
+	if req.Cursor != "" {
+		cursor, err := decodeSearchCursor(req.Cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid search cursor")))
+			return
+		}
+		after = cursor
+	}
+
+	hits, err := api.searchService.SearchBooks(ctx, req.Query, &req.FacetSelection, after, pageSize)
+	if errors.Is(err, service.ErrEmptySearchQuery) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to search books")))
+		return
+	}
+
+	res := searchResponseBody{Hits: hits}
+
+	// facets describe the whole result set, later pages don't repeat them
+	if after == nil {
+		res.Facets, err = api.searchService.SearchFacets(ctx, req.Query, &req.FacetSelection)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to count search facets")))
+			return
+		}
+	}
+
+	if len(hits) == pageSize {
+		last := hits[len(hits)-1]
+		res.NextCursor = encodeSearchCursor(&service.SearchCursor{Score: last.Score, Id: last.Id})
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+func encodeSearchCursor(cursor *service.SearchCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSearchCursor(value string) (*service.SearchCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor *service.SearchCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, err
+	}
+
+	if cursor == nil {
+		return nil, errors.New("empty cursor")
+	}
+	return cursor, nil
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/cql"
+	"github.com/dutt23/lms/pkg/dublincore"
+	"github.com/dutt23/lms/pkg/isbn"
+	"github.com/dutt23/lms/pkg/marc"
+	"github.com/dutt23/lms/pkg/sru"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sruDefaultMaximumRecords = 10
+	sruMaxMaximumRecords     = 100
+)
+
+type sruIndex struct {
+	set   string
+	name  string
+	title string
+	index cql.Index
+}
+
+// sruIndexes are the indexes books can be searched on, in the order explain
+// lists them. cql.serverChoice, a term on its own, searches the text ones.
+var sruIndexes = []*sruIndex{
+	{"dc", "title", "Title", cql.Index{Columns: []string{"title"}}},
+	{"dc", "creator", "Author", cql.Index{Columns: []string{"author"}}},
+	{"dc", "subject", "Subject", cql.Index{Columns: []string{"subjects"}}},
+	{"dc", "language", "Language", cql.Index{Columns: []string{"language"}}},
+	{"bath", "isbn", "ISBN", cql.Index{Columns: []string{"isbn"}, Normalize: normalizeIsbnTerm}},
+	{"cql", "serverChoice", "Title, author or subject", cql.Index{Columns: []string{"title", "author", "subjects"}}},
+}
+
+var sruIndexSets = []*sru.IndexSet{
+	{Name: "cql", Identifier: "info:srw/cql-context-set/1/cql-v1.2"},
+	{Name: "dc", Identifier: "info:srw/cql-context-set/1/dc-v1.1"},
+	{Name: "bath", Identifier: "http://zing.z3950.org/cql/bath/2.0/"},
+}
+
+var bookIndexes = func() cql.Indexes {
+	indexes := cql.Indexes{}
+	for _, index := range sruIndexes {
+		indexes[strings.ToLower(index.set+"."+index.name)] = index.index
+	}
+	return indexes
+}()
+
+type sruSchema struct {
+	sru.Schema
+	render func(book *model.Book) any
+}
+
+// sruSchemas are the schemas records come in, the first being the default.
+// A schema can be asked for by name or identifier.
+var sruSchemas = []*sruSchema{
+	{
+		Schema: sru.Schema{Identifier: "info:srw/schema/1/dc-v1.1", Name: "dc", Title: "Dublin Core"},
+		render: func(book *model.Book) any { return dublincore.FromBook(book) },
+	},
+	{
+		Schema: sru.Schema{Identifier: "info:srw/schema/1/marcxml-v1.1", Name: "marcxml", Title: "MARCXML"},
+		render: func(book *model.Book) any { return marc.FromBook(book) },
+	},
+}
+
+// sruParameters lists the parameters an operation takes besides operation
+// and version. Extension parameters, x-..., are let through.
+var sruParameters = map[string]map[string]bool{
+	sru.OperationExplain:        {"recordPacking": true, "stylesheet": true},
+	sru.OperationSearchRetrieve: {"query": true, "startRecord": true, "maximumRecords": true, "recordPacking": true, "recordSchema": true, "stylesheet": true},
+}
+
+type sruApi struct {
+	config        *config.AppConfig
+	searchService service.SearchService
+}
+
+func NewSruApi(config *config.AppConfig, searchService service.SearchService) *sruApi {
+	return &sruApi{
+		config,
+		searchService,
+	}
+}
+
+// Sru godoc
+// @Summary SRU 1.2 server
+// @Description search the catalogue with CQL through the explain and searchRetrieve operations. Queries can use the dc.title, dc.creator, dc.subject, dc.language and bath.isbn indexes with and, or, not and parentheses, * and ? mask characters. Records come as Dublin Core or MARCXML
+// @Tags sru
+// @Produce xml
+// @Param operation query string false "explain (default) or searchRetrieve"
+// @Param version query string false "1.1 or 1.2"
+// @Param query query string false "CQL query, required by searchRetrieve"
+// @Param startRecord query int false "position of the first record, from 1"
+// @Param maximumRecords query int false "records per response, 10 by default and at most 100"
+// @Param recordSchema query string false "dc (default) or marcxml"
+// @Param recordPacking query string false "xml (default) or string"
+// @Success 200
+// @Router /sru [get]
+// @Router /sru [post]
+func (api *sruApi) Sru(ctx *gin.Context) {
+	if err := ctx.Request.ParseForm(); err != nil {
+		api.renderDiagnostic(ctx, sru.OperationExplain, sru.NewDiagnostic(sru.DiagnosticUnsupportedParamValue, "", "the request parameters could not be read"))
+		return
+	}
+
+	args := ctx.Request.Form
+	operation := args.Get("operation")
+	if operation == "" {
+		operation = sru.OperationExplain
+		if args.Has("query") {
+			operation = sru.OperationSearchRetrieve
+		}
+	}
+
+	allowed, ok := sruParameters[operation]
+	if !ok {
+		api.renderDiagnostic(ctx, sru.OperationExplain, sru.NewDiagnostic(sru.DiagnosticUnsupportedOperation, operation, fmt.Sprintf("%s is not an SRU operation", operation)))
+		return
+	}
+
+	if version := args.Get("version"); version != "" && version != "1.1" && version != sru.Version {
+		api.renderDiagnostic(ctx, operation, sru.NewDiagnostic(sru.DiagnosticUnsupportedVersion, sru.Version, fmt.Sprintf("version %s is not supported", version)))
+		return
+	}
+
+	if diagnostic := checkSruParameters(args, allowed); diagnostic != nil {
+		api.renderDiagnostic(ctx, operation, diagnostic)
+		return
+	}
+
+	packing := args.Get("recordPacking")
+	if packing == "" {
+		packing = sru.PackingXML
+	}
+
+	if packing != sru.PackingXML && packing != sru.PackingString {
+		api.renderDiagnostic(ctx, operation, sru.NewDiagnostic(sru.DiagnosticUnsupportedPacking, packing, "records are packed as xml or string"))
+		return
+	}
+
+	if operation == sru.OperationExplain {
+		res := sru.NewExplainResponse()
+		res.Record = &sru.Record{
+			RecordSchema:   "http://explain.z3950.org/dtd/2.0/",
+			RecordPacking:  packing,
+			RecordData:     packRecord(api.explain(ctx), packing),
+			RecordPosition: 1,
+		}
+		renderSru(ctx, res)
+		return
+	}
+
+	res, err := api.searchRetrieve(ctx, args, packing)
+	if err != nil {
+		fmt.Println("unable to answer sru request ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to answer the SRU request")))
+		return
+	}
+	renderSru(ctx, res)
+}
+
+// searchRetrieve answers a query with a page of records. A refused query is
+// reported as a diagnostic, only failures to read the catalogue are errors.
+func (api *sruApi) searchRetrieve(ctx *gin.Context, args url.Values, packing string) (*sru.SearchRetrieveResponse, error) {
+	res := sru.NewSearchRetrieveResponse()
+	res.EchoedRequest = &sru.EchoedRequest{
+		Version:       sru.Version,
+		Query:         args.Get("query"),
+		RecordPacking: packing,
+		RecordSchema:  args.Get("recordSchema"),
+		BaseURL:       baseURL(ctx),
+	}
+
+	if !args.Has("query") {
+		res.Diagnostics = sru.NewDiagnostics(sru.NewDiagnostic(sru.DiagnosticMissingParameter, "query", "searchRetrieve needs a query"))
+		return res, nil
+	}
+
+	startRecord, diagnostic := sruNumber(args, "startRecord", 1)
+	if diagnostic == nil {
+		res.EchoedRequest.StartRecord = startRecord
+		if startRecord < 1 {
+			diagnostic = sru.NewDiagnostic(sru.DiagnosticUnsupportedParamValue, "startRecord", "startRecord counts from 1")
+		}
+	}
+	if diagnostic != nil {
+		res.Diagnostics = sru.NewDiagnostics(diagnostic)
+		return res, nil
+	}
+
+	maximumRecords, diagnostic := sruNumber(args, "maximumRecords", sruDefaultMaximumRecords)
+	if diagnostic == nil {
+		res.EchoedRequest.MaximumRecords = maximumRecords
+		if maximumRecords < 0 {
+			diagnostic = sru.NewDiagnostic(sru.DiagnosticUnsupportedParamValue, "maximumRecords", "maximumRecords cannot be negative")
+		}
+	}
+	if diagnostic != nil {
+		res.Diagnostics = sru.NewDiagnostics(diagnostic)
+		return res, nil
+	}
+	maximumRecords = min(maximumRecords, sruMaxMaximumRecords)
+
+	schema := findSruSchema(args.Get("recordSchema"))
+	if schema == nil {
+		res.Diagnostics = sru.NewDiagnostics(sru.NewDiagnostic(sru.DiagnosticUnsupportedRecordSchema, args.Get("recordSchema"), "records come in dc or marcxml"))
+		return res, nil
+	}
+
+	where, err := bookIndexes.Compile(args.Get("query"))
+	var queryErr *cql.Error
+	if errors.As(err, &queryErr) {
+		res.Diagnostics = sru.NewDiagnostics(sru.NewDiagnostic(queryErr.Code, queryErr.Details, queryErr.Message))
+		return res, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	total, err := api.searchService.CountBooks(ctx, where)
+	if err != nil {
+		return nil, err
+	}
+	res.NumberOfRecords = total
+
+	if maximumRecords == 0 || total == 0 {
+		return res, nil
+	}
+
+	if int64(startRecord) > total {
+		res.Diagnostics = sru.NewDiagnostics(sru.NewDiagnostic(sru.DiagnosticFirstRecordOutOfRange, strconv.Itoa(startRecord), fmt.Sprintf("there are %d records", total)))
+		return res, nil
+	}
+
+	books, err := api.searchService.FindBooks(ctx, where, startRecord-1, maximumRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Records = &sru.Records{}
+	for idx, book := range books {
+		res.Records.Records = append(res.Records.Records, &sru.Record{
+			RecordSchema:   schema.Identifier,
+			RecordPacking:  packing,
+			RecordData:     packRecord(schema.render(book), packing),
+			RecordPosition: int64(startRecord + idx),
+		})
+	}
+
+	if next := int64(startRecord + len(books)); next <= total {
+		res.NextRecordPosition = next
+	}
+	return res, nil
+}
+
+// explain describes the server in ZeeRex: where it is reached, the indexes
+// queries can use and the schemas records come in.
+func (api *sruApi) explain(ctx *gin.Context) *sru.Explain {
+	location, _ := url.Parse(baseURL(ctx))
+	host := location.Hostname()
+	port, err := strconv.Atoi(location.Port())
+	if err != nil {
+		port = 80
+		if location.Scheme == "https" {
+			port = 443
+		}
+	}
+
+	explain := &sru.Explain{
+		Xmlns: sru.ExplainNamespace,
+		ServerInfo: &sru.ServerInfo{
+			Protocol: "SRU",
+			Version:  sru.Version,
+			Host:     host,
+			Port:     port,
+			Database: strings.TrimPrefix(ctx.Request.URL.Path, "/"),
+		},
+		DatabaseInfo: &sru.DatabaseInfo{
+			Title:   api.config.OaiConfig.RepositoryName,
+			Contact: api.config.OaiConfig.AdminEmail,
+		},
+		IndexInfo:  &sru.IndexInfo{Sets: sruIndexSets},
+		SchemaInfo: &sru.SchemaInfo{},
+		ConfigInfo: &sru.ConfigInfo{Settings: []*sru.Setting{
+			{XMLName: xml.Name{Local: "default"}, Type: "numberOfRecords", Value: strconv.Itoa(sruDefaultMaximumRecords)},
+			{XMLName: xml.Name{Local: "setting"}, Type: "maximumRecords", Value: strconv.Itoa(sruMaxMaximumRecords)},
+		}},
+	}
+
+	for _, index := range sruIndexes {
+		explain.IndexInfo.Indexes = append(explain.IndexInfo.Indexes, &sru.Index{
+			Title: index.title,
+			Map:   &sru.IndexName{Set: index.set, Name: index.name},
+		})
+	}
+
+	for _, schema := range sruSchemas {
+		explain.SchemaInfo.Schemas = append(explain.SchemaInfo.Schemas, &schema.Schema)
+	}
+	return explain
+}
+
+func (api *sruApi) renderDiagnostic(ctx *gin.Context, operation string, diagnostic *sru.Diagnostic) {
+	if operation == sru.OperationSearchRetrieve {
+		res := sru.NewSearchRetrieveResponse()
+		res.Diagnostics = sru.NewDiagnostics(diagnostic)
+		renderSru(ctx, res)
+		return
+	}
+
+	res := sru.NewExplainResponse()
+	res.Diagnostics = sru.NewDiagnostics(diagnostic)
+	renderSru(ctx, res)
+}
+
+// checkSruParameters refuses parameters the operation doesn't take and
+// repeated ones.
+func checkSruParameters(args url.Values, allowed map[string]bool) *sru.Diagnostic {
+	for name, values := range args {
+		if name == "operation" || name == "version" || strings.HasPrefix(name, "x-") {
+			continue
+		}
+
+		if !allowed[name] {
+			return sru.NewDiagnostic(sru.DiagnosticUnsupportedParameter, name, fmt.Sprintf("%s is not a parameter of the operation", name))
+		}
+
+		if len(values) != 1 {
+			return sru.NewDiagnostic(sru.DiagnosticUnsupportedParamValue, name, fmt.Sprintf("%s is repeated", name))
+		}
+	}
+	return nil
+}
+
+func sruNumber(args url.Values, name string, fallback int) (int, *sru.Diagnostic) {
+	value := args.Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, sru.NewDiagnostic(sru.DiagnosticUnsupportedParamValue, name, fmt.Sprintf("%s is not a number", name))
+	}
+	return number, nil
+}
+
+func findSruSchema(name string) *sruSchema {
+	if name == "" {
+		return sruSchemas[0]
+	}
+
+	for _, schema := range sruSchemas {
+		if schema.Name == name || schema.Identifier == name {
+			return schema
+		}
+	}
+	return nil
+}
+
+// packRecord puts a record in a response as XML or, packed as string, as the
+// escaped text of its XML.
+func packRecord(record any, packing string) *sru.RecordData {
+	if packing == sru.PackingXML {
+		return &sru.RecordData{Value: record}
+	}
+
+	data, err := xml.Marshal(record)
+	if err != nil {
+		fmt.Println("unable to pack sru record ", err)
+	}
+	return &sru.RecordData{Text: string(data)}
+}
+
+// normalizeIsbnTerm brings every ISBN of a term to the canonical ISBN-13
+// books are kept under, a masked one only loses its hyphens.
+func normalizeIsbnTerm(term string) string {
+	words := strings.Fields(term)
+	for idx, word := range words {
+		words[idx] = isbn.Normalize(word)
+	}
+	return strings.Join(words, " ")
+}
+
+func renderSru(ctx *gin.Context, res any) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(res); err != nil {
+		fmt.Println("unable to render sru response ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to answer the SRU request")))
+		return
+	}
+	ctx.Data(http.StatusOK, "text/xml; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+)
+
+type subjectsApi struct {
+	config             *config.AppConfig
+	subjectService     service.SubjectService
+	contributorService service.ContributorService
+}
+
+func NewSubjectsApi(config *config.AppConfig, subjectService service.SubjectService, contributorService service.ContributorService) *subjectsApi {
+	return &subjectsApi{
+		config,
+		subjectService,
+		contributorService,
+	}
+}
+
+type addSubjectRequestBody struct {
+	ParentId *uint64 `json:"parent_id" binding:"omitempty,min=1"`
+	Name     string  `json:"name" binding:"required,gt=1"`
+	Kind     string  `json:"kind" binding:"omitempty,oneof=topic genre"`
+	Dewey    string  `json:"dewey" binding:"max=32"`
+	Lcc      string  `json:"lcc" binding:"max=32"`
+}
+
+type getSubjectRequestBody struct {
+	ID uint64 `uri:"id" binding:"required,min=1"`
+}
+
+type getSubjectBooksRequestBody struct {
+	LastId   uint64 `form:"last_id"`
+	PageSize int    `form:"page_size"`
+}
+
+type getSubjectsResponseBody struct {
+	Subjects []*model.Subject `json:"subjects"`
+}
+
+type getSubjectResponseBody struct {
+	*model.Subject
+	Path []*model.Subject `json:"path"`
+}
+
+type getTagsResponseBody struct {
+	Tags []*model.TagCount `json:"tags"`
+}
+
+// AddSubject godoc
+// @Summary endpoint to create a subject
+// @Description add a topic or genre to the classification tree, under parent_id or as a root, with optional Dewey and LCC class numbers
+// @Tags subject
+// @Accept json
+// @Produce json
+// @Param subject body addSubjectRequestBody true "Subject data"
+// @Success 201 {object} model.Subject
+// @Router /v1/subjects [post]
+func (api *subjectsApi) AddSubject(ctx *gin.Context) {
+	var req addSubjectRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if req.ParentId != nil {
+		if _, err := api.subjectService.GetSubject(ctx, *req.ParentId); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("unable to locate parent subject with Id %d", *req.ParentId)))
+			return
+		}
+	}
+
+	subject := &model.Subject{
+		ParentId: req.ParentId,
+		Name:     req.Name,
+		Kind:     subjectKind(req.Kind),
+		Dewey:    req.Dewey,
+		Lcc:      req.Lcc,
+	}
+
+	if err := api.subjectService.AddSubject(ctx, subject); err != nil {
+		fmt.Println("unable to add subject ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to add subject to library")))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, subject)
+}
+
+// GetSubjects godoc
+// @Summary endpoint to browse the subjects
+// @Description get the whole classification tree, every root subject with its children down to the leaves
+// @Tags subject
+// @Produce json
+// @Success 200 {object} getSubjectsResponseBody
+// @Router /v1/subjects [get]
+func (api *subjectsApi) GetSubjects(ctx *gin.Context) {
+	subjects, err := api.subjectService.GetSubjectTree(ctx, 0)
+	if err != nil {
+		fmt.Println("not able to find any subjects", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any subjects")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getSubjectsResponseBody{Subjects: subjects})
+}
+
+// GetSubject godoc
+// @Summary endpoint to get a subject
+// @Description get a subject with its children down to the leaves and the path of subjects from the root down to it
+// @Tags subject
+// @Produce json
+// @param id path integer true "subject id"
+// @Success 200 {object} getSubjectResponseBody
+// @Router /v1/subjects/{id} [get]
+func (api *subjectsApi) GetSubject(ctx *gin.Context) {
+	var req getSubjectRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	subject, err := api.subjectService.GetSubject(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate subject with Id %d", req.ID)))
+		return
+	}
+
+	subject.Children, err = api.subjectService.GetSubjectTree(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to locate subject with Id %d", req.ID)))
+		return
+	}
+
+	path, err := api.subjectService.GetSubjectPath(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to locate subject with Id %d", req.ID)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getSubjectResponseBody{Subject: subject, Path: path})
+}
+
+// UpdateSubject godoc
+// @Summary endpoint to update a subject
+// @Description rename, reclassify or move a subject, with its descendants, under another parent
+// @Tags subject
+// @Accept json
+// @Produce json
+// @param id path integer true "subject id"
+// @Param subject body addSubjectRequestBody true "Subject data"
+// @Success 200 {object} model.Subject
+// @Router /v1/subjects/{id} [put]
+func (api *subjectsApi) UpdateSubject(ctx *gin.Context) {
+	var uri getSubjectRequestBody
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req addSubjectRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	subject, err := api.subjectService.GetSubject(ctx, uri.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate subject with Id %d", uri.ID)))
+		return
+	}
+
+	if req.ParentId != nil {
+		// the new parent can't be the subject or one of its descendants
+		path, err := api.subjectService.GetSubjectPath(ctx, *req.ParentId)
+		if err != nil || len(path) == 0 {
+			ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("unable to locate parent subject with Id %d", *req.ParentId)))
+			return
+		}
+
+		for _, ancestor := range path {
+			if ancestor.Id == subject.Id {
+				ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("a subject cannot be moved under itself or its descendants")))
+				return
+			}
+		}
+	}
+
+	subject.ParentId = req.ParentId
+	subject.Name = req.Name
+	subject.Kind = subjectKind(req.Kind)
+	subject.Dewey = req.Dewey
+	subject.Lcc = req.Lcc
+
+	if err := api.subjectService.UpdateSubject(ctx, subject); err != nil {
+		fmt.Println("unable to update subject ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to update subject %d", uri.ID)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, subject)
+}
+
+// DeleteSubject godoc
+// @Summary endpoint to delete a subject
+// @Description delete a leaf subject no book is classed under
+// @Tags subject
+// @param id path integer true "subject id"
+// @Success 200
+// @Router /v1/subjects/{id} [delete]
+func (api *subjectsApi) DeleteSubject(ctx *gin.Context) {
+	var req getSubjectRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, err := api.subjectService.GetSubject(ctx, req.ID); err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate subject with Id %d", req.ID)))
+		return
+	}
+
+	children, err := api.subjectService.GetSubjectTree(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to delete subject %d", req.ID)))
+		return
+	}
+
+	if len(children) > 0 {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("subject has children, move or delete them first")))
+		return
+	}
+
+	books, err := api.subjectService.GetSubjectBooks(ctx, req.ID, 0, 1)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to delete subject %d", req.ID)))
+		return
+	}
+
+	if len(books) > 0 {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("books are classed under the subject, reclassify them first")))
+		return
+	}
+
+	if err := api.subjectService.DeleteSubject(ctx, req.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to delete subject %d", req.ID)))
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// GetSubjectBooks godoc
+// @Summary endpoint to list the books under a subject
+// @Description get the books classed under the subject or any of its descendants, in id order
+// @Tags subject
+// @Produce json
+// @param id path integer true "subject id"
+// @Param last_id query integer false "id of the last book of the previous page"
+// @Param page_size query integer false "books per page, 10 by default and at most 100"
+// @Success 200 {object} getBooksResponseBody
+// @Router /v1/subjects/{id}/books [get]
+func (api *subjectsApi) GetSubjectBooks(ctx *gin.Context) {
+	var uri getSubjectRequestBody
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req getSubjectBooksRequestBody
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, err := api.subjectService.GetSubject(ctx, uri.ID); err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate subject with Id %d", uri.ID)))
+		return
+	}
+
+	pageSize := 10
+
+	if req.PageSize > 0 && req.PageSize <= 100 {
+		pageSize = req.PageSize
+	}
+
+	books, err := api.subjectService.GetSubjectBooks(ctx, uri.ID, req.LastId, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any books")))
+		return
+	}
+
+	if err := api.contributorService.LoadContributors(ctx, books); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any books")))
+		return
+	}
+
+	if err := api.subjectService.LoadClassification(ctx, books); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any books")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getBooksResponseBody{Books: books})
+}
+
+// GetTags godoc
+// @Summary endpoint to list tags
+// @Description get the tags books carry with the number of books for each, the most used first
+// @Tags subject
+// @Produce json
+// @Success 200 {object} getTagsResponseBody
+// @Router /v1/tags [get]
+func (api *subjectsApi) GetTags(ctx *gin.Context) {
+	tags, err := api.subjectService.GetTags(ctx)
+	if err != nil {
+		fmt.Println("not able to find any tags", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any tags")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getTagsResponseBody{Tags: tags})
+}
+
+func subjectKind(requested string) string {
+	if requested == "" {
+		return model.SubjectKindTopic
+	}
+	return requested
+}
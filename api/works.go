@@ -0,0 +1,216 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	service "github.com/dutt23/lms/services"
+	"github.com/gin-gonic/gin"
+)
+
+type worksApi struct {
+	config      *config.AppConfig
+	workService service.WorkService
+}
+
+func NewWorksApi(config *config.AppConfig, workService service.WorkService) *worksApi {
+	return &worksApi{
+		config,
+		workService,
+	}
+}
+
+type addWorkRequestBody struct {
+	Title  string `json:"title" binding:"required,gt=1"`
+	Author string `json:"author"`
+}
+
+type getWorkRequestBody struct {
+	ID uint64 `uri:"id" binding:"required,min=1"`
+}
+
+type getWorksRequestBody struct {
+	Title    string `form:"title"`
+	LastId   uint64 `form:"last_id"`
+	PageSize int    `form:"page_size"`
+}
+
+type getWorksResponseBody struct {
+	Works []*model.Work `json:"works"`
+}
+
+// AddWork godoc
+// @Summary endpoint to create a work
+// @Description add a work that editions and translations of the same title can be grouped under
+// @Tags work
+// @Accept json
+// @Produce json
+// @Param work body addWorkRequestBody true "Work data"
+// @Success 201 {object} model.Work
+// @Router /v1/works [post]
+func (api *worksApi) AddWork(ctx *gin.Context) {
+	var req addWorkRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	work := &model.Work{Title: req.Title, Author: req.Author}
+	if err := api.workService.AddWork(ctx, work); err != nil {
+		fmt.Println("unable to add work ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to add work to library")))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, work)
+}
+
+// GetWorks godoc
+// @Summary endpoint to list works
+// @Description get the works in id order, those whose title contains title when it is given
+// @Tags work
+// @Produce json
+// @Param title query string false "part of the title"
+// @Param last_id query integer false "id of the last work of the previous page"
+// @Param page_size query integer false "works per page, 10 by default and at most 100"
+// @Success 200 {object} getWorksResponseBody
+// @Router /v1/works [get]
+func (api *worksApi) GetWorks(ctx *gin.Context) {
+	var req getWorksRequestBody
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	pageSize := 10
+
+	if req.PageSize > 0 && req.PageSize <= 100 {
+		pageSize = req.PageSize
+	}
+
+	works, err := api.workService.GetWorks(ctx, req.Title, req.LastId, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find any works")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, getWorksResponseBody{Works: works})
+}
+
+// GetWork godoc
+// @Summary endpoint to get a work
+// @Description get a work with its editions, oldest first
+// @Tags work
+// @Produce json
+// @param id path integer true "work id"
+// @Success 200 {object} model.Work
+// @Router /v1/works/{id} [get]
+func (api *worksApi) GetWork(ctx *gin.Context) {
+	var req getWorkRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	work, err := api.workService.GetWork(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate work with Id %d", req.ID)))
+		return
+	}
+
+	work.Editions, err = api.workService.GetEditions(ctx, req.ID)
+	if err != nil {
+		fmt.Println("unable to find the editions of work ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("Unable to find the editions of the work")))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, work)
+}
+
+// UpdateWork godoc
+// @Summary endpoint to update a work
+// @Description change the title or author of a work, its editions keep their own
+// @Tags work
+// @Accept json
+// @Produce json
+// @param id path integer true "work id"
+// @Param work body addWorkRequestBody true "Work data"
+// @Success 200 {object} model.Work
+// @Router /v1/works/{id} [put]
+func (api *worksApi) UpdateWork(ctx *gin.Context) {
+	var uri getWorkRequestBody
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req addWorkRequestBody
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	work, err := api.workService.GetWork(ctx, uri.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate work with Id %d", uri.ID)))
+		return
+	}
+
+	work.Title = req.Title
+	work.Author = req.Author
+	if err := api.workService.UpdateWork(ctx, work); err != nil {
+		fmt.Println("unable to update work ", err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to update work %d", uri.ID)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, work)
+}
+
+// DeleteWork godoc
+// @Summary endpoint to delete a work
+// @Description delete a work no book is an edition of anymore
+// @Tags work
+// @param id path integer true "work id"
+// @Success 200
+// @Router /v1/works/{id} [delete]
+func (api *worksApi) DeleteWork(ctx *gin.Context) {
+	var req getWorkRequestBody
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, err := api.workService.GetWork(ctx, req.ID); err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("unable to locate work with Id %d", req.ID)))
+		return
+	}
+
+	editions, err := api.workService.GetEditions(ctx, req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to delete work %d", req.ID)))
+		return
+	}
+
+	if len(editions) > 0 {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("work has %d editions, move or delete them first", len(editions))))
+		return
+	}
+
+	if err := api.workService.DeleteWork(ctx, req.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(fmt.Errorf("unable to delete work %d", req.ID)))
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
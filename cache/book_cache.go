@@ -20,6 +20,8 @@ type BookCache interface {
 	DeleteBook(c context.Context, bookId uint64) error
 	IsIsbnUnique(c context.Context, isbn string) bool
 	GetBookAnalytics(c context.Context, bookIds []uint64) (*BookAnalytics, error)
+	StoreBookMetadata(c context.Context, metadata *model.BookMetadata) error
+	GetBookMetadata(c context.Context, isbn string) (*model.BookMetadata, error)
 }
 
 type bookCache struct {
@@ -107,6 +109,34 @@ func (cache *bookCache) GetBook(c context.Context, bookId uint64) (*model.Book,
 	return book, nil
 }
 
+// StoreBookMetadata keeps what a provider found for an ISBN. Editions rarely
+// change, so entries live for a day.
+func (cache *bookCache) StoreBookMetadata(c context.Context, metadata *model.BookMetadata) error {
+	metadataKey := CacheKey(c, "SET_BOOK_METADATA", metadata.Isbn)
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	metadataExpiryTime := 24 * time.Hour
+	jitter := time.Duration(rand.Int63n(int64(metadataExpiryTime)))
+	return cache.conn.DB(c).Set(c, metadataKey, data, metadataExpiryTime+jitter/2).Err()
+}
+
+func (cache *bookCache) GetBookMetadata(c context.Context, isbn string) (*model.BookMetadata, error) {
+	metadataKey := CacheKey(c, "SET_BOOK_METADATA", isbn)
+	res, err := cache.conn.DB(c).Get(c, metadataKey).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &model.BookMetadata{}
+	if err := json.Unmarshal(res, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
 func (cache *bookCache) GetBookAnalytics(c context.Context, bookIds []uint64) (*BookAnalytics, error) {
 	db := cache.conn.DB(c)
 	pipe := db.Pipeline()
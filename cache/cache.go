@@ -25,6 +25,15 @@ type BookFreq struct {
 	Count uint64 `json:"count"`
 }
 
+// WorkAnalytics rolls the book analytics of editions up to their works.
+type WorkAnalytics struct {
+	Analytics map[string]*WorkAnalytic `json:"work_analytics"`
+}
+
+type WorkAnalytic struct {
+	WorkFrequency []*BookFreq `json:"work_frequency"`
+}
+
 type MemberAnalytics struct {
 	Analytics map[string]*MemberAnalytic `json:"member_analytics"`
 }
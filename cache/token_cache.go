@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/google/uuid"
+)
+
+// TokenCache holds the ids of the tokens revoked before they expire, each
+// only for as long as its token would still be valid.
+type TokenCache interface {
+	RevokeToken(c context.Context, tokenId uuid.UUID, ttl time.Duration) error
+	IsTokenRevoked(c context.Context, tokenId uuid.UUID) (bool, error)
+}
+
+type tokenCache struct {
+	conn connectors.CacheConnector
+}
+
+func NewTokenCache(client connectors.CacheConnector) TokenCache {
+	return &tokenCache{conn: client}
+}
+
+func (cache *tokenCache) RevokeToken(c context.Context, tokenId uuid.UUID, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	db := cache.conn.DB(c)
+	tokenKey := CacheKey(c, "REVOKED_TOKEN", tokenId.String())
+	return db.Set(c, tokenKey, 1, ttl).Err()
+}
+
+func (cache *tokenCache) IsTokenRevoked(c context.Context, tokenId uuid.UUID) (bool, error) {
+	db := cache.conn.DB(c)
+	tokenKey := CacheKey(c, "REVOKED_TOKEN", tokenId.String())
+	res, err := db.Exists(c, tokenKey).Result()
+	if err != nil {
+		return false, err
+	}
+	return res > 0, nil
+}
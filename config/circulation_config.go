@@ -0,0 +1,14 @@
+package config
+
+// CirculationConfig is the lending policy used when no row of the
+// circulation_policies table matches a member type and item type. MaxBalance
+// is the outstanding fines above which a member cannot borrow.
+type CirculationConfig struct {
+	LoanPeriodDays int64 `mapstructure:"loan_period_days" validate:"required"`
+	MaxLoans       int64 `mapstructure:"max_loans" validate:"required"`
+	MaxRenewals    int64 `mapstructure:"max_renewals"`
+	GraceDays      int64 `mapstructure:"grace_days"`
+	DailyFine      int64 `mapstructure:"daily_fine"`
+	MaxFine        int64 `mapstructure:"max_fine"`
+	MaxBalance     int64 `mapstructure:"max_balance"`
+}
@@ -20,10 +20,29 @@ type AppConfig struct {
 	LogLevel          string      `mapstructure:"log_level" validate:"required"`
 	DbConfig          DBConfig    `mapstructure:"db" validate:"required"`
 	CacheConfig       CacheConfig `mapstructure:"cache" validate:"required"`
+	CirculationConfig CirculationConfig `mapstructure:"circulation" validate:"required"`
+	OaiConfig         OaiConfig   `mapstructure:"oai"`
+	MetadataConfig    MetadataConfig `mapstructure:"metadata"`
+	MailConfig        MailConfig  `mapstructure:"mail"`
 	TokenSymmetricKey string      `mapstructure:"token_symmetric_key" validate:"required"`
 	QueuePort         int         `mapstructure:"queue_port" validate:"required"`
 	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
 	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	HoldPickupDuration   time.Duration `mapstructure:"HOLD_PICKUP_DURATION"`
+	ImportDir            string        `mapstructure:"IMPORT_DIR"`
+	// AdminEmail always logs in as an admin, to hand out the librarian and
+	// admin roles to the others
+	AdminEmail           string        `mapstructure:"ADMIN_EMAIL"`
+	// failed logins from a client IP within LoginLockoutDuration that hold its
+	// logins off until the duration passes, none when 0
+	LoginMaxAttempts     int           `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginLockoutDuration time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	// comma separated proxies whose X-Forwarded-For is taken as the client IP,
+	// none by default so the login throttle cannot be dodged with the header
+	TrustedProxies       []string      `mapstructure:"TRUSTED_PROXIES"`
+	// how long the links mailed to verify an email or reset a password work
+	EmailVerificationDuration time.Duration `mapstructure:"EMAIL_VERIFICATION_DURATION"`
+	PasswordResetDuration     time.Duration `mapstructure:"PASSWORD_RESET_DURATION"`
 }
 
 // reading config and intializing configs for application
@@ -59,6 +78,14 @@ func setDefault(v *viper.Viper) {
 	v.SetDefault("HOST", "localhost")
 	v.SetDefault("PORT", "")
 	v.SetDefault("LOG_LEVEL", "debug")
+	v.SetDefault("HOLD_PICKUP_DURATION", "72h")
+	v.SetDefault("IMPORT_DIR", "./imports")
+	v.SetDefault("ADMIN_EMAIL", "")
+	v.SetDefault("LOGIN_MAX_ATTEMPTS", 5)
+	v.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	v.SetDefault("TRUSTED_PROXIES", "")
+	v.SetDefault("EMAIL_VERIFICATION_DURATION", "24h")
+	v.SetDefault("PASSWORD_RESET_DURATION", "30m")
 	//
 
 	v.SetDefault("DB__HOST", "")
@@ -69,6 +96,33 @@ func setDefault(v *viper.Viper) {
 	v.SetDefault("DB__MAX_OPEN_CONNECTION", 10)
 	v.SetDefault("DB__MAX_IDEAL_CONNECTION", 10)
 	v.SetDefault("DB__SSL_MODE", "disable")
+	//
+
+	v.SetDefault("CIRCULATION__LOAN_PERIOD_DAYS", 14)
+	v.SetDefault("CIRCULATION__MAX_LOANS", 5)
+	v.SetDefault("CIRCULATION__MAX_RENEWALS", 2)
+	v.SetDefault("CIRCULATION__GRACE_DAYS", 0)
+	v.SetDefault("CIRCULATION__DAILY_FINE", 25)
+	v.SetDefault("CIRCULATION__MAX_FINE", 1000)
+	v.SetDefault("CIRCULATION__MAX_BALANCE", 500)
+	//
+
+	v.SetDefault("OAI__REPOSITORY_NAME", "Library catalogue")
+	v.SetDefault("OAI__REPOSITORY_IDENTIFIER", "lms.local")
+	v.SetDefault("OAI__ADMIN_EMAIL", "admin@lms.local")
+	//
+
+	v.SetDefault("METADATA__DUMP_PATH", "")
+	v.SetDefault("METADATA__HTTP_URL", "")
+	v.SetDefault("METADATA__TIMEOUT", "5s")
+	//
+
+	v.SetDefault("MAIL__HOST", "localhost")
+	v.SetDefault("MAIL__PORT", 1025)
+	v.SetDefault("MAIL__USERNAME", "")
+	v.SetDefault("MAIL__PASSWORD", "")
+	v.SetDefault("MAIL__FROM", "library@lms.local")
+	v.SetDefault("MAIL__LINK_BASE_URL", "http://localhost:9001")
 }
 
 // Getting application config from viper
@@ -0,0 +1,14 @@
+package config
+
+// MailConfig is the SMTP server the mails to members go out through, which can
+// be a local stand-in such as MailHog. Username is left empty for a server
+// taking mail without authentication. The links in mails open LinkBaseURL,
+// the site that posts the token in them back to the API.
+type MailConfig struct {
+	Host        string `mapstructure:"host"`
+	Port        int    `mapstructure:"port"`
+	Username    string `mapstructure:"username"`
+	Password    string `mapstructure:"password"`
+	From        string `mapstructure:"from"`
+	LinkBaseURL string `mapstructure:"link_base_url"`
+}
@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// MetadataConfig points at the sources a book entered by ISBN is filled in
+// from. The Open Library dump is consulted first, then the HTTP API, which can
+// be https://openlibrary.org or a local stub. A source left empty is skipped.
+type MetadataConfig struct {
+	DumpPath string        `mapstructure:"dump_path"`
+	HttpURL  string        `mapstructure:"http_url"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
@@ -0,0 +1,10 @@
+package config
+
+// OaiConfig describes the repository to OAI-PMH harvesters. Record identifiers
+// are oai:<repository_identifier>:<book id>, so the identifier should be a
+// domain name the library controls and must not change once harvested.
+type OaiConfig struct {
+	RepositoryName       string `mapstructure:"repository_name"`
+	RepositoryIdentifier string `mapstructure:"repository_identifier"`
+	AdminEmail           string `mapstructure:"admin_email"`
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "book_loans_item_id_idx";
+ALTER TABLE "book_loans" DROP COLUMN "item_id";
+DROP TABLE IF EXISTS items;
@@ -0,0 +1,43 @@
+CREATE TABLE "items" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "barcode" varchar UNIQUE NOT NULL,
+  "book_id" bigint NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'available',
+  "shelf_location" varchar,
+  "acquisition_date" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "condition" varchar NOT NULL DEFAULT 'good',
+  FOREIGN KEY ("book_id") REFERENCES "books" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "items_book_id_idx" ON "items" ("book_id");
+CREATE INDEX "items_status_idx" ON "items" ("status");
+
+ALTER TABLE "book_loans" ADD COLUMN "item_id" bigint;
+CREATE INDEX "book_loans_item_id_idx" ON "book_loans" ("item_id");
+
+-- A member can borrow the same title more than once, one copy at a time.
+DROP INDEX IF EXISTS "book_loans_member_id_book_id_uq_idx";
+
+-- Every existing copy counted in available_copies becomes a barcoded item.
+WITH RECURSIVE "copies" ("n") AS (
+  SELECT 1
+  UNION ALL
+  SELECT "n" + 1 FROM "copies" WHERE "n" < (SELECT MAX("available_copies") FROM "books")
+)
+INSERT INTO "items" ("barcode", "book_id", "status", "acquisition_date", "condition")
+SELECT printf('%s-%03d', "books"."isbn", "copies"."n"), "books"."id", 'available', CURRENT_TIMESTAMP, 'good'
+FROM "books" JOIN "copies" ON "copies"."n" <= "books"."available_copies";
+
+-- Loans still open lent a copy that available_copies no longer counted, so it
+-- becomes an item on loan for the loan to be returned with.
+INSERT INTO "items" ("barcode", "book_id", "status", "acquisition_date", "condition")
+SELECT printf('%s-L%d', "books"."isbn", "book_loans"."id"), "books"."id", 'on_loan', CURRENT_TIMESTAMP, 'good'
+FROM "book_loans" JOIN "books" ON "books"."id" = "book_loans"."book_id"
+WHERE "book_loans"."return_date" IS NULL;
+
+UPDATE "book_loans" SET "item_id" = (
+  SELECT "items"."id" FROM "items" JOIN "books" ON "books"."id" = "items"."book_id"
+  WHERE "items"."book_id" = "book_loans"."book_id"
+    AND "items"."barcode" = printf('%s-L%d', "books"."isbn", "book_loans"."id")
+)
+WHERE "return_date" IS NULL;
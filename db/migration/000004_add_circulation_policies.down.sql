@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "book_loans_due_date_idx";
+ALTER TABLE "book_loans" DROP COLUMN "due_date";
+ALTER TABLE "items" DROP COLUMN "item_type";
+ALTER TABLE "members" DROP COLUMN "member_type";
+DROP TABLE IF EXISTS circulation_policies;
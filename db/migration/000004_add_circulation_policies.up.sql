@@ -0,0 +1,18 @@
+CREATE TABLE "circulation_policies" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "member_type" varchar NOT NULL DEFAULT '*',
+  "item_type" varchar NOT NULL DEFAULT '*',
+  "loan_period_days" bigint NOT NULL CHECK ("loan_period_days" > 0),
+  "max_loans" bigint NOT NULL CHECK ("max_loans" >= 0),
+  "max_renewals" bigint NOT NULL DEFAULT 0 CHECK ("max_renewals" >= 0),
+  "grace_days" bigint NOT NULL DEFAULT 0 CHECK ("grace_days" >= 0)
+);
+
+CREATE UNIQUE INDEX "circulation_policies_member_type_item_type_uq_idx" ON "circulation_policies" ("member_type", "item_type");
+
+ALTER TABLE "members" ADD COLUMN "member_type" varchar NOT NULL DEFAULT 'standard';
+ALTER TABLE "items" ADD COLUMN "item_type" varchar NOT NULL DEFAULT 'book';
+
+ALTER TABLE "book_loans" ADD COLUMN "due_date" timestamp;
+UPDATE "book_loans" SET "due_date" = datetime("loan_date", '+14 days') WHERE "due_date" IS NULL;
+CREATE INDEX "book_loans_due_date_idx" ON "book_loans" ("due_date");
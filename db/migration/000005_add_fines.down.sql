@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS fines;
+ALTER TABLE "circulation_policies" DROP COLUMN "max_fine";
+ALTER TABLE "circulation_policies" DROP COLUMN "daily_fine";
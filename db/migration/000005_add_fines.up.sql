@@ -0,0 +1,18 @@
+ALTER TABLE "circulation_policies" ADD COLUMN "daily_fine" bigint NOT NULL DEFAULT 0 CHECK ("daily_fine" >= 0);
+ALTER TABLE "circulation_policies" ADD COLUMN "max_fine" bigint NOT NULL DEFAULT 0 CHECK ("max_fine" >= 0);
+
+CREATE TABLE "fines" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "member_id" bigint NOT NULL,
+  "loan_id" bigint,
+  "kind" varchar NOT NULL,
+  "amount" bigint NOT NULL,
+  "accrued_on" varchar NOT NULL,
+  "created_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY ("member_id") REFERENCES "members" ("id"),
+  FOREIGN KEY ("loan_id") REFERENCES "book_loans" ("id") ON DELETE SET NULL
+);
+
+CREATE INDEX "fines_member_id_idx" ON "fines" ("member_id");
+-- a loan is charged at most once per day, however often the job runs
+CREATE UNIQUE INDEX "fines_loan_id_kind_accrued_on_uq_idx" ON "fines" ("loan_id", "kind", "accrued_on") WHERE "kind" = 'overdue';
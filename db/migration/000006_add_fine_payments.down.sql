@@ -0,0 +1,4 @@
+ALTER TABLE "fines" DROP COLUMN "note";
+ALTER TABLE "fines" DROP COLUMN "reason_code";
+ALTER TABLE "fines" DROP COLUMN "reference";
+ALTER TABLE "fines" DROP COLUMN "method";
@@ -0,0 +1,4 @@
+ALTER TABLE "fines" ADD COLUMN "method" varchar;
+ALTER TABLE "fines" ADD COLUMN "reference" varchar;
+ALTER TABLE "fines" ADD COLUMN "reason_code" varchar;
+ALTER TABLE "fines" ADD COLUMN "note" varchar;
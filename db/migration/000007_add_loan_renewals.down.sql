@@ -0,0 +1 @@
+ALTER TABLE "book_loans" DROP COLUMN "renewal_count";
@@ -0,0 +1 @@
+ALTER TABLE "book_loans" ADD COLUMN "renewal_count" bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS "books_fts_au";
+DROP TRIGGER IF EXISTS "books_fts_ad";
+DROP TRIGGER IF EXISTS "books_fts_ai";
+DROP TABLE IF EXISTS "books_fts";
+ALTER TABLE "books" DROP COLUMN "subjects";
@@ -0,0 +1,34 @@
+ALTER TABLE "books" ADD COLUMN "subjects" varchar NOT NULL DEFAULT '';
+
+-- External content table over books, requires sqlite built with FTS5
+-- (go build -tags sqlite_fts5).
+CREATE VIRTUAL TABLE "books_fts" USING fts5(
+  "title",
+  "author",
+  "isbn",
+  "language",
+  "subjects",
+  content = 'books',
+  content_rowid = 'id',
+  tokenize = 'unicode61 remove_diacritics 2'
+);
+
+INSERT INTO "books_fts" ("rowid", "title", "author", "isbn", "language", "subjects")
+SELECT "id", "title", "author", "isbn", "language", "subjects" FROM "books";
+
+CREATE TRIGGER "books_fts_ai" AFTER INSERT ON "books" BEGIN
+  INSERT INTO "books_fts" ("rowid", "title", "author", "isbn", "language", "subjects")
+  VALUES (new."id", new."title", new."author", new."isbn", new."language", new."subjects");
+END;
+
+CREATE TRIGGER "books_fts_ad" AFTER DELETE ON "books" BEGIN
+  INSERT INTO "books_fts" ("books_fts", "rowid", "title", "author", "isbn", "language", "subjects")
+  VALUES ('delete', old."id", old."title", old."author", old."isbn", old."language", old."subjects");
+END;
+
+CREATE TRIGGER "books_fts_au" AFTER UPDATE OF "title", "author", "isbn", "language", "subjects" ON "books" BEGIN
+  INSERT INTO "books_fts" ("books_fts", "rowid", "title", "author", "isbn", "language", "subjects")
+  VALUES ('delete', old."id", old."title", old."author", old."isbn", old."language", old."subjects");
+  INSERT INTO "books_fts" ("rowid", "title", "author", "isbn", "language", "subjects")
+  VALUES (new."id", new."title", new."author", new."isbn", new."language", new."subjects");
+END;
@@ -0,0 +1,31 @@
+CREATE TABLE "imports" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "format" varchar NOT NULL,
+  "file_name" varchar NOT NULL,
+  "path" varchar NOT NULL,
+  "copies" bigint NOT NULL DEFAULT 1 CHECK ("copies" >= 0),
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "total" bigint NOT NULL DEFAULT 0,
+  "succeeded" bigint NOT NULL DEFAULT 0,
+  "failed" bigint NOT NULL DEFAULT 0,
+  "error" varchar NOT NULL DEFAULT '',
+  "created_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "finished_at" timestamp
+);
+
+CREATE TABLE "import_records" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "import_id" bigint NOT NULL,
+  "position" bigint NOT NULL,
+  "control_number" varchar NOT NULL DEFAULT '',
+  "status" varchar NOT NULL,
+  "book_id" bigint,
+  "isbn" varchar NOT NULL DEFAULT '',
+  "title" varchar NOT NULL DEFAULT '',
+  "error" varchar NOT NULL DEFAULT '',
+  FOREIGN KEY ("import_id") REFERENCES "imports" ("id") ON DELETE CASCADE,
+  FOREIGN KEY ("book_id") REFERENCES "books" ("id") ON DELETE SET NULL
+);
+
+-- a retried import job resumes after the last recorded position
+CREATE UNIQUE INDEX "import_records_import_id_position_uq_idx" ON "import_records" ("import_id", "position");
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "book_updated_at_idx";
+ALTER TABLE "books" DROP COLUMN "updated_at";
@@ -0,0 +1,5 @@
+-- the datestamp OAI-PMH harvesters select changed records by, kept in UTC
+ALTER TABLE "books" ADD COLUMN "updated_at" timestamp;
+UPDATE "books" SET "updated_at" = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
+
+CREATE INDEX "book_updated_at_idx" ON "books" ("updated_at");
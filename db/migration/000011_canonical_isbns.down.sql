@@ -0,0 +1,2 @@
+-- the form an ISBN was entered in is not kept, canonical ISBNs stay
+SELECT 1;
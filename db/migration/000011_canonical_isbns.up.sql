@@ -0,0 +1,34 @@
+-- books are kept under their ISBN-13 without hyphens, rows that would clash
+-- with a book already in that form, or with an older row taking the same form,
+-- are left for a librarian to merge
+UPDATE "books" SET "isbn" = upper(replace(replace("isbn", '-', ''), ' ', ''))
+WHERE "id" IN (
+    SELECT min("id") FROM "books"
+    WHERE "isbn" <> upper(replace(replace("isbn", '-', ''), ' ', ''))
+    GROUP BY upper(replace(replace("isbn", '-', ''), ' ', ''))
+  )
+  AND upper(replace(replace("isbn", '-', ''), ' ', '')) NOT IN (SELECT "isbn" FROM "books");
+
+-- valid ISBN-10s move under the 978 prefix with a new check digit
+WITH "isbn10s" AS (
+  SELECT "id", "isbn" FROM "books"
+  WHERE length("isbn") = 10
+    AND substr("isbn", 1, 9) NOT GLOB '*[^0-9]*'
+    AND substr("isbn", 10, 1) GLOB '[0-9X]'
+    AND (10 * substr("isbn", 1, 1) + 9 * substr("isbn", 2, 1) + 8 * substr("isbn", 3, 1)
+      + 7 * substr("isbn", 4, 1) + 6 * substr("isbn", 5, 1) + 5 * substr("isbn", 6, 1)
+      + 4 * substr("isbn", 7, 1) + 3 * substr("isbn", 8, 1) + 2 * substr("isbn", 9, 1)
+      + CASE substr("isbn", 10, 1) WHEN 'X' THEN 10 ELSE substr("isbn", 10, 1) END) % 11 = 0
+),
+"converted" AS (
+  SELECT "id", '978' || substr("isbn", 1, 9) || ((10 - (38
+    + 3 * substr("isbn", 1, 1) + substr("isbn", 2, 1) + 3 * substr("isbn", 3, 1)
+    + substr("isbn", 4, 1) + 3 * substr("isbn", 5, 1) + substr("isbn", 6, 1)
+    + 3 * substr("isbn", 7, 1) + substr("isbn", 8, 1) + 3 * substr("isbn", 9, 1)) % 10) % 10) AS "isbn"
+  FROM "isbn10s"
+)
+UPDATE "books" SET "isbn" = (SELECT "converted"."isbn" FROM "converted" WHERE "converted"."id" = "books"."id")
+WHERE "id" IN (
+  SELECT min("id") FROM "converted" WHERE "converted"."isbn" NOT IN (SELECT "isbn" FROM "books")
+  GROUP BY "converted"."isbn"
+);
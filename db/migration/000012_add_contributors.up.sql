@@ -0,0 +1,39 @@
+CREATE TABLE "contributors" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "name" varchar NOT NULL
+);
+
+CREATE INDEX "contributors_name_idx" ON "contributors" ("name");
+
+-- role is a MARC relator code, position orders the credits of a book
+CREATE TABLE "book_contributors" (
+  "book_id" bigint NOT NULL,
+  "contributor_id" bigint NOT NULL,
+  "role" varchar NOT NULL DEFAULT 'aut',
+  "position" integer NOT NULL DEFAULT 0,
+  PRIMARY KEY ("book_id", "contributor_id", "role"),
+  FOREIGN KEY ("book_id") REFERENCES "books" ("id") ON DELETE CASCADE,
+  FOREIGN KEY ("contributor_id") REFERENCES "contributors" ("id")
+);
+
+CREATE INDEX "book_contributors_contributor_id_idx" ON "book_contributors" ("contributor_id");
+
+-- Every author statement becomes author credits, one for each name it lists
+-- separated by ";". Books by the same name share the contributor.
+CREATE TEMP TABLE "author_names" AS
+WITH RECURSIVE "names" ("book_id", "position", "name", "rest") AS (
+  SELECT "id", -1, '', "author" || ';' FROM "books"
+  UNION ALL
+  SELECT "book_id", "position" + 1, trim(substr("rest", 1, instr("rest", ';') - 1)), substr("rest", instr("rest", ';') + 1)
+  FROM "names" WHERE "rest" <> ''
+)
+SELECT "book_id", "position", "name" FROM "names" WHERE "name" <> '';
+
+INSERT INTO "contributors" ("name")
+SELECT DISTINCT "name" FROM "author_names" ORDER BY "name";
+
+INSERT OR IGNORE INTO "book_contributors" ("book_id", "contributor_id", "role", "position")
+SELECT "author_names"."book_id", "contributors"."id", 'aut', "author_names"."position"
+FROM "author_names" JOIN "contributors" ON "contributors"."name" = "author_names"."name";
+
+DROP TABLE "author_names";
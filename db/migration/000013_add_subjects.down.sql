@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "book_tags";
+DROP TABLE IF EXISTS "tags";
+DROP TABLE IF EXISTS "book_subjects";
+DROP TABLE IF EXISTS "subjects";
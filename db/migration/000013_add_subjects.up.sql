@@ -0,0 +1,38 @@
+-- a subject heading or genre, placed in the tree under its parent
+CREATE TABLE "subjects" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "parent_id" bigint,
+  "name" varchar NOT NULL,
+  "kind" varchar NOT NULL DEFAULT 'topic',
+  "dewey" varchar NOT NULL DEFAULT '',
+  "lcc" varchar NOT NULL DEFAULT '',
+  FOREIGN KEY ("parent_id") REFERENCES "subjects" ("id")
+);
+
+CREATE INDEX "subjects_parent_id_idx" ON "subjects" ("parent_id");
+CREATE INDEX "subjects_name_idx" ON "subjects" ("name");
+
+CREATE TABLE "book_subjects" (
+  "book_id" bigint NOT NULL,
+  "subject_id" bigint NOT NULL,
+  PRIMARY KEY ("book_id", "subject_id"),
+  FOREIGN KEY ("book_id") REFERENCES "books" ("id") ON DELETE CASCADE,
+  FOREIGN KEY ("subject_id") REFERENCES "subjects" ("id")
+);
+
+CREATE INDEX "book_subjects_subject_id_idx" ON "book_subjects" ("subject_id");
+
+CREATE TABLE "tags" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "name" varchar UNIQUE NOT NULL
+);
+
+CREATE TABLE "book_tags" (
+  "book_id" bigint NOT NULL,
+  "tag_id" bigint NOT NULL,
+  PRIMARY KEY ("book_id", "tag_id"),
+  FOREIGN KEY ("book_id") REFERENCES "books" ("id") ON DELETE CASCADE,
+  FOREIGN KEY ("tag_id") REFERENCES "tags" ("id")
+);
+
+CREATE INDEX "book_tags_tag_id_idx" ON "book_tags" ("tag_id");
@@ -0,0 +1,30 @@
+-- holds on a work are dropped, they have no book to go back to
+CREATE TABLE "holds_books" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "book_id" bigint NOT NULL,
+  "member_id" bigint NOT NULL,
+  "item_id" bigint,
+  "status" varchar NOT NULL DEFAULT 'waiting',
+  "placed_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "ready_at" timestamp,
+  "expires_at" timestamp,
+  FOREIGN KEY ("book_id") REFERENCES "books" ("id"),
+  FOREIGN KEY ("member_id") REFERENCES "members" ("id"),
+  FOREIGN KEY ("item_id") REFERENCES "items" ("id")
+);
+
+INSERT INTO "holds_books" ("id", "book_id", "member_id", "item_id", "status", "placed_at", "ready_at", "expires_at")
+SELECT "id", "book_id", "member_id", "item_id", "status", "placed_at", "ready_at", "expires_at"
+FROM "holds" WHERE "book_id" IS NOT NULL;
+
+DROP TABLE "holds";
+ALTER TABLE "holds_books" RENAME TO "holds";
+
+CREATE INDEX "holds_book_id_status_idx" ON "holds" ("book_id", "status");
+CREATE INDEX "holds_member_id_idx" ON "holds" ("member_id");
+CREATE INDEX "holds_item_id_idx" ON "holds" ("item_id");
+CREATE INDEX "holds_expires_at_idx" ON "holds" ("expires_at");
+
+DROP INDEX IF EXISTS "books_work_id_idx";
+ALTER TABLE "books" DROP COLUMN "work_id";
+DROP TABLE IF EXISTS "works";
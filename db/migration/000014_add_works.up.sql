@@ -0,0 +1,52 @@
+-- a work groups the editions and translations of the same title
+CREATE TABLE "works" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "title" varchar NOT NULL,
+  "author" varchar NOT NULL DEFAULT ''
+);
+
+CREATE INDEX "works_title_idx" ON "works" ("title");
+
+ALTER TABLE "books" ADD COLUMN "work_id" bigint REFERENCES "works" ("id");
+
+-- books sharing a title, whatever its case, and an author statement are
+-- taken for editions of one work
+INSERT INTO "works" ("title", "author")
+SELECT "title", "author" FROM "books" GROUP BY lower("title"), "author" ORDER BY min("id");
+
+UPDATE "books" SET "work_id" = (
+  SELECT min("works"."id") FROM "works"
+  WHERE lower("works"."title") = lower("books"."title") AND "works"."author" = "books"."author"
+);
+
+CREATE INDEX "books_work_id_idx" ON "books" ("work_id");
+
+-- a hold on a work, book_id left null, is met by a copy of any edition
+CREATE TABLE "holds_editions" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "book_id" bigint,
+  "work_id" bigint NOT NULL,
+  "member_id" bigint NOT NULL,
+  "item_id" bigint,
+  "status" varchar NOT NULL DEFAULT 'waiting',
+  "placed_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "ready_at" timestamp,
+  "expires_at" timestamp,
+  FOREIGN KEY ("book_id") REFERENCES "books" ("id"),
+  FOREIGN KEY ("work_id") REFERENCES "works" ("id"),
+  FOREIGN KEY ("member_id") REFERENCES "members" ("id"),
+  FOREIGN KEY ("item_id") REFERENCES "items" ("id")
+);
+
+INSERT INTO "holds_editions" ("id", "book_id", "work_id", "member_id", "item_id", "status", "placed_at", "ready_at", "expires_at")
+SELECT "holds"."id", "holds"."book_id", coalesce("books"."work_id", 0), "holds"."member_id", "holds"."item_id", "holds"."status", "holds"."placed_at", "holds"."ready_at", "holds"."expires_at"
+FROM "holds" LEFT JOIN "books" ON "books"."id" = "holds"."book_id";
+
+DROP TABLE "holds";
+ALTER TABLE "holds_editions" RENAME TO "holds";
+
+CREATE INDEX "holds_book_id_status_idx" ON "holds" ("book_id", "status");
+CREATE INDEX "holds_work_id_status_idx" ON "holds" ("work_id", "status");
+CREATE INDEX "holds_member_id_idx" ON "holds" ("member_id");
+CREATE INDEX "holds_item_id_idx" ON "holds" ("item_id");
+CREATE INDEX "holds_expires_at_idx" ON "holds" ("expires_at");
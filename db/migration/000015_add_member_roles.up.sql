@@ -0,0 +1,2 @@
+-- member, librarian or admin, each role may do what the ones before it can
+ALTER TABLE "members" ADD COLUMN "role" varchar NOT NULL DEFAULT 'member';
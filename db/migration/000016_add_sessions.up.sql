@@ -0,0 +1,27 @@
+-- A session is one refresh token, id being the token's. Every renewal rotates
+-- it into a new session of the same family, the family id being the id of the
+-- session the login started. The access token issued with the refresh token is
+-- kept so a logout can revoke it before it expires.
+CREATE TABLE "sessions" (
+  "id" varchar PRIMARY KEY,
+  "family_id" varchar NOT NULL,
+  "member_id" bigint,
+  "username" varchar NOT NULL,
+  "user_agent" varchar NOT NULL DEFAULT '',
+  "client_ip" varchar NOT NULL DEFAULT '',
+  "is_revoked" boolean NOT NULL DEFAULT false,
+  "rotated_at" timestamp,
+  "access_token_id" varchar,
+  "access_expires_at" timestamp,
+  "expires_at" timestamp NOT NULL,
+  "created_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY ("member_id") REFERENCES "members" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "sessions_family_id_idx" ON "sessions" ("family_id");
+
+CREATE INDEX "sessions_member_id_idx" ON "sessions" ("member_id");
+
+CREATE INDEX "sessions_access_token_id_idx" ON "sessions" ("access_token_id");
+
+CREATE INDEX "sessions_username_idx" ON "sessions" ("username");
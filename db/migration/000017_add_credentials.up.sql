@@ -0,0 +1,7 @@
+-- the argon2id hashed password of a member
+CREATE TABLE "credentials" (
+  "member_id" INTEGER PRIMARY KEY,
+  "password_hash" varchar NOT NULL,
+  "updated_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY ("member_id") REFERENCES "members" ("id") ON DELETE CASCADE
+);
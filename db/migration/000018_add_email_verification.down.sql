@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "one_time_tokens";
+
+ALTER TABLE "members" DROP COLUMN "email_verified_at";
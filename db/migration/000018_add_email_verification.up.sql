@@ -0,0 +1,15 @@
+ALTER TABLE "members" ADD COLUMN "email_verified_at" timestamp;
+
+-- the tokens mailed to members to verify their email or reset their password,
+-- each good for one use. Mailing a new one uses up the ones before it.
+CREATE TABLE "one_time_tokens" (
+  "id" varchar PRIMARY KEY,
+  "member_id" bigint NOT NULL,
+  "purpose" varchar NOT NULL,
+  "expires_at" timestamp NOT NULL,
+  "used_at" timestamp,
+  "created_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY ("member_id") REFERENCES "members" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "one_time_tokens_member_id_purpose_idx" ON "one_time_tokens" ("member_id", "purpose");
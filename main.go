@@ -8,6 +8,7 @@ import (
 
 	"github.com/dutt23/lms/config"
 	_ "github.com/dutt23/lms/docs"
+	"github.com/dutt23/lms/pkg/mail"
 	"github.com/dutt23/lms/workers"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
@@ -107,6 +108,32 @@ func (app *AppRunner) startProcessors(config *config.AppConfig) {
 	if err := analyticsProcessor.Start(); err != nil {
 		fmt.Println("Unable to start analytics processor ", err)
 	}
+
+	opts := app.server.opts
+	holdsWorker := workers.NewHoldsExpiryWorker(app.server.DB, opts.holdService, opts.bookService)
+	finesWorker := workers.NewFinesOverdueWorker(opts.loanService, opts.memberService, opts.itemService, opts.policyService, opts.fineService)
+	maintenanceProcessor := workers.NewMaintenanceTaskProcessor(config, holdsWorker, finesWorker)
+	fmt.Println("starting maintenance processor")
+
+	if err := maintenanceProcessor.Start(); err != nil {
+		fmt.Println("Unable to start maintenance processor ", err)
+	}
+
+	marcWorker := workers.NewMarcImportWorker(app.server.DB, opts.importService, opts.bookService, opts.itemService)
+	importProcessor := workers.NewImportTaskProcessor(config, marcWorker)
+	fmt.Println("starting import processor")
+
+	if err := importProcessor.Start(); err != nil {
+		fmt.Println("Unable to start import processor ", err)
+	}
+
+	mailWorker := workers.NewMailWorker(mail.NewSMTPSender(&config.MailConfig))
+	mailProcessor := workers.NewMailTaskProcessor(config, mailWorker)
+	fmt.Println("starting mail processor")
+
+	if err := mailProcessor.Start(); err != nil {
+		fmt.Println("Unable to start mail processor ", err)
+	}
 }
 
 func runMigrations(migrationURL, dbSource string) {
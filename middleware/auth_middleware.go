@@ -2,9 +2,11 @@ package middleware
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/dutt23/lms/cache"
 	"github.com/dutt23/lms/token"
 	"github.com/gin-gonic/gin"
 )
@@ -15,7 +17,7 @@ const (
 	AuthPayloadKey         = "authotization_payload"
 )
 
-func AuthMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+func AuthMiddleware(tokenMaker token.Maker, tokenCache cache.TokenCache) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authHeader := ctx.GetHeader(AuthorizationHeaderKey)
 
@@ -27,7 +29,7 @@ func AuthMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 
 		fields := strings.Fields(authHeader)
 
-		if len(fields) > 2 {
+		if len(fields) != 2 {
 			err := errors.New("invalid auth format supplied")
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
 			return
@@ -42,10 +44,23 @@ func AuthMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		}
 
 		accessToken := fields[1]
-		payload, err := tokenMaker.Validate(accessToken)
+		// refresh tokens only renew the tokens at /tokens/renew
+		payload, err := tokenMaker.Validate(accessToken, token.KindAccess)
 
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+
+		// without the cache a logged out token stays good until it expires
+		revoked, err := tokenCache.IsTokenRevoked(ctx, payload.ID)
+		if err != nil {
+			fmt.Println("unable to check token revocation ", err)
+		}
+
+		if revoked {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("token has been revoked")))
+			return
 		}
 
 		ctx.Set(AuthPayloadKey, payload)
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginWindow counts the failed logins of a client IP since start.
+type loginWindow struct {
+	start    time.Time
+	failures int
+}
+
+// LoginThrottle refuses logins with a 429 once a client IP has failed
+// maxAttempts of them within the window, until the window passes. A login is
+// counted before it runs, so concurrent guesses cannot slip past the limit,
+// and handed back unless it is answered with a 401. Throttled logins are
+// refused before the password is hashed and alike for every email, telling
+// nothing about which are registered and locking no account out.
+func LoginThrottle(maxAttempts int, window time.Duration) gin.HandlerFunc {
+	var mu sync.Mutex
+	windows := map[string]*loginWindow{}
+
+	return func(ctx *gin.Context) {
+		if maxAttempts <= 0 {
+			ctx.Next()
+			return
+		}
+
+		ip := ctx.ClientIP()
+		now := time.Now()
+
+		mu.Lock()
+		for key, w := range windows {
+			if now.Sub(w.start) >= window {
+				delete(windows, key)
+			}
+		}
+		w, ok := windows[ip]
+		if !ok {
+			w = &loginWindow{start: now}
+			windows[ip] = w
+		}
+		if w.failures >= maxAttempts {
+			retryAfter := w.start.Add(window).Sub(now)
+			mu.Unlock()
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(errors.New("too many failed logins, try again later")))
+			return
+		}
+		w.failures++
+		mu.Unlock()
+
+		ctx.Next()
+
+		if ctx.Writer.Status() != http.StatusUnauthorized {
+			mu.Lock()
+			w.failures--
+			mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/token"
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionMiddleware lets through the callers whose token carries the role
+// or one above it and refuses the others with a 403. It runs after
+// AuthMiddleware.
+func PermissionMiddleware(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload := ctx.MustGet(AuthPayloadKey).(*token.Payload)
+
+		if !model.HasRole(payload.Role, role) {
+			err := fmt.Errorf("%s role required", role)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+
+		ctx.Next()
+	}
+}
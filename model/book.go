@@ -12,4 +12,14 @@ type Book struct {
 	CoverImage      string    `json:"cover_image"`
 	Language        string    `json:"language"`
 	AvailableCopies int64     `json:"available_copies"`
+	Subjects        string    `json:"subjects"`
+	WorkId          uint64    `json:"work_id"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	// Contributors are the credits of the book, Author the statement of its
+	// authors' names kept alongside for search and sorting.
+	Contributors []*BookContributor `json:"contributors,omitempty" gorm:"-"`
+	// Classification lists the subjects of the taxonomy the book is classed
+	// under, unlike the free text Subjects.
+	Classification []*Subject `json:"classification,omitempty" gorm:"-"`
+	Tags           []string   `json:"tags,omitempty" gorm:"-"`
 }
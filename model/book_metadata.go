@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// BookMetadata is what a bibliographic source knows about an edition, used
+// to fill in a book entered by its ISBN. Fields the source lacks are empty.
+// Author is an author statement, the names separated by ";".
+type BookMetadata struct {
+	Isbn          string     `json:"isbn"`
+	Title         string     `json:"title"`
+	Author        string     `json:"author"`
+	PublishedDate *time.Time `json:"published_date"`
+	NumberOfPages uint64     `json:"number_of_pages"`
+	CoverURL      string     `json:"cover_url"`
+	Language      string     `json:"language"`
+	Subjects      string     `json:"subjects"`
+	Source        string     `json:"source"`
+}
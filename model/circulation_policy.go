@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+const PolicyWildcard = "*"
+
+// CirculationPolicy holds the lending rules for a member type and item type
+// pair. Either type may be PolicyWildcard to match any type. Fines are in the
+// smallest currency unit, a MaxFine of 0 leaves the fine per loan uncapped.
+type CirculationPolicy struct {
+	Audited
+	MemberType     string `json:"member_type"`
+	ItemType       string `json:"item_type"`
+	LoanPeriodDays int64  `json:"loan_period_days"`
+	MaxLoans       int64  `json:"max_loans"`
+	MaxRenewals    int64  `json:"max_renewals"`
+	GraceDays      int64  `json:"grace_days"`
+	DailyFine      int64  `json:"daily_fine"`
+	MaxFine        int64  `json:"max_fine"`
+}
+
+func (policy *CirculationPolicy) DueDate(from time.Time) time.Time {
+	return from.AddDate(0, 0, int(policy.LoanPeriodDays))
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Contributor roles are MARC relator codes.
+const (
+	RoleAuthor      = "aut"
+	RoleEditor      = "edt"
+	RoleTranslator  = "trl"
+	RoleIllustrator = "ill"
+	RoleIntroducer  = "aui"
+	RoleNarrator    = "nrt"
+	RoleContributor = "ctb"
+)
+
+// ContributorRoles names the roles a contributor can be credited under.
+var ContributorRoles = map[string]string{
+	RoleAuthor:      "author",
+	RoleEditor:      "editor",
+	RoleTranslator:  "translator",
+	RoleIllustrator: "illustrator",
+	RoleIntroducer:  "author of introduction",
+	RoleNarrator:    "narrator",
+	RoleContributor: "contributor",
+}
+
+// Contributor is a person or body credited on books. Names need not be
+// unique, the id tells two contributors of the same name apart.
+type Contributor struct {
+	Audited
+	Name string `json:"name"`
+}
+
+// BookContributor credits a contributor on a book under a role. Position
+// orders the credits of a book, Name is read along with the credit.
+type BookContributor struct {
+	BookId        uint64 `json:"-" gorm:"primaryKey"`
+	ContributorId uint64 `json:"contributor_id" gorm:"primaryKey"`
+	Role          string `json:"role" gorm:"primaryKey"`
+	Position      int    `json:"position"`
+	Name          string `json:"name" gorm:"->"`
+}
+
+// ContributorCredit is a book of a contributor's bibliography.
+type ContributorCredit struct {
+	BookId        uint64    `json:"book_id"`
+	Title         string    `json:"title"`
+	Isbn          string    `json:"isbn"`
+	PublishedDate time.Time `json:"published_date"`
+	Role          string    `json:"role"`
+}
+
+// AuthorCredits are the author credits of an author statement, the names it
+// lists separated by ";".
+func AuthorCredits(statement string) []*BookContributor {
+	var credits []*BookContributor
+	for _, name := range strings.Split(statement, ";") {
+		if name = strings.TrimSpace(name); name != "" {
+			credits = append(credits, &BookContributor{Name: name, Role: RoleAuthor, Position: len(credits)})
+		}
+	}
+	return credits
+}
+
+// AuthorStatement lists the names of the authors among the credits, those of
+// every contributor when none is an author, as in an edited collection.
+func AuthorStatement(credits []*BookContributor) string {
+	var names []string
+	for _, credit := range credits {
+		if credit.Role == RoleAuthor {
+			names = append(names, credit.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		for _, credit := range credits {
+			names = append(names, credit.Name)
+		}
+	}
+	return strings.Join(names, "; ")
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// Credential is the password a member logs in with, kept as an argon2id hash.
+type Credential struct {
+	MemberId     uint64    `json:"member_id" gorm:"primaryKey;autoIncrement:false"`
+	PasswordHash string    `json:"-"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package model
+
+const (
+	FacetAvailable   = "available"
+	FacetUnavailable = "unavailable"
+)
+
+// FacetSelection holds the facet values a client has picked. Values of one
+// facet are alternatives, different facets narrow each other down.
+type FacetSelection struct {
+	Author       []string `json:"author" form:"author"`
+	Language     []string `json:"language" form:"language"`
+	Decade       []int    `json:"decade" form:"decade" binding:"dive,gte=0"`
+	Availability []string `json:"availability" form:"availability" binding:"dive,oneof=available unavailable"`
+}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// BookFacets are bucket counts over a filtered set of books. The counts of a
+// facet ignore the values selected for that same facet, so every bucket tells
+// how many books selecting it would add or leave.
+type BookFacets struct {
+	Author       []*FacetBucket `json:"author"`
+	Language     []*FacetBucket `json:"language"`
+	Decade       []*FacetBucket `json:"decade"`
+	Availability []*FacetBucket `json:"availability"`
+}
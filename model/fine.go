@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+const (
+	FineKindOverdue = "overdue"
+	FineKindPayment = "payment"
+	FineKindWaiver  = "waiver"
+)
+
+// Fine is an entry of a member's fines ledger. Charges are positive, payments
+// and waivers negative, so the balance is the sum of the amounts. Overdue
+// entries are accrued once per loan per day, AccruedOn being that day as
+// YYYY-MM-DD.
+type Fine struct {
+	Audited
+	MemberId   uint64    `json:"member_id"`
+	LoanId     *uint64   `json:"loan_id"`
+	Kind       string    `json:"kind"`
+	Amount     int64     `json:"amount"`
+	AccruedOn  string    `json:"accrued_on"`
+	Method     string    `json:"method,omitempty"`
+	Reference  string    `json:"reference,omitempty"`
+	ReasonCode string    `json:"reason_code,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type MemberAccount struct {
+	MemberId uint64  `json:"member_id"`
+	Balance  int64   `json:"balance"`
+	Lines    []*Fine `json:"lines"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+const (
+	HoldStatusWaiting   = "waiting"
+	HoldStatusReady     = "ready"
+	HoldStatusFulfilled = "fulfilled"
+	HoldStatusCancelled = "cancelled"
+	HoldStatusExpired   = "expired"
+)
+
+// Hold is a member's place in the queue for a book, or for any edition of a
+// work when BookId is nil. Once a copy is trapped for the hold it is ready for
+// pickup until ExpiresAt.
+type Hold struct {
+	Audited
+	BookId    *uint64    `json:"book_id"`
+	WorkId    uint64     `json:"work_id"`
+	MemberId  uint64     `json:"member_id"`
+	ItemId    *uint64    `json:"item_id"`
+	Status    string     `json:"status"`
+	PlacedAt  time.Time  `json:"placed_at"`
+	ReadyAt   *time.Time `json:"ready_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+const ImportFormatMarc = "marc"
+
+const (
+	ImportRecordSucceeded = "succeeded"
+	ImportRecordFailed    = "failed"
+)
+
+// Import is an uploaded file of catalogue records processed in the
+// background. Every record read from the file gets an ImportRecord.
+type Import struct {
+	Audited
+	Format     string     `json:"format"`
+	FileName   string     `json:"file_name"`
+	Path       string     `json:"-"`
+	Copies     int64      `json:"copies"`
+	Status     string     `json:"status"`
+	Total      int64      `json:"total"`
+	Succeeded  int64      `json:"succeeded"`
+	Failed     int64      `json:"failed"`
+	Error      string     `json:"error"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// ImportRecord is the outcome of importing one record, Position being its
+// 1-based place in the file.
+type ImportRecord struct {
+	Audited
+	ImportId      uint64  `json:"import_id"`
+	Position      int64   `json:"position"`
+	ControlNumber string  `json:"control_number"`
+	Status        string  `json:"status"`
+	BookId        *uint64 `json:"book_id"`
+	Isbn          string  `json:"isbn"`
+	Title         string  `json:"title"`
+	Error         string  `json:"error"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+const (
+	ItemStatusAvailable = "available"
+	ItemStatusOnLoan    = "on_loan"
+	ItemStatusOnHold    = "on_hold_shelf"
+	ItemStatusDamaged   = "damaged"
+	ItemStatusLost      = "lost"
+	ItemStatusWithdrawn = "withdrawn"
+)
+
+const DefaultItemType = "book"
+
+// Item is a single physical copy of a book identified by its barcode.
+type Item struct {
+	Audited
+	Barcode         string    `json:"barcode"`
+	BookId          uint64    `json:"book_id"`
+	ItemType        string    `json:"item_type"`
+	Status          string    `json:"status"`
+	ShelfLocation   string    `json:"shelf_location"`
+	AcquisitionDate time.Time `json:"acquisition_date"`
+	Condition       string    `json:"condition"`
+}
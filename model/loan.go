@@ -4,8 +4,11 @@ import "time"
 
 type BookLoan struct {
 	Audited
-	BookId     uint64    `json:"book_id"`
-	MemberId   uint64    `json:"member_id"`
-	LoanDate   time.Time `json:"loan_date"`
-	ReturnDate time.Time `json:"return_date"`
+	BookId       uint64     `json:"book_id"`
+	ItemId       uint64     `json:"item_id"`
+	MemberId     uint64     `json:"member_id"`
+	LoanDate     time.Time  `json:"loan_date"`
+	DueDate      time.Time  `json:"due_date"`
+	RenewalCount int64      `json:"renewal_count"`
+	ReturnDate   *time.Time `json:"return_date"`
 }
@@ -2,9 +2,36 @@ package model
 
 import "time"
 
+const DefaultMemberType = "standard"
+
+const (
+	RoleMember    = "member"
+	RoleLibrarian = "librarian"
+	RoleAdmin     = "admin"
+)
+
+// roleRanks orders the roles, a role may do anything the roles below it can.
+var roleRanks = map[string]int{
+	RoleMember:    1,
+	RoleLibrarian: 2,
+	RoleAdmin:     3,
+}
+
 type Member struct {
 	Audited
-	Name     string    `json:"name"`
-	Email    string    `json:"email"`
-	JoinDate time.Time `json:"join_date"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	MemberType string    `json:"member_type"`
+	Role       string    `json:"role"`
+	JoinDate   time.Time `json:"join_date"`
+	// EmailVerifiedAt is when the member followed the link mailed to them,
+	// nil until they do
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+// HasRole tells whether role grants what required does. Unknown roles grant
+// nothing.
+func HasRole(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
 }
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OneTimeToken is a purpose token mailed to a member, recorded so it is good
+// for one use only. Id is the id of the token.
+type OneTimeToken struct {
+	Id        uuid.UUID  `json:"id" gorm:"primaryKey"`
+	MemberId  uint64     `json:"member_id"`
+	Purpose   string     `json:"purpose"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,11 @@
+package model
+
+// BookSearchHit is a book matched by a catalogue search, with its relevance
+// score (lower is better) and fragments highlighting the matched terms.
+type BookSearchHit struct {
+	Book
+	Score           float64 `json:"score"`
+	TitleHighlight  string  `json:"title_highlight"`
+	AuthorHighlight string  `json:"author_highlight"`
+	Snippet         string  `json:"snippet"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a refresh token handed out at login or renewal. A used one is
+// rotated, a replayed rotated one has its whole family revoked. MemberId is
+// nil for the admin email when it is not a member.
+type Session struct {
+	Id              uuid.UUID  `json:"id" gorm:"primaryKey"`
+	FamilyId        uuid.UUID  `json:"family_id"`
+	MemberId        *uint64    `json:"member_id"`
+	Username        string     `json:"username"`
+	UserAgent       string     `json:"user_agent"`
+	ClientIp        string     `json:"client_ip"`
+	IsRevoked       bool       `json:"is_revoked"`
+	RotatedAt       *time.Time `json:"rotated_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	AccessTokenId   uuid.UUID  `json:"-"`
+	AccessExpiresAt *time.Time `json:"-"`
+	// Current marks the session the caller is logged in with
+	Current bool `json:"current" gorm:"-"`
+}
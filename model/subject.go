@@ -0,0 +1,41 @@
+package model
+
+const (
+	SubjectKindTopic = "topic"
+	SubjectKindGenre = "genre"
+)
+
+// Subject is a node of the classification tree, a topic or a genre. Dewey
+// and Lcc optionally hold its Dewey Decimal and Library of Congress class
+// numbers. Books classed under a subject are also under all its ancestors.
+type Subject struct {
+	Audited
+	ParentId *uint64    `json:"parent_id"`
+	Name     string     `json:"name"`
+	Kind     string     `json:"kind"`
+	Dewey    string     `json:"dewey"`
+	Lcc      string     `json:"lcc"`
+	Children []*Subject `json:"children,omitempty" gorm:"-"`
+}
+
+type BookSubject struct {
+	BookId    uint64 `gorm:"primaryKey"`
+	SubjectId uint64 `gorm:"primaryKey"`
+}
+
+// Tag is a free-form label, kept lower case.
+type Tag struct {
+	Audited
+	Name string `json:"name"`
+}
+
+type BookTag struct {
+	BookId uint64 `gorm:"primaryKey"`
+	TagId  uint64 `gorm:"primaryKey"`
+}
+
+// TagCount is a tag with the number of books carrying it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// Work groups the editions and translations of the same title. A hold on a
+// work is met by a copy of any of its editions.
+type Work struct {
+	Audited
+	Title    string  `json:"title"`
+	Author   string  `json:"author"`
+	Editions []*Book `json:"editions,omitempty" gorm:"-"`
+}
@@ -0,0 +1,87 @@
+// Package bulk reads and writes streams of rows, either as CSV with a header
+// line naming the columns or as newline delimited JSON (NDJSON). Columns are
+// the json names of the row struct's fields, so both formats carry the same
+// data and decode with the same rules as a JSON request body.
+package bulk
+
+import (
+	"fmt"
+	"mime"
+	"reflect"
+	"strings"
+)
+
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+)
+
+// ParseFormat accepts a format name or the matching media type.
+func ParseFormat(value string) (Format, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	if mediaType, _, err := mime.ParseMediaType(name); err == nil {
+		name = mediaType
+	}
+
+	switch name {
+	case "csv", "text/csv":
+		return CSV, nil
+	case "ndjson", "jsonl", "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return NDJSON, nil
+	}
+	return "", fmt.Errorf("unsupported format %q, use csv or ndjson", value)
+}
+
+func (format Format) ContentType() string {
+	if format == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// RowError reports a row that could not be decoded.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (err *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", err.Line, err.Err)
+}
+
+func (err *RowError) Unwrap() error {
+	return err.Err
+}
+
+type column struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+// columns lists the json named fields of a struct type, including the ones
+// promoted from embedded structs.
+func columns(typ reflect.Type) []column {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var cols []column
+	for _, field := range reflect.VisibleFields(typ) {
+		if !field.IsExported() || (field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		cols = append(cols, column{name, field.Index, field.Type})
+	}
+	return cols
+}
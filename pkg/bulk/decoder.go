@@ -0,0 +1,150 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Decoder reads rows one at a time. Decode fills v, a pointer to a struct,
+// and returns the line the row started on, or io.EOF once the input is
+// exhausted. A *RowError means only the current row was unusable and decoding
+// can go on; any other error ends the input.
+type Decoder interface {
+	Decode(v any) (int, error)
+}
+
+func NewDecoder(format Format, r io.Reader) Decoder {
+	if format == CSV {
+		reader := csv.NewReader(r)
+		reader.ReuseRecord = true
+		return &csvDecoder{reader: reader}
+	}
+	return &ndjsonDecoder{r: bufio.NewReader(r)}
+}
+
+type ndjsonDecoder struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (decoder *ndjsonDecoder) Decode(v any) (int, error) {
+	for {
+		data, err := decoder.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(data) == 0) {
+			return 0, err
+		}
+		decoder.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		if err := json.Unmarshal(data, v); err != nil {
+			return decoder.line, &RowError{decoder.line, err}
+		}
+		return decoder.line, nil
+	}
+}
+
+type csvDecoder struct {
+	reader *csv.Reader
+	header []string
+}
+
+func (decoder *csvDecoder) Decode(v any) (int, error) {
+	if decoder.header == nil {
+		header, err := decoder.reader.Read()
+		if err == io.EOF {
+			return 0, err
+		}
+		if err != nil {
+			return 0, fmt.Errorf("unable to read the header line: %w", err)
+		}
+
+		for _, name := range header {
+			decoder.header = append(decoder.header, strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		}
+	}
+
+	record, err := decoder.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, &RowError{parseErr.StartLine, parseErr.Err}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	line, _ := decoder.reader.FieldPos(0)
+	data, err := decoder.toJSON(record, reflect.TypeOf(v))
+	if err != nil {
+		return line, &RowError{line, err}
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return line, &RowError{line, err}
+	}
+	return line, nil
+}
+
+// toJSON turns a record into the JSON object a client would have sent, so
+// cells decode exactly like request bodies do. Empty cells are left out.
+func (decoder *csvDecoder) toJSON(record []string, typ reflect.Type) ([]byte, error) {
+	types := map[string]reflect.Type{}
+	for _, col := range columns(typ) {
+		types[col.name] = col.typ
+	}
+
+	object := map[string]json.RawMessage{}
+	for idx, name := range decoder.header {
+		typ, ok := types[name]
+		if !ok || record[idx] == "" {
+			continue
+		}
+
+		value, err := cell(record[idx], typ)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", name, err)
+		}
+		object[name] = value
+	}
+	return json.Marshal(object)
+}
+
+func cell(value string, typ reflect.Type) (json.RawMessage, error) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return json.RawMessage(value), nil
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return json.Marshal(parsed)
+	case reflect.Slice:
+		// a list is exported as its JSON encoding
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("%q is not a JSON list", value)
+		}
+		return json.RawMessage(value), nil
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,80 @@
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+// Encoder writes rows one at a time. Output is buffered until Flush.
+type Encoder interface {
+	Encode(v any) error
+	Flush() error
+}
+
+func NewEncoder(format Format, w io.Writer) Encoder {
+	if format == CSV {
+		return &csvEncoder{writer: csv.NewWriter(w)}
+	}
+
+	buffered := bufio.NewWriter(w)
+	return &ndjsonEncoder{buffered, json.NewEncoder(buffered)}
+}
+
+type ndjsonEncoder struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (encoder *ndjsonEncoder) Encode(v any) error {
+	return encoder.encoder.Encode(v)
+}
+
+func (encoder *ndjsonEncoder) Flush() error {
+	return encoder.w.Flush()
+}
+
+// csvEncoder writes the header line from the first row's type. Cells hold
+// the JSON encoding of the field, unquoted for strings. Null unmarshals to an
+// empty cell.
+type csvEncoder struct {
+	writer  *csv.Writer
+	columns []column
+	record  []string
+}
+
+func (encoder *csvEncoder) Encode(v any) error {
+	if encoder.columns == nil {
+		encoder.columns = columns(reflect.TypeOf(v))
+		encoder.record = make([]string, len(encoder.columns))
+
+		for idx, col := range encoder.columns {
+			encoder.record[idx] = col.name
+		}
+		if err := encoder.writer.Write(encoder.record); err != nil {
+			return err
+		}
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(v))
+	for idx, col := range encoder.columns {
+		data, err := json.Marshal(value.FieldByIndex(col.index).Interface())
+		if err != nil {
+			return err
+		}
+
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			text = string(data)
+		}
+		encoder.record[idx] = text
+	}
+	return encoder.writer.Write(encoder.record)
+}
+
+func (encoder *csvEncoder) Flush() error {
+	encoder.writer.Flush()
+	return encoder.writer.Error()
+}
@@ -18,19 +18,68 @@ type sqliteConnector struct {
 type SqliteConnector interface {
 	Connector
 	DB(ctx context.Context) *gorm.DB
+	// WithinTransaction runs fn as a single unit of work. Transactions take the
+	// write lock up front so concurrent units queue up instead of failing. DB calls made with
+	// the context given to fn join the transaction, which commits when fn
+	// returns nil and rolls back otherwise. Nested calls join the outer unit.
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type unitOfWorkKey struct{}
+
+type unitOfWork struct {
+	tx          *gorm.DB
+	afterCommit []func(ctx context.Context)
 }
 
 func (sql *sqliteConnector) DB(ctx context.Context) *gorm.DB {
+	if uow, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork); ok {
+		return uow.tx.WithContext(ctx)
+	}
 	return sql.db.WithContext(ctx)
 }
 
+func (sql *sqliteConnector) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork); ok {
+		return fn(ctx)
+	}
+
+	uow := &unitOfWork{}
+	err := sql.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		uow.tx = tx
+		return fn(context.WithValue(ctx, unitOfWorkKey{}, uow))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, callback := range uow.afterCommit {
+		callback(ctx)
+	}
+	return nil
+}
+
+// AfterCommit defers fn until the unit of work in ctx commits, dropping it on
+// rollback. Outside of a unit of work fn runs straight away.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if uow, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork); ok {
+		uow.afterCommit = append(uow.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
 func NewSqliteConnector(config *config.DBConfig) SqliteConnector {
 	return &sqliteConnector{cfg: config}
 }
 
 func (sql *sqliteConnector) Connect(ctx context.Context) error {
-	db, err := gorm.Open(sqlite.Open("lms.db"), &gorm.Config{
+	db, err := gorm.Open(sqlite.Open("lms.db?_txlock=immediate&_busy_timeout=5000"), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// sqlite keeps timestamps as text, in UTC they compare in time order
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
 	})
 	if err != nil {
 		fmt.Errorf("Failed to open sqlite connection %s.", err)
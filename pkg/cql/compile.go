@@ -0,0 +1,249 @@
+package cql
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// SRU diagnostics a query can be refused with, from
+// http://www.loc.gov/standards/sru/diagnostics/diagnosticsList.html
+const (
+	DiagnosticSyntax                    = 10
+	DiagnosticTooLong                   = 12
+	DiagnosticTooComplex                = 13
+	DiagnosticUnsupportedIndex          = 16
+	DiagnosticUnsupportedRelation       = 19
+	DiagnosticUnsupportedRelationMod    = 20
+	DiagnosticEmptyTerm                 = 27
+	DiagnosticUnsupportedMaskingPattern = 28
+	DiagnosticUnsupportedBoolean        = 37
+	DiagnosticUnsupportedBooleanMod     = 46
+)
+
+// maxLength caps a query in characters.
+const maxLength = 1000
+
+// Error is a query refused with an SRU diagnostic. Details names the part of
+// the query at fault, as the diagnostic's details.
+type Error struct {
+	Code    int
+	Details string
+	Message string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("invalid query: %s", err.Message)
+}
+
+func newError(code int, details, message string) *Error {
+	return &Error{Code: code, Details: details, Message: message}
+}
+
+// Index maps a CQL index onto the columns it searches, a term matching in
+// any of them. Identifier indexes compare whole normalised values rather than
+// words.
+type Index struct {
+	Columns   []string
+	Normalize func(term string) string
+}
+
+// Indexes is the whitelist of indexes a resource can be searched on, by lower
+// case name.
+type Indexes map[string]Index
+
+// Compile parses a query and turns it into a gorm clause on the whitelisted
+// indexes.
+func (indexes Indexes) Compile(query string) (clause.Expression, error) {
+	if len([]rune(query)) > maxLength {
+		return nil, newError(DiagnosticTooLong, fmt.Sprint(maxLength), fmt.Sprintf("a query can be at most %d characters", maxLength))
+	}
+
+	node, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return indexes.compile(node)
+}
+
+func (indexes Indexes) compile(node Node) (clause.Expression, error) {
+	switch node := node.(type) {
+	case *Boolean:
+		if node.Op == BooleanProx {
+			return nil, newError(DiagnosticUnsupportedBoolean, node.Op, "prox is not supported")
+		}
+
+		if len(node.Modifiers) > 0 {
+			return nil, newError(DiagnosticUnsupportedBooleanMod, node.Modifiers[0], fmt.Sprintf("the boolean modifier %s is not supported", node.Modifiers[0]))
+		}
+
+		left, err := indexes.compile(node.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := indexes.compile(node.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		switch node.Op {
+		case BooleanAnd:
+			return clause.And(left, right), nil
+		case BooleanOr:
+			return clause.Or(left, right), nil
+		default:
+			return clause.And(left, negation{right}), nil
+		}
+	case *Clause:
+		return indexes.compileClause(node)
+	}
+	return nil, newError(DiagnosticSyntax, "", "unknown query node")
+}
+
+func (indexes Indexes) compileClause(node *Clause) (clause.Expression, error) {
+	index, ok := indexes[node.Index]
+	if !ok {
+		return nil, newError(DiagnosticUnsupportedIndex, node.Index, fmt.Sprintf("the index %s is not supported", node.Index))
+	}
+
+	if len(node.Modifiers) > 0 {
+		return nil, newError(DiagnosticUnsupportedRelationMod, node.Modifiers[0], fmt.Sprintf("the relation modifier %s is not supported", node.Modifiers[0]))
+	}
+
+	term := node.Term
+	if index.Normalize != nil {
+		term = index.Normalize(term)
+	}
+
+	words := strings.Fields(term)
+	if len(words) == 0 {
+		return nil, newError(DiagnosticEmptyTerm, node.Index, "the search term is empty")
+	}
+
+	// identifiers are whole values, never words of a text
+	if index.Normalize != nil {
+		switch node.Relation {
+		case "=", "==", "exact", "adj":
+			pattern, err := likePattern(strings.Join(words, ""))
+			if err != nil {
+				return nil, err
+			}
+			return index.match(pattern), nil
+		case "any":
+		case "<>":
+			pattern, err := likePattern(strings.Join(words, ""))
+			if err != nil {
+				return nil, err
+			}
+			return negation{index.match(pattern)}, nil
+		default:
+			return nil, newError(DiagnosticUnsupportedRelation, node.Relation, fmt.Sprintf("the relation %s is not supported on %s", node.Relation, node.Index))
+		}
+	}
+
+	switch node.Relation {
+	case "=", "all", "any":
+		exprs := make([]clause.Expression, 0, len(words))
+		for _, word := range words {
+			pattern, err := likePattern(word)
+			if err != nil {
+				return nil, err
+			}
+
+			if index.Normalize == nil {
+				pattern = "%" + pattern + "%"
+			}
+			exprs = append(exprs, index.match(pattern))
+		}
+
+		if len(exprs) == 1 {
+			return exprs[0], nil
+		}
+
+		if node.Relation == "any" {
+			return clause.Or(exprs...), nil
+		}
+		return clause.And(exprs...), nil
+	case "adj":
+		pattern, err := likePattern(strings.Join(words, " "))
+		if err != nil {
+			return nil, err
+		}
+		return index.match("%" + pattern + "%"), nil
+	case "==", "exact":
+		pattern, err := likePattern(term)
+		if err != nil {
+			return nil, err
+		}
+		return index.match(pattern), nil
+	case "<>":
+		pattern, err := likePattern(term)
+		if err != nil {
+			return nil, err
+		}
+		return negation{index.match(pattern)}, nil
+	}
+	return nil, newError(DiagnosticUnsupportedRelation, node.Relation, fmt.Sprintf("the relation %s is not supported on %s", node.Relation, node.Index))
+}
+
+// match is a LIKE on any of the index's columns. SQLite's LIKE ignores the
+// case of ASCII letters.
+func (index Index) match(pattern string) clause.Expression {
+	exprs := make([]clause.Expression, 0, len(index.Columns))
+	for _, column := range index.Columns {
+		exprs = append(exprs, clause.Expr{
+			SQL:  `? LIKE ? ESCAPE '\'`,
+			Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: column}, pattern},
+		})
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	return clause.Or(exprs...)
+}
+
+// likePattern turns a CQL term into a LIKE pattern: * and ? mask any number
+// of characters and a single one, a backslash takes the next character as
+// is.
+func likePattern(term string) (string, error) {
+	var pattern strings.Builder
+	runes := []rune(term)
+	for pos := 0; pos < len(runes); pos++ {
+		r := runes[pos]
+		switch r {
+		case '*':
+			pattern.WriteByte('%')
+		case '?':
+			pattern.WriteByte('_')
+		case '\\':
+			pos++
+			if pos == len(runes) {
+				return "", newError(DiagnosticUnsupportedMaskingPattern, term, "the term ends in a lone backslash")
+			}
+			r = runes[pos]
+			fallthrough
+		default:
+			if r == '%' || r == '_' || r == '\\' {
+				pattern.WriteByte('\\')
+			}
+			pattern.WriteRune(r)
+		}
+	}
+	return pattern.String(), nil
+}
+
+// negation negates an expression as a whole. gorm's clause.Not negates the
+// members of a group one by one, which turns NOT (a AND b) into NOT a AND
+// NOT b.
+type negation struct {
+	expr clause.Expression
+}
+
+func (negation negation) Build(builder clause.Builder) {
+	builder.WriteString("NOT (")
+	negation.expr.Build(builder)
+	builder.WriteByte(')')
+}
@@ -0,0 +1,160 @@
+package cql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/utils/tests"
+)
+
+var testIndexes = Indexes{
+	"dc.title":         {Columns: []string{"title"}},
+	"dc.creator":       {Columns: []string{"author"}},
+	"cql.serverchoice": {Columns: []string{"title", "author"}},
+}
+
+// compile compiles the query and renders the clause it compiles to as SQL on
+// the books table.
+func compile(t *testing.T, query string) (string, []interface{}, error) {
+	t.Helper()
+	where, err := testIndexes.Compile(query)
+	if err != nil {
+		return "", nil, err
+	}
+
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := &gorm.Statement{DB: db, Table: "books", Clauses: map[string]clause.Clause{}}
+	where.Build(stmt)
+	return stmt.SQL.String(), stmt.Vars, nil
+}
+
+func TestCompileRendersQueries(t *testing.T) {
+	cases := []struct {
+		query string
+		sql   string
+		vars  []interface{}
+	}{
+		{`dune`, "(`books`.`title` LIKE ? ESCAPE '\\' OR `books`.`author` LIKE ? ESCAPE '\\')", []interface{}{"%dune%", "%dune%"}},
+		{`dc.title any "dune emma"`, "(`books`.`title` LIKE ? ESCAPE '\\' OR `books`.`title` LIKE ? ESCAPE '\\')", []interface{}{"%dune%", "%emma%"}},
+		{`dc.title adj "lord of*"`, "`books`.`title` LIKE ? ESCAPE '\\'", []interface{}{"%lord of%%"}},
+		// booleans bind left to right, whatever they are
+		{`dc.title = dune or dc.title = emma and dc.creator = austen`,
+			"((`books`.`title` LIKE ? ESCAPE '\\' OR `books`.`title` LIKE ? ESCAPE '\\') AND `books`.`author` LIKE ? ESCAPE '\\')",
+			[]interface{}{"%dune%", "%emma%", "%austen%"}},
+		{`dc.title = dune or (dc.title = emma and dc.creator = austen)`,
+			"(`books`.`title` LIKE ? ESCAPE '\\' OR (`books`.`title` LIKE ? ESCAPE '\\' AND `books`.`author` LIKE ? ESCAPE '\\'))",
+			[]interface{}{"%dune%", "%emma%", "%austen%"}},
+		// not negates the group as a whole
+		{`dc.title = dune not (dc.creator = herbert or dc.creator = austen)`,
+			"(`books`.`title` LIKE ? ESCAPE '\\' AND NOT ((`books`.`author` LIKE ? ESCAPE '\\' OR `books`.`author` LIKE ? ESCAPE '\\')))",
+			[]interface{}{"%dune%", "%herbert%", "%austen%"}},
+	}
+
+	for _, tc := range cases {
+		sql, vars, err := compile(t, tc.query)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.query, err)
+		}
+
+		if sql != tc.sql {
+			t.Fatalf("%s: expected %s, got %s", tc.query, tc.sql, sql)
+		}
+
+		if len(vars) != len(tc.vars) {
+			t.Fatalf("%s: expected %v, got %v", tc.query, tc.vars, vars)
+		}
+		for idx := range vars {
+			if vars[idx] != tc.vars[idx] {
+				t.Fatalf("%s: expected %v, got %v", tc.query, tc.vars, vars)
+			}
+		}
+	}
+}
+
+func TestCompileEscapes(t *testing.T) {
+	cases := []struct {
+		query   string
+		pattern string
+	}{
+		{`dc.title == "say \"hi\""`, `say "hi"`},
+		{`dc.title == "a\*b"`, `a*b`},
+		{`dc.title == "a\?b"`, `a?b`},
+		{`dc.title == a*b?`, `a%b_`},
+		{`dc.title == "100%_done"`, `100\%\_done`},
+		{`dc.title == "a\\b"`, `a\\b`},
+	}
+
+	for _, tc := range cases {
+		_, vars, err := compile(t, tc.query)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.query, err)
+		}
+
+		if len(vars) != 1 || vars[0] != tc.pattern {
+			t.Fatalf("%s: expected the pattern %s, got %v", tc.query, tc.pattern, vars)
+		}
+	}
+}
+
+func TestCompileRejectsInvalidQueries(t *testing.T) {
+	cases := []struct {
+		query   string
+		code    int
+		details string
+	}{
+		{``, DiagnosticSyntax, ""},
+		{`dc.title = "dune`, DiagnosticSyntax, `dc.title = "dune`},
+		{`(dc.title = dune`, DiagnosticSyntax, ""},
+		{`dc.title = dune)`, DiagnosticSyntax, ")"},
+		{`dc.title =`, DiagnosticSyntax, "dc.title"},
+		{`dc.title = dune and`, DiagnosticSyntax, ""},
+		{`dc.title = dune\`, DiagnosticUnsupportedMaskingPattern, `dune\`},
+		{`bath.isbn = 0261103253`, DiagnosticUnsupportedIndex, "bath.isbn"},
+		{`dc.title < dune`, DiagnosticUnsupportedRelation, "<"},
+		{`dc.title =/stem dune`, DiagnosticUnsupportedRelationMod, "stem"},
+		{`dc.title = ""`, DiagnosticEmptyTerm, "dc.title"},
+		{`dune prox emma`, DiagnosticUnsupportedBoolean, "prox"},
+		{`dune and/rel.sum emma`, DiagnosticUnsupportedBooleanMod, "rel.sum"},
+	}
+
+	for _, tc := range cases {
+		_, _, err := compile(t, tc.query)
+		var cqlErr *Error
+		if !errors.As(err, &cqlErr) {
+			t.Fatalf("%s: expected a query error, got %v", tc.query, err)
+		}
+
+		if cqlErr.Code != tc.code || cqlErr.Details != tc.details {
+			t.Fatalf("%s: expected diagnostic %d on %q, got %d on %q", tc.query, tc.code, tc.details, cqlErr.Code, cqlErr.Details)
+		}
+	}
+}
+
+func TestCompileLimitsSize(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "dune" + strings.Repeat(")", depth)
+	}
+
+	if _, _, err := compile(t, nested(maxDepth)); err != nil {
+		t.Fatalf("expected %d nested parentheses to compile, got %v", maxDepth, err)
+	}
+
+	var cqlErr *Error
+	if _, _, err := compile(t, nested(maxDepth+1)); !errors.As(err, &cqlErr) || cqlErr.Code != DiagnosticTooComplex {
+		t.Fatalf("expected more than %d nested parentheses to fail with %d, got %v", maxDepth, DiagnosticTooComplex, err)
+	}
+
+	if _, _, err := compile(t, strings.Repeat("a", maxLength)); err != nil {
+		t.Fatalf("expected a %d character query to compile, got %v", maxLength, err)
+	}
+
+	if _, _, err := compile(t, strings.Repeat("a", maxLength+1)); !errors.As(err, &cqlErr) || cqlErr.Code != DiagnosticTooLong {
+		t.Fatalf("expected more than %d characters to fail with %d, got %v", maxLength, DiagnosticTooLong, err)
+	}
+}
@@ -0,0 +1,305 @@
+// Package cql parses Contextual Query Language queries, the query language
+// of SRU, and compiles them onto whitelisted columns much like package filter
+// does for JSON filters.
+//
+//	dc.title = "lord of the rings" and (dc.creator any "tolkien lewis" not bath.isbn = 0261103253)
+//
+// Booleans bind left to right with equal precedence, parentheses group. A
+// term on its own searches cql.serverChoice.
+package cql
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	BooleanAnd  = "and"
+	BooleanOr   = "or"
+	BooleanNot  = "not"
+	BooleanProx = "prox"
+)
+
+// ServerChoice is the index of a search clause that names none.
+const ServerChoice = "cql.serverchoice"
+
+const maxDepth = 10
+
+// Node is a parsed query: a *Clause or a *Boolean.
+type Node interface {
+	node()
+}
+
+// Clause is a single search clause. Index and Relation are lower case,
+// Term is as given.
+type Clause struct {
+	Index     string
+	Relation  string
+	Modifiers []string
+	Term      string
+}
+
+// Boolean joins two sub queries.
+type Boolean struct {
+	Op        string
+	Modifiers []string
+	Left      Node
+	Right     Node
+}
+
+func (*Clause) node()  {}
+func (*Boolean) node() {}
+
+// Parse parses a query, refusing anything that is not well formed CQL with a
+// *Error.
+func Parse(query string) (Node, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, newError(DiagnosticSyntax, query, "the query is empty")
+	}
+
+	parser := &parser{tokens: tokens}
+	node, err := parser.query(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := parser.peek(); token != nil {
+		return nil, newError(DiagnosticSyntax, token.text, fmt.Sprintf("unexpected %q at %d", token.text, token.pos))
+	}
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenComparator
+	tokenOpen
+	tokenClose
+	tokenSlash
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lex(query string) ([]*token, error) {
+	var tokens []*token
+	runes := []rune(query)
+	for pos := 0; pos < len(runes); {
+		r := runes[pos]
+		switch {
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			pos++
+		case r == '(':
+			tokens = append(tokens, &token{tokenOpen, "(", pos})
+			pos++
+		case r == ')':
+			tokens = append(tokens, &token{tokenClose, ")", pos})
+			pos++
+		case r == '/':
+			tokens = append(tokens, &token{tokenSlash, "/", pos})
+			pos++
+		case r == '=' || r == '<' || r == '>':
+			start := pos
+			pos++
+			if pos < len(runes) {
+				if pair := string(runes[start : pos+1]); pair == "==" || pair == "<>" || pair == "<=" || pair == ">=" {
+					pos++
+				}
+			}
+			tokens = append(tokens, &token{tokenComparator, string(runes[start:pos]), start})
+		case r == '"':
+			start := pos
+			var text strings.Builder
+			for pos++; ; pos++ {
+				if pos >= len(runes) {
+					return nil, newError(DiagnosticSyntax, query, fmt.Sprintf("unterminated string at %d", start))
+				}
+
+				if runes[pos] == '"' {
+					pos++
+					break
+				}
+
+				// an escaped quote loses its backslash, any other escape is
+				// kept for the compiler to tell masking characters apart
+				if runes[pos] == '\\' && pos+1 < len(runes) {
+					if runes[pos+1] != '"' {
+						text.WriteRune(runes[pos])
+					}
+					pos++
+				}
+				text.WriteRune(runes[pos])
+			}
+			tokens = append(tokens, &token{tokenString, text.String(), start})
+		default:
+			start := pos
+			for pos < len(runes) && !strings.ContainsRune(" \t\r\n()/=<>\"", runes[pos]) {
+				pos++
+			}
+			tokens = append(tokens, &token{tokenWord, string(runes[start:pos]), start})
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []*token
+	pos    int
+}
+
+func (parser *parser) peek() *token {
+	if parser.pos < len(parser.tokens) {
+		return parser.tokens[parser.pos]
+	}
+	return nil
+}
+
+func (parser *parser) next() *token {
+	token := parser.peek()
+	if token != nil {
+		parser.pos++
+	}
+	return token
+}
+
+// query parses clauses joined by booleans, left to right.
+func (parser *parser) query(depth int) (Node, error) {
+	if depth > maxDepth {
+		return nil, newError(DiagnosticTooComplex, "", fmt.Sprintf("parentheses can be nested at most %d deep", maxDepth))
+	}
+
+	left, err := parser.searchClause(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		token := parser.peek()
+		if token == nil || token.kind != tokenWord || !isBoolean(token.text) {
+			return left, nil
+		}
+		parser.next()
+
+		modifiers, err := parser.modifiers()
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := parser.searchClause(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Boolean{Op: strings.ToLower(token.text), Modifiers: modifiers, Left: left, Right: right}
+	}
+}
+
+func (parser *parser) searchClause(depth int) (Node, error) {
+	token := parser.next()
+	if token == nil {
+		return nil, newError(DiagnosticSyntax, "", "the query ends where a search clause was expected")
+	}
+
+	switch token.kind {
+	case tokenOpen:
+		node, err := parser.query(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := parser.next(); closing == nil || closing.kind != tokenClose {
+			return nil, newError(DiagnosticSyntax, "", fmt.Sprintf("the parenthesis at %d is not closed", token.pos))
+		}
+		return node, nil
+	case tokenWord, tokenString:
+	default:
+		return nil, newError(DiagnosticSyntax, token.text, fmt.Sprintf("unexpected %q at %d", token.text, token.pos))
+	}
+
+	// a word followed by a relation is an index, otherwise the term itself
+	relation := parser.peek()
+	if token.kind == tokenString || relation == nil || !isRelation(relation) {
+		return &Clause{Index: ServerChoice, Relation: "=", Term: token.text}, nil
+	}
+	parser.next()
+
+	modifiers, err := parser.modifiers()
+	if err != nil {
+		return nil, err
+	}
+
+	term := parser.next()
+	if term == nil || (term.kind != tokenWord && term.kind != tokenString) {
+		return nil, newError(DiagnosticSyntax, token.text, fmt.Sprintf("the clause on %s has no search term", token.text))
+	}
+
+	return &Clause{
+		Index:     strings.ToLower(token.text),
+		Relation:  strings.ToLower(relation.text),
+		Modifiers: modifiers,
+		Term:      term.text,
+	}, nil
+}
+
+// modifiers parses /name modifiers. Only their names are kept, none are
+// supported past parsing.
+func (parser *parser) modifiers() ([]string, error) {
+	var modifiers []string
+	for {
+		slash := parser.peek()
+		if slash == nil || slash.kind != tokenSlash {
+			return modifiers, nil
+		}
+		parser.next()
+
+		name := parser.next()
+		if name == nil || name.kind != tokenWord {
+			return nil, newError(DiagnosticSyntax, "/", fmt.Sprintf("the modifier at %d has no name", slash.pos))
+		}
+
+		// a modifier may compare to a value, /distance<3
+		if comparator := parser.peek(); comparator != nil && comparator.kind == tokenComparator {
+			parser.next()
+			if value := parser.next(); value == nil || (value.kind != tokenWord && value.kind != tokenString) {
+				return nil, newError(DiagnosticSyntax, name.text, fmt.Sprintf("the modifier %s has no value", name.text))
+			}
+		}
+		modifiers = append(modifiers, strings.ToLower(name.text))
+	}
+}
+
+func isBoolean(word string) bool {
+	switch strings.ToLower(word) {
+	case BooleanAnd, BooleanOr, BooleanNot, BooleanProx:
+		return true
+	}
+	return false
+}
+
+// isRelation tells whether a token after a word makes that word an index. Any
+// comparator does, and so do the named relations, which as a bare word after
+// a term could not be anything else.
+func isRelation(token *token) bool {
+	if token.kind == tokenComparator {
+		return true
+	}
+
+	if token.kind != tokenWord {
+		return false
+	}
+
+	switch strings.ToLower(token.text) {
+	case "any", "all", "adj", "exact", "within", "encloses":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+// Package dublincore renders catalogue books as simple Dublin Core records
+// in the oai_dc schema harvesters expect.
+package dublincore
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/marc"
+)
+
+const (
+	Namespace    = "http://purl.org/dc/elements/1.1/"
+	OAINamespace = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+	Schema       = "http://www.openarchives.org/OAI/2.0/oai_dc.xsd"
+
+	// MediaType is what clients ask for to get Dublin Core instead of JSON.
+	MediaType = "application/dc+xml"
+)
+
+// Record is an oai_dc:dc element. encoding/xml has no say over prefixes, so
+// the prefixed names and their declarations are spelled out.
+type Record struct {
+	XMLName        xml.Name `xml:"oai_dc:dc"`
+	OAIDC          string   `xml:"xmlns:oai_dc,attr"`
+	DC             string   `xml:"xmlns:dc,attr"`
+	XSI            string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Title          []string `xml:"dc:title"`
+	Creator        []string `xml:"dc:creator"`
+	Subject        []string `xml:"dc:subject"`
+	Contributor    []string `xml:"dc:contributor"`
+	Date           []string `xml:"dc:date"`
+	Type           []string `xml:"dc:type"`
+	Format         []string `xml:"dc:format"`
+	Identifier     []string `xml:"dc:identifier"`
+	Language       []string `xml:"dc:language"`
+}
+
+// FromBook describes a book in Dublin Core. The language is the MARC (ISO
+// 639-2) code and the ISBN is given as a URN. Author credits are creators and
+// the other credits contributors, the author statement standing in as the
+// creator when there is no author credit.
+func FromBook(book *model.Book) *Record {
+	record := &Record{
+		OAIDC:          OAINamespace,
+		DC:             Namespace,
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: OAINamespace + " " + Schema,
+		Title:          []string{book.Title},
+		Date:           []string{book.PublishedDate.Format("2006-01-02")},
+		Type:           []string{"Text"},
+		Format:         []string{fmt.Sprintf("%d pages", book.NumberOfPages)},
+		Identifier:     []string{"urn:isbn:" + book.Isbn},
+		Language:       []string{marc.LanguageCode(book.Language)},
+	}
+
+	for _, subject := range strings.Split(book.Subjects, ";") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			record.Subject = append(record.Subject, subject)
+		}
+	}
+
+	for _, credit := range book.Contributors {
+		if credit.Role == model.RoleAuthor {
+			record.Creator = append(record.Creator, credit.Name)
+		} else {
+			record.Contributor = append(record.Contributor, credit.Name)
+		}
+	}
+
+	if len(record.Creator) == 0 {
+		record.Creator = []string{book.Author}
+	}
+	return record
+}
+
+var xmlHeader = xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)}
+
+// WriteXML writes a record as a document of its own.
+func WriteXML(w io.Writer, record *Record) error {
+	encoder := xml.NewEncoder(w)
+	if err := encoder.EncodeToken(xmlHeader); err != nil {
+		return err
+	}
+
+	if err := encoder.Encode(record); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+// XMLWriter streams records inside a records element, Dublin Core having no
+// collection element of its own. Close ends the document; output is buffered
+// until Flush or Close.
+type XMLWriter struct {
+	encoder *xml.Encoder
+	started bool
+}
+
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	return &XMLWriter{encoder: xml.NewEncoder(w)}
+}
+
+var records = xml.StartElement{Name: xml.Name{Local: "records"}}
+
+func (writer *XMLWriter) start() error {
+	if writer.started {
+		return nil
+	}
+	writer.started = true
+
+	if err := writer.encoder.EncodeToken(xmlHeader); err != nil {
+		return err
+	}
+	return writer.encoder.EncodeToken(records)
+}
+
+func (writer *XMLWriter) Write(record *Record) error {
+	if err := writer.start(); err != nil {
+		return err
+	}
+	return writer.encoder.Encode(record)
+}
+
+func (writer *XMLWriter) Flush() error {
+	return writer.encoder.Flush()
+}
+
+func (writer *XMLWriter) Close() error {
+	if err := writer.start(); err != nil {
+		return err
+	}
+
+	if err := writer.encoder.EncodeToken(records.End()); err != nil {
+		return err
+	}
+	return writer.encoder.Flush()
+}
@@ -0,0 +1,319 @@
+// Package filter implements the structured filter language accepted by the
+// list endpoints. A filter is a tree of conditions on whitelisted fields,
+// combined with and/or groups, which is validated and compiled into gorm
+// clauses so client input never reaches the SQL text.
+//
+//	{"or": [
+//	  {"field": "author", "op": "like", "value": "%tolkien%"},
+//	  {"and": [
+//	    {"field": "language", "op": "in", "value": ["english", "french"]},
+//	    {"field": "published_date", "op": "between", "value": ["1950-01-01", "1960-01-01"]}
+//	  ]}
+//	]}
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	OpEq      = "eq"
+	OpNeq     = "neq"
+	OpLt      = "lt"
+	OpLte     = "lte"
+	OpGt      = "gt"
+	OpGte     = "gte"
+	OpIn      = "in"
+	OpLike    = "like"
+	OpBetween = "between"
+)
+
+const (
+	maxDepth      = 5
+	maxConditions = 50
+	maxInValues   = 100
+)
+
+// Expression is a node of a filter tree. It is either a condition on a single
+// field or an and/or group of nested expressions, never both.
+type Expression struct {
+	Field string          `json:"field,omitempty"`
+	Op    string          `json:"op,omitempty"`
+	Value json.RawMessage `json:"value,omitempty" swaggertype:"object"`
+	And   []*Expression   `json:"and,omitempty"`
+	Or    []*Expression   `json:"or,omitempty"`
+}
+
+type FieldType int
+
+const (
+	String FieldType = iota
+	Number
+	Time
+)
+
+// Field maps a filterable field name to its column and value type.
+// Normalize, if set, brings string values compared for equality into the
+// form the column keeps.
+//
+// A field of a related table names the Table and sets Within, which turns
+// the condition on that table into one on the filtered resource, usually a
+// subquery.
+type Field struct {
+	Column    string
+	Table     string
+	Type      FieldType
+	Normalize func(value string) string
+	Within    func(condition clause.Expression) clause.Expression
+}
+
+// Fields is the whitelist of fields a resource can be filtered on.
+type Fields map[string]Field
+
+// Error is returned for a filter that fails validation. Field names the
+// offending field when the problem is tied to one.
+type Error struct {
+	Field   string
+	Message string
+}
+
+func (err *Error) Error() string {
+	if err.Field == "" {
+		return fmt.Sprintf("invalid filter: %s", err.Message)
+	}
+	return fmt.Sprintf("invalid filter on field %q: %s", err.Field, err.Message)
+}
+
+// Compile validates expr against the whitelisted fields and turns it into a
+// gorm clause. A nil expression compiles to a nil clause.
+func (fields Fields) Compile(expr *Expression) (clause.Expression, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	conditions := 0
+	return fields.compile(expr, 1, &conditions)
+}
+
+func (fields Fields) compile(expr *Expression, depth int, conditions *int) (clause.Expression, error) {
+	if depth > maxDepth {
+		return nil, &Error{Message: fmt.Sprintf("groups can be nested at most %d deep", maxDepth)}
+	}
+
+	isGroup := len(expr.And) > 0 || len(expr.Or) > 0
+	if isGroup && (expr.Field != "" || expr.Op != "") {
+		return nil, &Error{Field: expr.Field, Message: "a condition cannot also be an and/or group"}
+	}
+
+	if len(expr.And) > 0 && len(expr.Or) > 0 {
+		return nil, &Error{Message: "use separate groups for and and or"}
+	}
+
+	if len(expr.And) > 0 {
+		exprs, err := fields.compileGroup(expr.And, depth, conditions)
+		if err != nil {
+			return nil, err
+		}
+		return clause.And(exprs...), nil
+	}
+
+	if len(expr.Or) > 0 {
+		exprs, err := fields.compileGroup(expr.Or, depth, conditions)
+		if err != nil {
+			return nil, err
+		}
+
+		// gorm joins a lone or condition to the rest of the query with OR.
+		if len(exprs) == 1 {
+			return exprs[0], nil
+		}
+		return clause.Or(exprs...), nil
+	}
+
+	*conditions++
+	if *conditions > maxConditions {
+		return nil, &Error{Message: fmt.Sprintf("at most %d conditions are allowed", maxConditions)}
+	}
+	return fields.compileCondition(expr)
+}
+
+func (fields Fields) compileGroup(group []*Expression, depth int, conditions *int) ([]clause.Expression, error) {
+	exprs := make([]clause.Expression, 0, len(group))
+	for _, expr := range group {
+		if expr == nil {
+			return nil, &Error{Message: "empty expression in group"}
+		}
+
+		compiled, err := fields.compile(expr, depth+1, conditions)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, compiled)
+	}
+	return exprs, nil
+}
+
+func (fields Fields) compileCondition(expr *Expression) (clause.Expression, error) {
+	if expr.Field == "" {
+		return nil, &Error{Message: "a condition needs a field"}
+	}
+
+	field, ok := fields[expr.Field]
+	if !ok {
+		return nil, &Error{Field: expr.Field, Message: "field cannot be filtered on"}
+	}
+
+	condition, err := field.compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if field.Within != nil {
+		return field.Within(condition), nil
+	}
+	return condition, nil
+}
+
+func (field Field) compile(expr *Expression) (clause.Expression, error) {
+	column := clause.Column{Table: clause.CurrentTable, Name: field.Column}
+	if field.Table != "" {
+		column.Table = field.Table
+	}
+
+	switch expr.Op {
+	case OpEq, OpNeq, OpLt, OpLte, OpGt, OpGte:
+		value, err := field.decode(expr.Value)
+		if err != nil {
+			return nil, &Error{Field: expr.Field, Message: err.Error()}
+		}
+		return comparison(expr.Op, column, field.normalize(value)), nil
+	case OpIn:
+		values, err := field.decodeList(expr.Value)
+		if err != nil {
+			return nil, &Error{Field: expr.Field, Message: err.Error()}
+		}
+
+		if len(values) == 0 || len(values) > maxInValues {
+			return nil, &Error{Field: expr.Field, Message: fmt.Sprintf("in takes between 1 and %d values", maxInValues)}
+		}
+
+		for idx, value := range values {
+			values[idx] = field.normalize(value)
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	case OpLike:
+		if field.Type != String {
+			return nil, &Error{Field: expr.Field, Message: "like only applies to text fields"}
+		}
+
+		value, err := field.decode(expr.Value)
+		if err != nil {
+			return nil, &Error{Field: expr.Field, Message: err.Error()}
+		}
+		return clause.Like{Column: column, Value: value}, nil
+	case OpBetween:
+		values, err := field.decodeList(expr.Value)
+		if err != nil {
+			return nil, &Error{Field: expr.Field, Message: err.Error()}
+		}
+
+		if len(values) != 2 {
+			return nil, &Error{Field: expr.Field, Message: "between takes exactly two values"}
+		}
+		return clause.And(clause.Gte{Column: column, Value: values[0]}, clause.Lte{Column: column, Value: values[1]}), nil
+	case "":
+		return nil, &Error{Field: expr.Field, Message: "missing operator"}
+	default:
+		return nil, &Error{Field: expr.Field, Message: fmt.Sprintf("unknown operator %q", expr.Op)}
+	}
+}
+
+func comparison(op string, column clause.Column, value interface{}) clause.Expression {
+	switch op {
+	case OpNeq:
+		return clause.Neq{Column: column, Value: value}
+	case OpLt:
+		return clause.Lt{Column: column, Value: value}
+	case OpLte:
+		return clause.Lte{Column: column, Value: value}
+	case OpGt:
+		return clause.Gt{Column: column, Value: value}
+	case OpGte:
+		return clause.Gte{Column: column, Value: value}
+	default:
+		return clause.Eq{Column: column, Value: value}
+	}
+}
+
+func (field Field) normalize(value interface{}) interface{} {
+	if text, ok := value.(string); ok && field.Normalize != nil {
+		return field.Normalize(text)
+	}
+	return value
+}
+
+func (field Field) decodeList(raw json.RawMessage) ([]interface{}, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("expected a list of values")
+	}
+
+	values := make([]interface{}, len(items))
+	for idx, item := range items {
+		value, err := field.decode(item)
+		if err != nil {
+			return nil, err
+		}
+		values[idx] = value
+	}
+	return values, nil
+}
+
+func (field Field) decode(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	switch field.Type {
+	case Number:
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		var number json.Number
+		if err := decoder.Decode(&number); err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+
+		if value, err := number.Int64(); err == nil {
+			return value, nil
+		}
+		return number.Float64()
+	case Time:
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("expected a date")
+		}
+		return parseTime(value)
+	default:
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("expected a string")
+		}
+		return value, nil
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a date as YYYY-MM-DD or RFC 3339")
+}
@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/utils/tests"
+)
+
+var testFields = Fields{
+	"title":          {Column: "title"},
+	"language":       {Column: "language"},
+	"pages":          {Column: "number_of_pages", Type: Number},
+	"published_date": {Column: "published_date", Type: Time},
+	"isbn":           {Column: "isbn", Normalize: strings.ToUpper},
+}
+
+// compile parses the filter and renders the clause it compiles to as SQL on
+// the books table.
+func compile(t *testing.T, filter string) (string, []interface{}, error) {
+	t.Helper()
+	var expr *Expression
+	if err := json.Unmarshal([]byte(filter), &expr); err != nil {
+		t.Fatalf("invalid test filter %s: %v", filter, err)
+	}
+
+	where, err := testFields.Compile(expr)
+	if err != nil || where == nil {
+		return "", nil, err
+	}
+
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := &gorm.Statement{DB: db, Table: "books", Clauses: map[string]clause.Clause{}}
+	where.Build(stmt)
+	return stmt.SQL.String(), stmt.Vars, nil
+}
+
+func TestCompileRendersConditions(t *testing.T) {
+	cases := []struct {
+		filter string
+		sql    string
+		vars   int
+	}{
+		{`{"field": "title", "op": "eq", "value": "Dune"}`, "`books`.`title` = ?", 1},
+		{`{"field": "pages", "op": "gte", "value": 100}`, "`books`.`number_of_pages` >= ?", 1},
+		{`{"field": "language", "op": "in", "value": ["english", "french"]}`, "`books`.`language` IN (?,?)", 2},
+		{`{"field": "published_date", "op": "between", "value": ["1950-01-01", "1960-01-01"]}`,
+			"(`books`.`published_date` >= ? AND `books`.`published_date` <= ?)", 2},
+		{`{"or": [{"field": "title", "op": "like", "value": "%dune%"}, {"and": [{"field": "language", "op": "eq", "value": "english"}, {"field": "pages", "op": "lt", "value": 300}]}]}`,
+			"(`books`.`title` LIKE ? OR (`books`.`language` = ? AND `books`.`number_of_pages` < ?))", 3},
+		{`{"and": [{"or": [{"field": "title", "op": "eq", "value": "Dune"}, {"field": "title", "op": "eq", "value": "Emma"}]}, {"field": "pages", "op": "gt", "value": 1}]}`,
+			"((`books`.`title` = ? OR `books`.`title` = ?) AND `books`.`number_of_pages` > ?)", 3},
+	}
+
+	for _, tc := range cases {
+		sql, vars, err := compile(t, tc.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.filter, err)
+		}
+
+		if sql != tc.sql || len(vars) != tc.vars {
+			t.Fatalf("%s: expected %s with %d values, got %s with %v", tc.filter, tc.sql, tc.vars, sql, vars)
+		}
+	}
+}
+
+func TestCompileNormalizesValues(t *testing.T) {
+	_, vars, err := compile(t, `{"field": "isbn", "op": "in", "value": ["080442957x", "9780441172719"]}`)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if vars[0] != "080442957X" || vars[1] != "9780441172719" {
+		t.Fatalf("expected the values normalized, got %v", vars)
+	}
+}
+
+func TestCompileRejectsInvalidFilters(t *testing.T) {
+	tooDeep := `{"field": "title", "op": "eq", "value": "Dune"}`
+	for idx := 0; idx < maxDepth; idx++ {
+		tooDeep = `{"and": [` + tooDeep + `]}`
+	}
+
+	cases := []struct {
+		filter string
+		field  string
+	}{
+		{`{"field": "password_hash", "op": "eq", "value": "x"}`, "password_hash"},
+		{`{"field": "title", "op": "eq' OR 1=1 --", "value": "x"}`, "title"},
+		{`{"field": "title", "value": "x"}`, "title"},
+		{`{"field": "title", "op": "eq"}`, "title"},
+		{`{"field": "pages", "op": "eq", "value": "many"}`, "pages"},
+		{`{"field": "published_date", "op": "lt", "value": "yesterday"}`, "published_date"},
+		{`{"field": "pages", "op": "like", "value": "1%"}`, "pages"},
+		{`{"field": "language", "op": "in", "value": []}`, "language"},
+		{`{"field": "language", "op": "in", "value": "english"}`, "language"},
+		{`{"field": "pages", "op": "between", "value": [1]}`, "pages"},
+		{`{"field": "pages", "op": "between", "value": [1, 2, 3]}`, "pages"},
+		{`{"field": "title", "op": "eq", "value": "Dune", "and": [{"field": "pages", "op": "eq", "value": 1}]}`, "title"},
+		{`{"and": [{"field": "title", "op": "eq", "value": "Dune"}], "or": [{"field": "pages", "op": "eq", "value": 1}]}`, ""},
+		{`{"and": [{"op": "eq", "value": "Dune"}]}`, ""},
+		{tooDeep, ""},
+	}
+
+	for _, tc := range cases {
+		_, _, err := compile(t, tc.filter)
+		var filterErr *Error
+		if !errors.As(err, &filterErr) {
+			t.Fatalf("%s: expected a filter error, got %v", tc.filter, err)
+		}
+
+		if filterErr.Field != tc.field {
+			t.Fatalf("%s: expected the error on field %q, got %q", tc.filter, tc.field, filterErr.Field)
+		}
+
+		if tc.field != "" && !strings.Contains(err.Error(), `"`+tc.field+`"`) {
+			t.Fatalf("%s: expected the error to name the field, got %s", tc.filter, err)
+		}
+	}
+}
+
+func TestCompileLimitsSize(t *testing.T) {
+	values := make([]string, maxInValues+1)
+	for idx := range values {
+		values[idx] = `"english"`
+	}
+	if _, _, err := compile(t, `{"field": "language", "op": "in", "value": [`+strings.Join(values, ",")+`]}`); err == nil {
+		t.Fatalf("expected more than %d in values to fail", maxInValues)
+	}
+
+	conditions := make([]string, maxConditions+1)
+	for idx := range conditions {
+		conditions[idx] = `{"field": "title", "op": "eq", "value": "Dune"}`
+	}
+	if _, _, err := compile(t, `{"or": [`+strings.Join(conditions, ",")+`]}`); err == nil {
+		t.Fatalf("expected more than %d conditions to fail", maxConditions)
+	}
+}
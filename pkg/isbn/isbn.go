@@ -0,0 +1,138 @@
+// Package isbn validates International Standard Book Numbers and converts
+// between their forms. Books are kept under their canonical form, the
+// ISBN-13 without hyphens.
+//
+//	isbn.Parse("0-261-10325-3") // "9780261103252"
+package isbn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalid is wrapped by every error for a malformed ISBN.
+var ErrInvalid = errors.New("invalid isbn")
+
+// Clean drops the hyphens and spaces ISBNs are printed with and upper cases
+// the X check digit of an ISBN-10.
+func Clean(value string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(value)))
+}
+
+// Parse validates an ISBN-10 or ISBN-13, hyphenated or not, and returns its
+// canonical ISBN-13.
+func Parse(value string) (string, error) {
+	cleaned := Clean(value)
+	switch len(cleaned) {
+	case 10:
+		return To13(cleaned)
+	case 13:
+		if !Valid13(cleaned) {
+			return "", fmt.Errorf("%w: %s has a wrong check digit or is not an ISBN-13", ErrInvalid, value)
+		}
+		return cleaned, nil
+	}
+	return "", fmt.Errorf("%w: %s is neither 10 nor 13 characters long", ErrInvalid, value)
+}
+
+// Normalize is the canonical ISBN-13 of a valid ISBN, anything else only
+// cleaned, so a lookup still compares like with like.
+func Normalize(value string) string {
+	if canonical, err := Parse(value); err == nil {
+		return canonical
+	}
+	return Clean(value)
+}
+
+// Valid10 tells whether a cleaned value is an ISBN-10: nine digits and a
+// check digit or X, the weighted sum divisible by 11.
+func Valid10(value string) bool {
+	if len(value) != 10 {
+		return false
+	}
+
+	sum := 0
+	for idx := 0; idx < 10; idx++ {
+		digit, ok := digitAt(value, idx)
+		if !ok {
+			if idx != 9 || value[idx] != 'X' {
+				return false
+			}
+			digit = 10
+		}
+		sum += (10 - idx) * digit
+	}
+	return sum%11 == 0
+}
+
+// Valid13 tells whether a cleaned value is an ISBN-13: thirteen digits under
+// the 978 or 979 prefix, weighted 1 and 3 alternately to a multiple of 10.
+func Valid13(value string) bool {
+	if len(value) != 13 || !(strings.HasPrefix(value, "978") || strings.HasPrefix(value, "979")) {
+		return false
+	}
+
+	for idx := 0; idx < 13; idx++ {
+		if _, ok := digitAt(value, idx); !ok {
+			return false
+		}
+	}
+	return checkDigit13(value[:12]) == value[12]
+}
+
+// To13 converts an ISBN-10 to its ISBN-13 under the 978 prefix.
+func To13(value string) (string, error) {
+	cleaned := Clean(value)
+	if !Valid10(cleaned) {
+		return "", fmt.Errorf("%w: %s has a wrong check digit or is not an ISBN-10", ErrInvalid, value)
+	}
+
+	body := "978" + cleaned[:9]
+	return body + string(checkDigit13(body)), nil
+}
+
+// To10 converts an ISBN-13 back to an ISBN-10. Only the 978 prefix has an
+// ISBN-10 form.
+func To10(value string) (string, error) {
+	cleaned := Clean(value)
+	if !Valid13(cleaned) {
+		return "", fmt.Errorf("%w: %s has a wrong check digit or is not an ISBN-13", ErrInvalid, value)
+	}
+
+	if !strings.HasPrefix(cleaned, "978") {
+		return "", fmt.Errorf("%w: %s has no ISBN-10 form", ErrInvalid, value)
+	}
+
+	body := cleaned[3:12]
+	sum := 0
+	for idx := 0; idx < 9; idx++ {
+		digit, _ := digitAt(body, idx)
+		sum += (10 - idx) * digit
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X", nil
+	}
+	return body + string(rune('0'+check)), nil
+}
+
+func checkDigit13(body string) byte {
+	sum := 0
+	for idx := 0; idx < 12; idx++ {
+		digit, _ := digitAt(body, idx)
+		if idx%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func digitAt(value string, idx int) (int, bool) {
+	if value[idx] < '0' || value[idx] > '9' {
+		return 0, false
+	}
+	return int(value[idx] - '0'), true
+}
@@ -0,0 +1,101 @@
+package isbn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCanonicalizes(t *testing.T) {
+	cases := []struct {
+		value     string
+		canonical string
+	}{
+		{"0-261-10325-3", "9780261103252"},
+		{"0261103253", "9780261103252"},
+		{"978-0-261-10325-2", "9780261103252"},
+		{" 978 0261103252 ", "9780261103252"},
+		{"080442957X", "9780804429573"},
+		{"0-8044-2957-x", "9780804429573"},
+		{"979-10-90636-07-1", "9791090636071"},
+	}
+
+	for _, tc := range cases {
+		canonical, err := Parse(tc.value)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.value, err)
+		}
+
+		if canonical != tc.canonical {
+			t.Fatalf("%s: expected %s, got %s", tc.value, tc.canonical, canonical)
+		}
+	}
+}
+
+func TestConvertsBetweenForms(t *testing.T) {
+	pairs := []struct {
+		isbn10 string
+		isbn13 string
+	}{
+		{"0261103253", "9780261103252"},
+		{"080442957X", "9780804429573"},
+		{"0306406152", "9780306406157"},
+	}
+
+	for _, pair := range pairs {
+		if isbn13, err := To13(pair.isbn10); err != nil || isbn13 != pair.isbn13 {
+			t.Fatalf("%s: expected %s, got %s, %v", pair.isbn10, pair.isbn13, isbn13, err)
+		}
+
+		if isbn10, err := To10(pair.isbn13); err != nil || isbn10 != pair.isbn10 {
+			t.Fatalf("%s: expected %s, got %s, %v", pair.isbn13, pair.isbn10, isbn10, err)
+		}
+	}
+}
+
+func TestNo10FormUnder979(t *testing.T) {
+	if !Valid13("9791090636071") {
+		t.Fatalf("expected 9791090636071 to be a valid ISBN-13")
+	}
+
+	if _, err := To10("9791090636071"); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected an ISBN-13 under 979 to have no ISBN-10 form, got %v", err)
+	}
+}
+
+func TestRejectsInvalid(t *testing.T) {
+	values := []string{
+		"0261103254",
+		"026110325X",
+		"9780261103253",
+		"9790261103252",
+		"9770261103252",
+		"X261103253",
+		"02611O3253",
+		"978026110325",
+		"",
+	}
+
+	for _, value := range values {
+		if canonical, err := Parse(value); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("%s: expected an invalid isbn, got %s, %v", value, canonical, err)
+		}
+	}
+
+	if _, err := To13("9780261103252"); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected an ISBN-13 not to convert to one, got %v", err)
+	}
+
+	if _, err := To10("0261103253"); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected an ISBN-10 not to convert to one, got %v", err)
+	}
+}
+
+func TestNormalizeKeepsInvalidCleaned(t *testing.T) {
+	if normalized := Normalize("0-261-10325-3"); normalized != "9780261103252" {
+		t.Fatalf("expected the canonical form, got %s", normalized)
+	}
+
+	if normalized := Normalize("0-261-10325-x"); normalized != "026110325X" {
+		t.Fatalf("expected an invalid isbn only cleaned, got %s", normalized)
+	}
+}
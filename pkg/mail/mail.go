@@ -0,0 +1,73 @@
+// Package mail sends plain text mails to members through an SMTP server. The
+// server is used as it comes, STARTTLS when it offers it and authentication
+// only when a username is configured, so a local stand-in without either
+// works for development.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/dutt23/lms/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+type smtpSender struct {
+	cfg *config.MailConfig
+}
+
+func NewSMTPSender(cfg *config.MailConfig) Sender {
+	return &smtpSender{cfg}
+}
+
+func (sender *smtpSender) Send(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(sender.cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address %s %w", sender.cfg.From, err)
+	}
+
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address %s %w", msg.To, err)
+	}
+
+	var auth smtp.Auth
+	if sender.cfg.Username != "" {
+		auth = smtp.PlainAuth("", sender.cfg.Username, sender.cfg.Password, sender.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(sender.cfg.Host, strconv.Itoa(sender.cfg.Port))
+	return smtp.SendMail(addr, auth, from.Address, []string{to.Address}, compose(from, to, msg))
+}
+
+// compose writes the message with the headers mail clients expect, the
+// subject encoded in case it is not ASCII.
+func compose(from, to *mail.Address, msg *Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.Body)
+	return buf.Bytes()
+}
@@ -0,0 +1,298 @@
+package marc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/isbn"
+)
+
+var (
+	yearPattern  = regexp.MustCompile(`\b(1[0-9]{3}|20[0-9]{2})\b`)
+	pagesPattern = regexp.MustCompile(`(\d+)\s*(?:p\b|pages|pp\b)`)
+	numPattern   = regexp.MustCompile(`\d+`)
+	isbnPattern  = regexp.MustCompile(`^[0-9Xx-]+`)
+)
+
+// languages maps MARC language codes onto the language names the catalogue
+// uses. Codes missing here are kept as they are.
+var languages = map[string]string{
+	"ara": "arabic",
+	"ben": "bengali",
+	"chi": "chinese",
+	"dan": "danish",
+	"dut": "dutch",
+	"eng": "english",
+	"fin": "finnish",
+	"fre": "french",
+	"ger": "german",
+	"gre": "greek",
+	"heb": "hebrew",
+	"hin": "hindi",
+	"ita": "italian",
+	"jpn": "japanese",
+	"kor": "korean",
+	"lat": "latin",
+	"nor": "norwegian",
+	"pol": "polish",
+	"por": "portuguese",
+	"rus": "russian",
+	"spa": "spanish",
+	"swe": "swedish",
+	"tam": "tamil",
+	"tur": "turkish",
+	"urd": "urdu",
+}
+
+// LanguageName returns the catalogue language for a MARC language code.
+func LanguageName(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if name, ok := languages[code]; ok {
+		return name
+	}
+	return code
+}
+
+// LanguageCode returns the MARC language code for a catalogue language.
+func LanguageCode(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for code, language := range languages {
+		if language == name {
+			return code
+		}
+	}
+	return name
+}
+
+// MappingError reports a record that lacks data a book needs.
+type MappingError struct {
+	Tag     string
+	Message string
+}
+
+func (err *MappingError) Error() string {
+	return fmt.Sprintf("field %s: %s", err.Tag, err.Message)
+}
+
+// ToBook maps a bibliographic record onto a book: 020 ISBN (the first valid
+// one, as ISBN-13), 100, 110, 111 and 700, 710, 711 contributors, 245 title,
+// 264 or 260 publication date, 300 pages and 041 (or 008) language. 650
+// topical terms become the subjects.
+func ToBook(record *Record) (*model.Book, error) {
+	book := &model.Book{}
+
+	for _, field := range record.Fields("020") {
+		number := isbnPattern.FindString(strings.TrimSpace(field.Subfield("a")))
+		if canonical, err := isbn.Parse(number); err == nil {
+			book.Isbn = canonical
+			break
+		}
+	}
+	if book.Isbn == "" {
+		return nil, &MappingError{"020", "no valid ISBN"}
+	}
+
+	for _, tag := range []string{"100", "110", "111", "700", "710", "711"} {
+		for _, field := range record.Fields(tag) {
+			if name := trimPunctuation(field.Subfield("a")); name != "" {
+				book.Contributors = append(book.Contributors, &model.BookContributor{Name: name, Role: relator(field)})
+			}
+		}
+	}
+
+	book.Author = model.AuthorStatement(book.Contributors)
+	if book.Author == "" {
+		return nil, &MappingError{"100", "no author"}
+	}
+
+	if fields := record.Fields("245"); len(fields) > 0 {
+		book.Title = trimPunctuation(fields[0].Subfield("a"))
+		if subtitle := trimPunctuation(fields[0].Subfield("b")); subtitle != "" {
+			book.Title = fmt.Sprintf("%s: %s", book.Title, subtitle)
+		}
+	}
+	if book.Title == "" {
+		return nil, &MappingError{"245", "no title"}
+	}
+
+	year := publicationYear(record)
+	if year == 0 {
+		return nil, &MappingError{"264", "no publication date"}
+	}
+	book.PublishedDate = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	book.NumberOfPages = pages(record)
+	if book.NumberOfPages == 0 {
+		return nil, &MappingError{"300", "no page count"}
+	}
+
+	book.Language = language(record)
+	if book.Language == "" {
+		return nil, &MappingError{"041", "no language"}
+	}
+
+	var subjects []string
+	for _, field := range record.Fields("650") {
+		if subject := trimPunctuation(field.Subfield("a")); subject != "" {
+			subjects = append(subjects, subject)
+		}
+	}
+	book.Subjects = strings.Join(subjects, "; ")
+
+	return book, nil
+}
+
+// publicationYear prefers the 264 publication statement, then 260, then the
+// date in the 008 fixed field.
+func publicationYear(record *Record) int {
+	var candidates []string
+	for _, field := range record.Fields("264") {
+		if field.Ind2 == "1" {
+			candidates = append(candidates, field.Subfield("c"))
+		}
+	}
+
+	for _, field := range record.Fields("260") {
+		candidates = append(candidates, field.Subfield("c"))
+	}
+
+	if fixed := record.ControlField("008"); len(fixed) >= 11 {
+		candidates = append(candidates, fixed[7:11])
+	}
+
+	for _, candidate := range candidates {
+		if match := yearPattern.FindString(candidate); match != "" {
+			year, _ := strconv.Atoi(match)
+			return year
+		}
+	}
+	return 0
+}
+
+func pages(record *Record) uint64 {
+	for _, field := range record.Fields("300") {
+		extent := field.Subfield("a")
+		match := pagesPattern.FindStringSubmatch(extent)
+		if match == nil {
+			match = []string{"", numPattern.FindString(extent)}
+		}
+
+		if count, err := strconv.ParseUint(match[1], 10, 64); err == nil && count > 0 {
+			return count
+		}
+	}
+	return 0
+}
+
+func language(record *Record) string {
+	for _, field := range record.Fields("041") {
+		if code := field.Subfield("a"); len(code) >= 3 {
+			return LanguageName(code[:3])
+		}
+	}
+
+	if fixed := record.ControlField("008"); len(fixed) >= 38 {
+		if code := strings.TrimSpace(fixed[35:38]); code != "" && code != "|||" {
+			return LanguageName(code)
+		}
+	}
+	return ""
+}
+
+// relator is the role of a 1XX or 7XX contributor, from the $4 code or the $e
+// term. Main entries default to author, added entries to contributor.
+func relator(field *DataField) string {
+	if code := strings.TrimSpace(field.Subfield("4")); model.ContributorRoles[code] != "" {
+		return code
+	}
+
+	term := strings.ToLower(trimPunctuation(field.Subfield("e")))
+	for code, label := range model.ContributorRoles {
+		if term != "" && term == label {
+			return code
+		}
+	}
+
+	if strings.HasPrefix(field.Tag, "1") {
+		return model.RoleAuthor
+	}
+	return model.RoleContributor
+}
+
+// trimPunctuation strips the ISBD punctuation cataloguers end subfields with.
+func trimPunctuation(value string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(value), " /:;,.="))
+}
+
+// FromBook renders a book as a MARC bibliographic record, the reverse of
+// ToBook. The book id becomes the 001 control number, the first author
+// credit the 100 main entry and the other credits 700 added entries with
+// their relator. A book read without its credits has its author statement as
+// the main entry.
+func FromBook(book *model.Book) *Record {
+	year := fmt.Sprintf("%04d", book.PublishedDate.Year())
+	language := LanguageCode(book.Language)
+	if len(language) != 3 {
+		language = "und"
+	}
+
+	record := &Record{
+		Leader: "00000nam a2200000 a 4500",
+		ControlFields: []*ControlField{
+			{Tag: "001", Value: strconv.FormatUint(book.Id, 10)},
+			{Tag: "008", Value: fmt.Sprintf("%6ss%s    xx %17s%s d", "", year, "", language)},
+		},
+	}
+
+	record.addDataField("020", "  ", "a", book.Isbn)
+	credits := book.Contributors
+	if len(credits) == 0 {
+		record.addDataField("100", "1 ", "a", book.Author)
+	} else if credits[0].Role == model.RoleAuthor {
+		record.addDataField("100", "1 ", "a", credits[0].Name, "e", model.ContributorRoles[model.RoleAuthor], "4", model.RoleAuthor)
+		credits = credits[1:]
+	}
+
+	if title, subtitle, ok := strings.Cut(book.Title, ": "); ok {
+		record.addDataField("245", "10", "a", title+" :", "b", subtitle)
+	} else {
+		record.addDataField("245", "10", "a", book.Title)
+	}
+
+	record.addDataField("264", " 1", "c", year)
+	record.addDataField("300", "  ", "a", fmt.Sprintf("%d pages", book.NumberOfPages))
+
+	for _, subject := range strings.Split(book.Subjects, ";") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			record.addDataField("650", " 0", "a", subject)
+		}
+	}
+
+	for _, credit := range credits {
+		record.addDataField("700", "1 ", "a", credit.Name, "e", model.ContributorRoles[credit.Role], "4", credit.Role)
+	}
+
+	if book.CoverImage != "" {
+		record.addDataField("856", "42", "3", "Cover image", "u", book.CoverImage)
+	}
+	return record
+}
+
+// addDataField appends a field built from the indicators and code, value
+// pairs, leaving out subfields without a value.
+func (record *Record) addDataField(tag, indicators string, subfields ...string) {
+	field := &DataField{Tag: tag, Ind1: indicators[:1], Ind2: indicators[1:]}
+	for idx := 0; idx+1 < len(subfields); idx += 2 {
+		if subfields[idx+1] != "" {
+			field.Subfields = append(field.Subfields, &Subfield{Code: subfields[idx], Value: subfields[idx+1]})
+		}
+	}
+
+	if len(field.Subfields) > 0 {
+		record.DataFields = append(record.DataFields, field)
+	}
+}
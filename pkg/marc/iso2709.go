@@ -0,0 +1,151 @@
+package marc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+
+	leaderLength         = 24
+	directoryEntryLength = 12
+)
+
+// Reader reads records in the ISO 2709 exchange format. Field data is taken
+// as UTF-8 (leader position 9 set to 'a'); invalid sequences are replaced.
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	if buffered, ok := r.(*bufio.Reader); ok {
+		return &Reader{buffered}
+	}
+	return &Reader{bufio.NewReader(r)}
+}
+
+func (reader *Reader) Read() (*Record, error) {
+	// some exports put line breaks between records
+	for {
+		b, err := reader.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if b[0] != '\r' && b[0] != '\n' {
+			break
+		}
+		reader.r.ReadByte()
+	}
+
+	var prefix [5]byte
+	if _, err := io.ReadFull(reader.r, prefix[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	length, err := strconv.Atoi(string(prefix[:]))
+	if err != nil || length <= leaderLength {
+		return nil, fmt.Errorf("invalid record length %q", string(prefix[:]))
+	}
+
+	data := make([]byte, length)
+	copy(data, prefix[:])
+	if _, err := io.ReadFull(reader.r, data[len(prefix):]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	record, err := parseRecord(data)
+	if err != nil {
+		return nil, &RecordError{err}
+	}
+	return record, nil
+}
+
+func parseRecord(data []byte) (*Record, error) {
+	if data[len(data)-1] != recordTerminator {
+		return nil, errors.New("missing record terminator")
+	}
+
+	leader := string(data[:leaderLength])
+	base, err := strconv.Atoi(leader[12:17])
+	if err != nil || base <= leaderLength || base > len(data) {
+		return nil, fmt.Errorf("invalid base address of data %q", leader[12:17])
+	}
+
+	directory := data[leaderLength : base-1]
+	if len(directory)%directoryEntryLength != 0 {
+		return nil, errors.New("directory length is not a multiple of 12")
+	}
+
+	record := &Record{Leader: leader}
+	for idx := 0; idx < len(directory); idx += directoryEntryLength {
+		entry := string(directory[idx : idx+directoryEntryLength])
+		tag := entry[:3]
+		length, lengthErr := strconv.Atoi(entry[3:7])
+		start, startErr := strconv.Atoi(entry[7:12])
+		if lengthErr != nil || startErr != nil {
+			return nil, fmt.Errorf("invalid directory entry for field %s", tag)
+		}
+
+		end := base + start + length
+		if length <= 0 || start < 0 || end > len(data) {
+			return nil, fmt.Errorf("field %s lies outside of the record", tag)
+		}
+
+		field := data[base+start : end]
+		if field[len(field)-1] == fieldTerminator {
+			field = field[:len(field)-1]
+		}
+
+		if isControlTag(tag) {
+			record.ControlFields = append(record.ControlFields, &ControlField{Tag: tag, Value: text(field)})
+			continue
+		}
+
+		dataField, err := parseDataField(tag, field)
+		if err != nil {
+			return nil, err
+		}
+		record.DataFields = append(record.DataFields, dataField)
+	}
+	return record, nil
+}
+
+func parseDataField(tag string, field []byte) (*DataField, error) {
+	if len(field) < 2 {
+		return nil, fmt.Errorf("field %s has no indicators", tag)
+	}
+
+	dataField := &DataField{Tag: tag, Ind1: string(field[0]), Ind2: string(field[1])}
+	for idx, part := range strings.Split(string(field[2:]), string(rune(subfieldDelimiter))) {
+		// anything before the first delimiter is not part of a subfield
+		if idx == 0 || part == "" {
+			continue
+		}
+		dataField.Subfields = append(dataField.Subfields, &Subfield{Code: part[:1], Value: text([]byte(part[1:]))})
+	}
+	return dataField, nil
+}
+
+// isControlTag tells the 001-009 control fields apart from data fields.
+func isControlTag(tag string) bool {
+	return strings.HasPrefix(tag, "00")
+}
+
+func text(b []byte) string {
+	return strings.ToValidUTF8(string(b), "�")
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
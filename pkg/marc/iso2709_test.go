@@ -0,0 +1,67 @@
+package marc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// iso2709 lays out a record from its directory entries and field data, the
+// leader giving the record length and base address of data.
+func iso2709(directory, fields string) string {
+	base := leaderLength + len(directory) + 1
+	length := base + len(fields) + 1
+	leader := fmt.Sprintf("%05dnam a22%05d   4500", length, base)
+	return leader + directory + string(rune(fieldTerminator)) + fields + string(rune(recordTerminator))
+}
+
+func titleRecord(title string) string {
+	field := "10" + string(rune(subfieldDelimiter)) + "a" + title + string(rune(fieldTerminator))
+	return iso2709(fmt.Sprintf("245%04d%05d", len(field), 0), field)
+}
+
+func TestReaderReadsRecord(t *testing.T) {
+	record, err := NewReader(strings.NewReader(titleRecord("Dune"))).Read()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	fields := record.Fields("245")
+	if len(fields) != 1 || fields[0].Subfield("a") != "Dune" {
+		t.Fatalf("unexpected title fields %+v", fields)
+	}
+}
+
+func TestReaderRejectsFieldsOutsideOfRecord(t *testing.T) {
+	field := "10" + string(rune(subfieldDelimiter)) + "aDune" + string(rune(fieldTerminator))
+	cases := map[string]string{
+		"negative length": "245-99900000",
+		"negative start":  "2450008-0001",
+		"zero length":     "245000000000",
+		"past the end":    "245000800100",
+	}
+
+	for name, entry := range cases {
+		t.Run(name, func(t *testing.T) {
+			reader := NewReader(strings.NewReader(iso2709(entry, field) + titleRecord("Emma")))
+
+			_, err := reader.Read()
+			var recordErr *RecordError
+			if !errors.As(err, &recordErr) {
+				t.Fatalf("expected a record error, got %v", err)
+			}
+
+			// the malformed record is skipped and reading goes on
+			record, err := reader.Read()
+			if err != nil || record.Fields("245")[0].Subfield("a") != "Emma" {
+				t.Fatalf("expected the next record, got %v %v", record, err)
+			}
+
+			if _, err := reader.Read(); err != io.EOF {
+				t.Fatalf("expected the end of input, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Package marc reads MARC 21 bibliographic records, either in the ISO 2709
+// exchange format or as MARCXML, and maps them onto catalogue books and back.
+package marc
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Record is a MARC record. The xml tags follow the MARCXML slim schema so a
+// record decodes from and encodes to MARCXML as is.
+type Record struct {
+	XMLName       xml.Name        `xml:"record"`
+	Leader        string          `xml:"leader"`
+	ControlFields []*ControlField `xml:"controlfield"`
+	DataFields    []*DataField    `xml:"datafield"`
+}
+
+// ControlField is one of the 00X fields, which carry a plain value.
+type ControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type DataField struct {
+	Tag       string      `xml:"tag,attr"`
+	Ind1      string      `xml:"ind1,attr"`
+	Ind2      string      `xml:"ind2,attr"`
+	Subfields []*Subfield `xml:"subfield"`
+}
+
+type Subfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// RecordReader reads records one at a time. Read returns io.EOF once the
+// input is exhausted. A *RecordError means only the current record was
+// unusable and reading can go on; any other error ends the input.
+type RecordReader interface {
+	Read() (*Record, error)
+}
+
+// RecordError reports a record that could not be parsed.
+type RecordError struct {
+	Err error
+}
+
+func (err *RecordError) Error() string {
+	return fmt.Sprintf("malformed record: %s", err.Err)
+}
+
+func (err *RecordError) Unwrap() error {
+	return err.Err
+}
+
+// NewRecordReader picks the MARCXML or the ISO 2709 reader by looking at the
+// start of the input.
+func NewRecordReader(r io.Reader) (RecordReader, error) {
+	buffered := bufio.NewReader(r)
+	for {
+		b, err := buffered.Peek(1)
+		if err == io.EOF {
+			return NewReader(buffered), nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch b[0] {
+		case ' ', '\t', '\r', '\n', 0xEF, 0xBB, 0xBF:
+			buffered.ReadByte()
+			continue
+		case '<':
+			return NewXMLReader(buffered), nil
+		default:
+			return NewReader(buffered), nil
+		}
+	}
+}
+
+// ControlField returns the value of the first control field with the tag.
+func (record *Record) ControlField(tag string) string {
+	for _, field := range record.ControlFields {
+		if field.Tag == tag {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// Fields returns the data fields with the tag in record order.
+func (record *Record) Fields(tag string) []*DataField {
+	var fields []*DataField
+	for _, field := range record.DataFields {
+		if field.Tag == tag {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Subfield returns the first value of the subfield with the code.
+func (field *DataField) Subfield(code string) string {
+	for _, subfield := range field.Subfields {
+		if subfield.Code == code {
+			return subfield.Value
+		}
+	}
+	return ""
+}
+
+// SubfieldValues returns every value of the subfield with the code.
+func (field *DataField) SubfieldValues(code string) []string {
+	var values []string
+	for _, subfield := range field.Subfields {
+		if subfield.Code == code {
+			values = append(values, subfield.Value)
+		}
+	}
+	return values
+}
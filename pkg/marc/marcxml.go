@@ -0,0 +1,114 @@
+package marc
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Namespace is the MARCXML slim schema namespace.
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+// MediaType is the registered media type of MARCXML documents.
+const MediaType = "application/marcxml+xml"
+
+var (
+	namespace  = []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}}
+	collection = xml.StartElement{Name: xml.Name{Local: "collection"}, Attr: namespace}
+	xmlHeader  = xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)}
+)
+
+// XMLReader streams the record elements of a MARCXML document, whether they
+// sit in a collection or make up the whole document.
+type XMLReader struct {
+	decoder *xml.Decoder
+}
+
+func NewXMLReader(r io.Reader) *XMLReader {
+	return &XMLReader{xml.NewDecoder(r)}
+}
+
+func (reader *XMLReader) Read() (*Record, error) {
+	for {
+		token, err := reader.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var record Record
+		if err := reader.decoder.DecodeElement(&record, &start); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+}
+
+// MarshalXML declares the MARCXML namespace on every record, so a record
+// stays MARCXML when it is embedded in another document.
+func (record *Record) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	type plain Record
+	start = xml.StartElement{Name: xml.Name{Local: "record"}, Attr: namespace}
+	return encoder.EncodeElement((*plain)(record), start)
+}
+
+// WriteXML writes a record as a MARCXML document of its own.
+func WriteXML(w io.Writer, record *Record) error {
+	encoder := xml.NewEncoder(w)
+	if err := encoder.EncodeToken(xmlHeader); err != nil {
+		return err
+	}
+
+	if err := encoder.Encode(record); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+// XMLWriter streams records into a MARCXML collection. Close ends the
+// collection; output is buffered until Flush or Close.
+type XMLWriter struct {
+	encoder *xml.Encoder
+	started bool
+}
+
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	return &XMLWriter{encoder: xml.NewEncoder(w)}
+}
+
+func (writer *XMLWriter) start() error {
+	if writer.started {
+		return nil
+	}
+	writer.started = true
+
+	if err := writer.encoder.EncodeToken(xmlHeader); err != nil {
+		return err
+	}
+	return writer.encoder.EncodeToken(collection)
+}
+
+func (writer *XMLWriter) Write(record *Record) error {
+	if err := writer.start(); err != nil {
+		return err
+	}
+	return writer.encoder.Encode(record)
+}
+
+func (writer *XMLWriter) Flush() error {
+	return writer.encoder.Flush()
+}
+
+func (writer *XMLWriter) Close() error {
+	if err := writer.start(); err != nil {
+		return err
+	}
+
+	if err := writer.encoder.EncodeToken(collection.End()); err != nil {
+		return err
+	}
+	return writer.encoder.Flush()
+}
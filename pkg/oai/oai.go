@@ -0,0 +1,189 @@
+// Package oai holds the OAI-PMH 2.0 protocol elements a repository answers
+// harvesters with, and the datestamp and resumption token formats it uses.
+package oai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+const (
+	Namespace       = "http://www.openarchives.org/OAI/2.0/"
+	Schema          = "http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd"
+	ProtocolVersion = "2.0"
+
+	// Granularity is the finest datestamp precision the repository keeps.
+	Granularity = "YYYY-MM-DDThh:mm:ssZ"
+
+	DatestampFormat = "2006-01-02T15:04:05Z"
+	DayFormat       = "2006-01-02"
+)
+
+const (
+	ErrBadArgument             = "badArgument"
+	ErrBadResumptionToken      = "badResumptionToken"
+	ErrBadVerb                 = "badVerb"
+	ErrCannotDisseminateFormat = "cannotDisseminateFormat"
+	ErrIdDoesNotExist          = "idDoesNotExist"
+	ErrNoRecordsMatch          = "noRecordsMatch"
+	ErrNoSetHierarchy          = "noSetHierarchy"
+)
+
+// Error is an OAI-PMH error condition. It is reported in the response body;
+// the HTTP status stays 200.
+type Error struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+func (err *Error) Error() string {
+	return err.Code + ": " + err.Message
+}
+
+func NewError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Request echoes the request in a response. The arguments are left out when
+// the verb or the arguments were refused.
+type Request struct {
+	Verb            string `xml:"verb,attr,omitempty"`
+	Identifier      string `xml:"identifier,attr,omitempty"`
+	MetadataPrefix  string `xml:"metadataPrefix,attr,omitempty"`
+	From            string `xml:"from,attr,omitempty"`
+	Until           string `xml:"until,attr,omitempty"`
+	Set             string `xml:"set,attr,omitempty"`
+	ResumptionToken string `xml:"resumptionToken,attr,omitempty"`
+	BaseURL         string `xml:",chardata"`
+}
+
+type Response struct {
+	XMLName        xml.Name `xml:"OAI-PMH"`
+	Xmlns          string   `xml:"xmlns,attr"`
+	XSI            string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	ResponseDate   string   `xml:"responseDate"`
+	Request        *Request `xml:"request"`
+	Errors         []*Error `xml:"error"`
+
+	Identify            *Identify            `xml:"Identify"`
+	ListMetadataFormats *ListMetadataFormats `xml:"ListMetadataFormats"`
+	ListSets            *ListSets            `xml:"ListSets"`
+	GetRecord           *GetRecord           `xml:"GetRecord"`
+	ListIdentifiers     *ListIdentifiers     `xml:"ListIdentifiers"`
+	ListRecords         *ListRecords         `xml:"ListRecords"`
+}
+
+func NewResponse(request *Request, now time.Time) *Response {
+	return &Response{
+		Xmlns:          Namespace,
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: Namespace + " " + Schema,
+		ResponseDate:   Datestamp(now),
+		Request:        request,
+	}
+}
+
+type Identify struct {
+	RepositoryName    string   `xml:"repositoryName"`
+	BaseURL           string   `xml:"baseURL"`
+	ProtocolVersion   string   `xml:"protocolVersion"`
+	AdminEmail        []string `xml:"adminEmail"`
+	EarliestDatestamp string   `xml:"earliestDatestamp"`
+	DeletedRecord     string   `xml:"deletedRecord"`
+	Granularity       string   `xml:"granularity"`
+}
+
+type MetadataFormat struct {
+	MetadataPrefix    string `xml:"metadataPrefix"`
+	Schema            string `xml:"schema"`
+	MetadataNamespace string `xml:"metadataNamespace"`
+}
+
+type ListMetadataFormats struct {
+	MetadataFormats []*MetadataFormat `xml:"metadataFormat"`
+}
+
+type Set struct {
+	SetSpec string `xml:"setSpec"`
+	SetName string `xml:"setName"`
+}
+
+type ListSets struct {
+	Sets []*Set `xml:"set"`
+}
+
+type Header struct {
+	Identifier string   `xml:"identifier"`
+	Datestamp  string   `xml:"datestamp"`
+	SetSpecs   []string `xml:"setSpec"`
+}
+
+// Record pairs a header with the metadata, any value that marshals to the
+// element of its format.
+type Record struct {
+	Header   *Header   `xml:"header"`
+	Metadata *Metadata `xml:"metadata"`
+}
+
+type Metadata struct {
+	Value any
+}
+
+type GetRecord struct {
+	Record *Record `xml:"record"`
+}
+
+// ResumptionToken continues an incomplete list. The last response of such a
+// list carries one with an empty token.
+type ResumptionToken struct {
+	CompleteListSize int64  `xml:"completeListSize,attr"`
+	Cursor           int64  `xml:"cursor,attr"`
+	Token            string `xml:",chardata"`
+}
+
+type ListIdentifiers struct {
+	Headers         []*Header        `xml:"header"`
+	ResumptionToken *ResumptionToken `xml:"resumptionToken"`
+}
+
+type ListRecords struct {
+	Records         []*Record        `xml:"record"`
+	ResumptionToken *ResumptionToken `xml:"resumptionToken"`
+}
+
+// Datestamp formats a time at the repository's granularity.
+func Datestamp(t time.Time) string {
+	return t.UTC().Format(DatestampFormat)
+}
+
+// Token is the state a resumption token carries: the arguments of the list
+// request and where the previous response stopped.
+type Token struct {
+	MetadataPrefix string `json:"p"`
+	From           string `json:"f,omitempty"`
+	Until          string `json:"u,omitempty"`
+	Set            string `json:"s,omitempty"`
+	LastId         uint64 `json:"id"`
+	Cursor         int64  `json:"n"`
+}
+
+func (token *Token) Encode() string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeToken(value string) (*Token, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, NewError(ErrBadResumptionToken, "the resumption token is invalid")
+	}
+
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil || token.MetadataPrefix == "" {
+		return nil, NewError(ErrBadResumptionToken, "the resumption token is invalid")
+	}
+	return &token, nil
+}
@@ -0,0 +1,89 @@
+package openlibrary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dutt23/lms/model"
+)
+
+const ClientSource = "openlibrary"
+
+// maxAuthors caps the author records fetched for an edition.
+const maxAuthors = 5
+
+// Client looks editions up over HTTP with the Open Library books API,
+// /isbn/<isbn>.json for the edition and /authors/<id>.json for every author.
+// The base URL can point at https://openlibrary.org or at a local stub
+// serving the same paths.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// LookupIsbn returns the metadata of the edition with the ISBN, nil when the
+// server has none. An author that can't be fetched is left out.
+func (client *Client) LookupIsbn(ctx context.Context, number string) (*model.BookMetadata, error) {
+	var edition Edition
+	found, err := client.get(ctx, "/isbn/"+number+".json", &edition)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var authors []string
+	for idx, key := range edition.Authors {
+		if idx == maxAuthors {
+			break
+		}
+
+		var author Author
+		found, err := client.get(ctx, key.Key+".json", &author)
+		if err != nil {
+			fmt.Println("unable to fetch open library author ", key.Key, err)
+			continue
+		}
+
+		if found && author.Name != "" {
+			authors = append(authors, author.Name)
+		}
+	}
+	return edition.Metadata(number, authors, ClientSource), nil
+}
+
+func (client *Client) get(ctx context.Context, path string, v any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.http.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("unable to reach open library %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("open library answered %s for %s", res.Status, path)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("unable to read open library response %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,146 @@
+package openlibrary
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/dutt23/lms/model"
+)
+
+const DumpSource = "openlibrary-dump"
+
+// Dump looks editions up in a local Open Library data dump, the tab separated
+// type, key, revision, last modified and JSON record lines of
+// https://openlibrary.org/developers/dumps, or a file of bare JSON records.
+// Edition and author records can be mixed in one file.
+//
+// The file is indexed on the first lookup: the offset of every edition by
+// ISBN and the name of every author are kept in memory, so it is meant for a
+// dump filtered down to the collection. Changes to the file need a restart.
+type Dump struct {
+	path string
+
+	once     sync.Once
+	err      error
+	editions map[string]int64
+	authors  map[string]string
+}
+
+func NewDump(path string) *Dump {
+	return &Dump{path: path}
+}
+
+type dumpRecord struct {
+	Type Key `json:"type"`
+	Edition
+	Name string `json:"name"`
+}
+
+// LookupIsbn returns the metadata of the edition with the ISBN, nil when the
+// dump has none.
+func (dump *Dump) LookupIsbn(ctx context.Context, number string) (*model.BookMetadata, error) {
+	dump.once.Do(func() {
+		dump.err = dump.index()
+	})
+	if dump.err != nil {
+		return nil, dump.err
+	}
+
+	offset, ok := dump.editions[number]
+	if !ok {
+		return nil, nil
+	}
+
+	record, err := dump.readAt(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var authors []string
+	for _, author := range record.Authors {
+		if name := dump.authors[author.Key]; name != "" {
+			authors = append(authors, name)
+		}
+	}
+	return record.Edition.Metadata(number, authors, DumpSource), nil
+}
+
+func (dump *Dump) index() error {
+	file, err := os.Open(dump.path)
+	if err != nil {
+		return fmt.Errorf("unable to open open library dump %w", err)
+	}
+	defer file.Close()
+
+	dump.editions = map[string]int64{}
+	dump.authors = map[string]string{}
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if record, ok := parseDumpLine(line); ok {
+				switch record.Type.Key {
+				case "/type/edition":
+					for _, number := range record.Isbns() {
+						dump.editions[number] = offset
+					}
+				case "/type/author":
+					dump.authors[record.Key] = record.Name
+				}
+			}
+			offset += int64(len(line))
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read open library dump %w", err)
+		}
+	}
+}
+
+func (dump *Dump) readAt(offset int64) (*dumpRecord, error) {
+	file, err := os.Open(dump.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open open library dump %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	record, ok := parseDumpLine(line)
+	if !ok {
+		return nil, fmt.Errorf("open library dump changed since it was indexed")
+	}
+	return record, nil
+}
+
+// parseDumpLine reads the JSON record of a line, the last column of a tab
+// separated one. Lines that are not records are skipped.
+func parseDumpLine(line []byte) (*dumpRecord, bool) {
+	if idx := bytes.LastIndexByte(line, '\t'); idx >= 0 {
+		line = line[idx+1:]
+	}
+
+	var record dumpRecord
+	if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+		return nil, false
+	}
+	return &record, true
+}
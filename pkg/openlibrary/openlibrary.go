@@ -0,0 +1,112 @@
+// Package openlibrary reads Open Library edition records, from a local data
+// dump or over HTTP, and maps them onto book metadata.
+package openlibrary
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/isbn"
+	"github.com/dutt23/lms/pkg/marc"
+)
+
+// CoverURL is where Open Library serves the large image of a cover id.
+const CoverURL = "https://covers.openlibrary.org/b/id/%d-L.jpg"
+
+var yearPattern = regexp.MustCompile(`\b(1[0-9]{3}|20[0-9]{2})\b`)
+
+// publishDateLayouts are the forms of publish_date full enough to keep the
+// day, anything else only gives its year.
+var publishDateLayouts = []string{"2006-01-02", "January 2, 2006", "Jan 2, 2006", "2 January 2006", "January 2006", "Jan 2006"}
+
+type Key struct {
+	Key string `json:"key"`
+}
+
+// Edition is the part of an Open Library edition record a book is filled in
+// from.
+type Edition struct {
+	Key           string   `json:"key"`
+	Title         string   `json:"title"`
+	Subtitle      string   `json:"subtitle"`
+	Authors       []Key    `json:"authors"`
+	ByStatement   string   `json:"by_statement"`
+	PublishDate   string   `json:"publish_date"`
+	NumberOfPages uint64   `json:"number_of_pages"`
+	Isbn10        []string `json:"isbn_10"`
+	Isbn13        []string `json:"isbn_13"`
+	Covers        []int64  `json:"covers"`
+	Languages     []Key    `json:"languages"`
+	Subjects      []string `json:"subjects"`
+}
+
+type Author struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// Isbns lists the canonical ISBN-13s of the edition's valid ISBNs.
+func (edition *Edition) Isbns() []string {
+	var isbns []string
+	for _, value := range append(append([]string{}, edition.Isbn13...), edition.Isbn10...) {
+		if canonical, err := isbn.Parse(value); err == nil {
+			isbns = append(isbns, canonical)
+		}
+	}
+	return isbns
+}
+
+// Metadata maps the edition onto book metadata. Author names come from the
+// author records, by_statement standing in when there are none.
+func (edition *Edition) Metadata(number string, authors []string, source string) *model.BookMetadata {
+	metadata := &model.BookMetadata{
+		Isbn:          number,
+		Title:         edition.Title,
+		Author:        strings.Join(authors, "; "),
+		PublishedDate: publishDate(edition.PublishDate),
+		NumberOfPages: edition.NumberOfPages,
+		Subjects:      strings.Join(edition.Subjects, "; "),
+		Source:        source,
+	}
+
+	if edition.Subtitle != "" {
+		metadata.Title = fmt.Sprintf("%s: %s", edition.Title, edition.Subtitle)
+	}
+
+	if metadata.Author == "" {
+		metadata.Author = strings.TrimRight(strings.TrimSpace(edition.ByStatement), ".")
+	}
+
+	for _, cover := range edition.Covers {
+		// a negative id marks a removed cover
+		if cover > 0 {
+			metadata.CoverURL = fmt.Sprintf(CoverURL, cover)
+			break
+		}
+	}
+
+	if len(edition.Languages) > 0 {
+		metadata.Language = marc.LanguageName(strings.TrimPrefix(edition.Languages[0].Key, "/languages/"))
+	}
+	return metadata
+}
+
+func publishDate(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range publishDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
+
+	if match := yearPattern.FindString(value); match != "" {
+		year, _ := strconv.Atoi(match)
+		t := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Package sru holds the SRU 1.2 (Search/Retrieve via URL) response elements
+// of the explain and searchRetrieve operations and the diagnostics they
+// report.
+package sru
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+const (
+	Namespace           = "http://www.loc.gov/zing/srw/"
+	DiagnosticNamespace = "http://www.loc.gov/zing/srw/diagnostic/"
+	ExplainNamespace    = "http://explain.z3950.org/dtd/2.0/"
+	Version             = "1.2"
+
+	OperationExplain        = "explain"
+	OperationSearchRetrieve = "searchRetrieve"
+
+	PackingXML    = "xml"
+	PackingString = "string"
+)
+
+// Diagnostics the protocol layer reports, the query ones live with the
+// parser.
+const (
+	DiagnosticUnsupportedOperation    = 4
+	DiagnosticUnsupportedVersion      = 5
+	DiagnosticUnsupportedParamValue   = 6
+	DiagnosticMissingParameter        = 7
+	DiagnosticUnsupportedParameter    = 8
+	DiagnosticFirstRecordOutOfRange   = 61
+	DiagnosticUnsupportedRecordSchema = 66
+	DiagnosticUnsupportedPacking      = 71
+)
+
+// Diagnostic is an SRU error condition. It is reported in the response body,
+// the HTTP status stays 200.
+type Diagnostic struct {
+	Uri     string `xml:"diag:uri"`
+	Details string `xml:"diag:details,omitempty"`
+	Message string `xml:"diag:message,omitempty"`
+}
+
+func (diagnostic *Diagnostic) Error() string {
+	return diagnostic.Uri + ": " + diagnostic.Message
+}
+
+func NewDiagnostic(code int, details, message string) *Diagnostic {
+	return &Diagnostic{
+		Uri:     fmt.Sprintf("info:srw/diagnostic/1/%d", code),
+		Details: details,
+		Message: message,
+	}
+}
+
+type Diagnostics struct {
+	Xmlns       string        `xml:"xmlns:diag,attr"`
+	Diagnostics []*Diagnostic `xml:"diag:diagnostic"`
+}
+
+func NewDiagnostics(diagnostics ...*Diagnostic) *Diagnostics {
+	return &Diagnostics{Xmlns: DiagnosticNamespace, Diagnostics: diagnostics}
+}
+
+// EchoedRequest repeats the request arguments in a searchRetrieve response.
+type EchoedRequest struct {
+	Version        string `xml:"srw:version"`
+	Query          string `xml:"srw:query,omitempty"`
+	StartRecord    int    `xml:"srw:startRecord,omitempty"`
+	MaximumRecords int    `xml:"srw:maximumRecords,omitempty"`
+	RecordPacking  string `xml:"srw:recordPacking,omitempty"`
+	RecordSchema   string `xml:"srw:recordSchema,omitempty"`
+	BaseURL        string `xml:"srw:baseUrl,omitempty"`
+}
+
+type SearchRetrieveResponse struct {
+	XMLName            xml.Name       `xml:"srw:searchRetrieveResponse"`
+	Xmlns              string         `xml:"xmlns:srw,attr"`
+	Version            string         `xml:"srw:version"`
+	NumberOfRecords    int64          `xml:"srw:numberOfRecords"`
+	Records            *Records       `xml:"srw:records"`
+	NextRecordPosition int64          `xml:"srw:nextRecordPosition,omitempty"`
+	EchoedRequest      *EchoedRequest `xml:"srw:echoedSearchRetrieveRequest"`
+	Diagnostics        *Diagnostics   `xml:"srw:diagnostics"`
+}
+
+func NewSearchRetrieveResponse() *SearchRetrieveResponse {
+	return &SearchRetrieveResponse{Xmlns: Namespace, Version: Version}
+}
+
+type Records struct {
+	Records []*Record `xml:"srw:record"`
+}
+
+// Record carries a record in a schema. RecordData holds any value that
+// marshals to the schema's root element, or its text when packed as string.
+type Record struct {
+	RecordSchema   string      `xml:"srw:recordSchema"`
+	RecordPacking  string      `xml:"srw:recordPacking"`
+	RecordData     *RecordData `xml:"srw:recordData"`
+	RecordPosition int64       `xml:"srw:recordPosition"`
+}
+
+type RecordData struct {
+	Value any
+	Text  string `xml:",chardata"`
+}
+
+type ExplainResponse struct {
+	XMLName     xml.Name     `xml:"srw:explainResponse"`
+	Xmlns       string       `xml:"xmlns:srw,attr"`
+	Version     string       `xml:"srw:version"`
+	Record      *Record      `xml:"srw:record"`
+	Diagnostics *Diagnostics `xml:"srw:diagnostics"`
+}
+
+func NewExplainResponse() *ExplainResponse {
+	return &ExplainResponse{Xmlns: Namespace, Version: Version}
+}
+
+// Explain is the ZeeRex record describing the server: where it listens, what
+// it can be searched on and the schemas records come in.
+type Explain struct {
+	XMLName      xml.Name      `xml:"explain"`
+	Xmlns        string        `xml:"xmlns,attr"`
+	ServerInfo   *ServerInfo   `xml:"serverInfo"`
+	DatabaseInfo *DatabaseInfo `xml:"databaseInfo"`
+	IndexInfo    *IndexInfo    `xml:"indexInfo"`
+	SchemaInfo   *SchemaInfo   `xml:"schemaInfo"`
+	ConfigInfo   *ConfigInfo   `xml:"configInfo"`
+}
+
+type ServerInfo struct {
+	Protocol string `xml:"protocol,attr"`
+	Version  string `xml:"version,attr"`
+	Host     string `xml:"host"`
+	Port     int    `xml:"port"`
+	Database string `xml:"database"`
+}
+
+type DatabaseInfo struct {
+	Title   string `xml:"title"`
+	Contact string `xml:"contact,omitempty"`
+}
+
+type IndexSet struct {
+	Name       string `xml:"name,attr"`
+	Identifier string `xml:"identifier,attr"`
+}
+
+type Index struct {
+	Title string     `xml:"title"`
+	Map   *IndexName `xml:"map>name"`
+}
+
+type IndexName struct {
+	Set  string `xml:"set,attr"`
+	Name string `xml:",chardata"`
+}
+
+type IndexInfo struct {
+	Sets    []*IndexSet `xml:"set"`
+	Indexes []*Index    `xml:"index"`
+}
+
+type Schema struct {
+	Identifier string `xml:"identifier,attr"`
+	Name       string `xml:"name,attr"`
+	Title      string `xml:"title"`
+}
+
+type SchemaInfo struct {
+	Schemas []*Schema `xml:"schema"`
+}
+
+// Setting is a configInfo default or setting, maximumRecords and the like.
+type Setting struct {
+	XMLName xml.Name
+	Type    string `xml:"type,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type ConfigInfo struct {
+	Settings []*Setting
+}
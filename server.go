@@ -9,7 +9,9 @@ import (
 	cache "github.com/dutt23/lms/cache"
 	"github.com/dutt23/lms/config"
 	"github.com/dutt23/lms/middleware"
+	"github.com/dutt23/lms/model"
 	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/dutt23/lms/pkg/openlibrary"
 	service "github.com/dutt23/lms/services"
 	"github.com/dutt23/lms/token"
 	"github.com/dutt23/lms/workers"
@@ -24,20 +26,38 @@ type Server struct {
 	Closeable    []func(context.Context) error
 	E            *gin.Engine
 	tokenMaker   token.Maker
+	purposeMaker token.PurposeMaker
 	bookFilter   *bloom.BloomFilter
 	memberFilter *bloom.BloomFilter
+	opts         *routerOpts
 }
 
 type routerOpts struct {
-	bookCache   cache.BookCache
-	bookService service.BookService
+	bookCache          cache.BookCache
+	bookService        service.BookService
+	itemService        service.ItemService
+	contributorService service.ContributorService
+	subjectService     service.SubjectService
+	workService        service.WorkService
 
-	memberCache   cache.MemberCache
-	memberService service.MemberService
+	memberCache         cache.MemberCache
+	memberService       service.MemberService
+	sessionService      service.SessionService
+	credentialService   service.CredentialService
+	oneTimeTokenService service.OneTimeTokenService
+	tokenCache          cache.TokenCache
 
 	loanService service.LoanService
+	holdService service.HoldService
 
+	policyService service.PolicyService
+	fineService   service.FineService
+
+	importService    service.ImportService
+	searchService    service.SearchService
+	harvestService   service.HarvestService
 	analyticsService service.AnalyticsService
+	metadataService  service.MetadataProvider
 
 	taskDistributor workers.TaskDistributor
 }
@@ -48,22 +68,50 @@ func NewServer(config *config.AppConfig) (*Server, error) {
 		return nil, fmt.Errorf("cannot create token maker %w", err)
 	}
 
+	purposeMaker, err := token.NewPasetoPurposeMaker(config.TokenSymmetricKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create purpose token maker %w", err)
+	}
+
 	bookFilter := bloom.NewWithEstimates(1000000, 0.01)
 	memberFilter := bloom.NewWithEstimates(1000000, 0.01)
-	server := &Server{tokenMaker: tokenMaker, config: config, bookFilter: bookFilter, memberFilter: memberFilter}
+	server := &Server{tokenMaker: tokenMaker, purposeMaker: purposeMaker, config: config, bookFilter: bookFilter, memberFilter: memberFilter}
 
 	// Init storages
 	server.AllConnectors()
 	// Init cache
 	bookCache := cache.NewBookCache(server.Cache)
 	memberCache := cache.NewMemberCache(server.Cache)
+	tokenCache := cache.NewTokenCache(server.Cache)
 
 	// Init Service
 	bookservice := service.NewBookService(server.DB, bookCache)
+	itemService := service.NewItemService(server.DB)
+	contributorService := service.NewContributorService(server.DB, bookCache)
+	subjectService := service.NewSubjectService(server.DB)
+	workService := service.NewWorkService(server.DB)
 	memberService := service.NewMemberService(server.DB, memberCache)
+	sessionService := service.NewSessionService(server.DB)
+	credentialService := service.NewCredentialService(server.DB)
+	oneTimeTokenService := service.NewOneTimeTokenService(server.DB)
 	loanService := service.NewLoanService(server.DB)
+	holdService := service.NewHoldService(server.DB, itemService, config.HoldPickupDuration)
+	policyService := service.NewPolicyService(server.DB, config.CirculationConfig)
+	fineService := service.NewFineService(server.DB)
+	importService := service.NewImportService(server.DB)
+	searchService := service.NewSearchService(server.DB)
+	harvestService := service.NewHarvestService(server.DB)
 	analyticsService := service.NewAnalyticsService(bookCache, memberCache)
 
+	var metadataProviders []service.MetadataProvider
+	if path := config.MetadataConfig.DumpPath; path != "" {
+		metadataProviders = append(metadataProviders, openlibrary.NewDump(path))
+	}
+	if httpURL := config.MetadataConfig.HttpURL; httpURL != "" {
+		metadataProviders = append(metadataProviders, openlibrary.NewClient(httpURL, config.MetadataConfig.Timeout))
+	}
+	metadataService := service.NewMetadataService(bookCache, metadataProviders...)
+
 	redisOpts := asynq.RedisClientOpt{
 		Addr: "0.0.0.0:6379",
 	}
@@ -73,17 +121,36 @@ func NewServer(config *config.AppConfig) (*Server, error) {
 	opts := &routerOpts{
 		bookCache,
 		bookservice,
+		itemService,
+		contributorService,
+		subjectService,
+		workService,
 		memberCache,
 		memberService,
+		sessionService,
+		credentialService,
+		oneTimeTokenService,
+		tokenCache,
 
 		loanService,
+		holdService,
 
+		policyService,
+		fineService,
+
+		importService,
+		searchService,
+		harvestService,
 		analyticsService,
+		metadataService,
 
 		taskDistributor,
 	}
+	server.opts = opts
 	// Add routes
-	server.setupRouter(opts)
+	if err := server.setupRouter(opts); err != nil {
+		return nil, fmt.Errorf("cannot setup router %w", err)
+	}
 	return server, nil
 }
 
@@ -94,52 +161,195 @@ func (s *Server) AllConnectors() {
 	s.Cache = cache
 }
 
-func (server *Server) setupRouter(opts *routerOpts) {
+func (server *Server) setupRouter(opts *routerOpts) error {
 	router := gin.Default()
+	if err := router.SetTrustedProxies(server.config.TrustedProxies); err != nil {
+		return err
+	}
 	apiv1 := router.Group("/v1/")
 	server.addBookRoutes(apiv1, opts)
+	server.addAuthorRoutes(apiv1, opts)
+	server.addSubjectRoutes(apiv1, opts)
+	server.addWorkRoutes(apiv1, opts)
 	server.addMemberRoutes(apiv1, opts)
 	server.addLoanRoutes(apiv1, opts)
+	server.addHoldRoutes(apiv1, opts)
+	server.addPolicyRoutes(apiv1, opts)
+	server.addSearchRoutes(apiv1, opts)
+	server.addImportRoutes(apiv1, opts)
+	server.addBulkRoutes(apiv1, opts)
 	server.addAnalyticsRoutes(apiv1, opts)
 	server.addAuthRoutes(apiv1, opts)
+	// OAI-PMH harvesters expect the provider outside of the versioned api
+	server.addOaiRoutes(&router.RouterGroup, opts)
+	server.addSruRoutes(&router.RouterGroup, opts)
 	server.E = router
+	return nil
 }
 
 func (server *Server) addBookRoutes(grp *gin.RouterGroup, opts *routerOpts) {
-	bookHandler := api.NewBooksApi(server.config, server.DB, opts.bookCache, opts.bookService)
-	grp.POST("/books", bookHandler.AddBook)
+	bookHandler := api.NewBooksApi(server.config, server.DB, opts.bookCache, opts.bookService, opts.itemService, opts.holdService, opts.contributorService, opts.subjectService, opts.workService, opts.metadataService)
 	grp.GET("/books", bookHandler.GetBooks)
 	grp.GET("/books/:id", bookHandler.GetBook)
-	grp.PUT("/books/:id", bookHandler.UpdateBook)
-	grp.DELETE("/books/:id", bookHandler.DeleteBook)
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.POST("/books", bookHandler.AddBook)
+	librarianRoutes.POST("/books/lookup", bookHandler.LookupBook)
+	librarianRoutes.PUT("/books/:id", bookHandler.UpdateBook)
+	librarianRoutes.DELETE("/books/:id", bookHandler.DeleteBook)
+
+	itemHandler := api.NewItemsApi(server.config, server.DB, opts.bookService, opts.itemService)
+	grp.GET("/books/:id/items", itemHandler.GetItems)
+	grp.GET("/books/:id/items/:item_id", itemHandler.GetItem)
+	librarianRoutes.POST("/books/:id/items", itemHandler.AddItem)
+	librarianRoutes.PUT("/books/:id/items/:item_id", itemHandler.UpdateItem)
+	librarianRoutes.DELETE("/books/:id/items/:item_id", itemHandler.DeleteItem)
+}
+
+func (server *Server) addAuthorRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	authorsHandler := api.NewAuthorsApi(server.config, opts.contributorService)
+	grp.GET("/authors", authorsHandler.GetAuthors)
+	grp.GET("/authors/:id", authorsHandler.GetAuthor)
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.POST("/authors", authorsHandler.AddAuthor)
+	librarianRoutes.PUT("/authors/:id", authorsHandler.UpdateAuthor)
+	librarianRoutes.DELETE("/authors/:id", authorsHandler.DeleteAuthor)
+}
+
+func (server *Server) addSubjectRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	subjectsHandler := api.NewSubjectsApi(server.config, opts.subjectService, opts.contributorService)
+	grp.GET("/subjects", subjectsHandler.GetSubjects)
+	grp.GET("/subjects/:id", subjectsHandler.GetSubject)
+	grp.GET("/subjects/:id/books", subjectsHandler.GetSubjectBooks)
+	grp.GET("/tags", subjectsHandler.GetTags)
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.POST("/subjects", subjectsHandler.AddSubject)
+	librarianRoutes.PUT("/subjects/:id", subjectsHandler.UpdateSubject)
+	librarianRoutes.DELETE("/subjects/:id", subjectsHandler.DeleteSubject)
+}
+
+func (server *Server) addWorkRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	worksHandler := api.NewWorksApi(server.config, opts.workService)
+	grp.GET("/works", worksHandler.GetWorks)
+	grp.GET("/works/:id", worksHandler.GetWork)
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.POST("/works", worksHandler.AddWork)
+	librarianRoutes.PUT("/works/:id", worksHandler.UpdateWork)
+	librarianRoutes.DELETE("/works/:id", worksHandler.DeleteWork)
 }
 
 func (server *Server) addMemberRoutes(grp *gin.RouterGroup, opts *routerOpts) {
-	memberHandler := api.NewMembersApi(server.config, server.DB, opts.memberCache, opts.memberService)
-	grp.POST("/members", memberHandler.AddMember)
-	grp.GET("/members", memberHandler.GetMembers)
-	grp.GET("/members/:id", memberHandler.GetMember)
-	grp.PUT("/members/:id", memberHandler.UpdateMember)
-	grp.DELETE("/members/:id", memberHandler.DeleteMember)
+	memberHandler := api.NewMembersApi(server.config, server.DB, opts.memberCache, opts.memberService, opts.credentialService, opts.sessionService, opts.tokenCache,
+		api.NewAccountMails(server.config, opts.memberService, opts.oneTimeTokenService, server.purposeMaker, opts.taskDistributor))
+	memberRoutes := server.authorized(grp, model.RoleMember)
+	memberRoutes.GET("/members/:id", memberHandler.GetMember)
+	memberRoutes.GET("/members/:id/account", memberHandler.GetMemberAccount)
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.POST("/members", memberHandler.AddMember)
+	librarianRoutes.GET("/members", memberHandler.GetMembers)
+	librarianRoutes.PUT("/members/:id", memberHandler.UpdateMember)
+	librarianRoutes.PUT("/members/:id/password", memberHandler.SetMemberPassword)
+	librarianRoutes.POST("/members/:id/account/payments", memberHandler.AddMemberPayment)
+	librarianRoutes.POST("/members/:id/account/waivers", memberHandler.AddMemberWaiver)
+	adminRoutes := server.authorized(grp, model.RoleAdmin)
+	adminRoutes.DELETE("/members/:id", memberHandler.DeleteMember)
 }
 
 func (server *Server) addLoanRoutes(grp *gin.RouterGroup, opts *routerOpts) {
-	loansHandler := api.NewLoansApi(server.config, server.DB, opts.bookService, opts.memberService, opts.loanService, opts.taskDistributor)
-	grp.POST("/loans", loansHandler.AddLoan)
-	grp.GET("/loans", loansHandler.GetLoans)
-	grp.GET("/loans/:id", loansHandler.GetLoan)
-	grp.PUT("/loans/:id", loansHandler.UpdateLoan)
-	grp.DELETE("/loans/:id", loansHandler.DeleteLoan)
+	loansHandler := api.NewLoansApi(server.config, server.DB, opts.bookService, opts.itemService, opts.memberService, opts.loanService, opts.holdService, opts.policyService, opts.taskDistributor)
+	// members check out, see and renew their own loans
+	memberRoutes := server.authorized(grp, model.RoleMember)
+	memberRoutes.POST("/loans", loansHandler.AddLoan)
+	memberRoutes.GET("/loans", loansHandler.GetLoans)
+	memberRoutes.GET("/loans/:id", loansHandler.GetLoan)
+	memberRoutes.POST("/loans/:id/renew", loansHandler.RenewLoan)
+	memberRoutes.POST("/me/loans/renew", loansHandler.RenewMyLoans)
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.PUT("/loans/:id", loansHandler.UpdateLoan)
+	librarianRoutes.DELETE("/loans/:id", loansHandler.DeleteLoan)
+}
+
+func (server *Server) addHoldRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	holdsHandler := api.NewHoldsApi(server.config, server.DB, opts.bookService, opts.workService, opts.memberService, opts.holdService)
+	// members place, see and cancel their own holds
+	memberRoutes := server.authorized(grp, model.RoleMember)
+	memberRoutes.POST("/holds", holdsHandler.AddHold)
+	memberRoutes.GET("/holds", holdsHandler.GetHolds)
+	memberRoutes.GET("/holds/:id", holdsHandler.GetHold)
+	memberRoutes.DELETE("/holds/:id", holdsHandler.DeleteHold)
+}
+
+func (server *Server) addSearchRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	searchHandler := api.NewSearchApi(server.config, opts.searchService)
+	grp.GET("/search", searchHandler.SearchBooks)
+}
+
+func (server *Server) addImportRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	importsHandler := api.NewImportsApi(server.config, opts.importService, opts.taskDistributor)
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.POST("/imports/marc", importsHandler.AddMarcImport)
+	librarianRoutes.GET("/imports/:id", importsHandler.GetImport)
+	librarianRoutes.GET("/imports/:id/records", importsHandler.GetImportRecords)
+}
+
+func (server *Server) addBulkRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	bulkHandler := api.NewBulkApi(server.config, server.DB, opts.bookService, opts.itemService, opts.subjectService, opts.memberService, opts.loanService,
+		api.NewAccountMails(server.config, opts.memberService, opts.oneTimeTokenService, server.purposeMaker, opts.taskDistributor))
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.POST("/books/import", bulkHandler.ImportBooks)
+	librarianRoutes.GET("/books/export", bulkHandler.ExportBooks)
+	librarianRoutes.POST("/members/import", bulkHandler.ImportMembers)
+	librarianRoutes.GET("/members/export", bulkHandler.ExportMembers)
+	librarianRoutes.GET("/loans/export", bulkHandler.ExportLoans)
+}
+
+func (server *Server) addOaiRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	oaiHandler := api.NewOaiApi(server.config, opts.harvestService)
+	grp.GET("/oai", oaiHandler.Oai)
+	grp.POST("/oai", oaiHandler.Oai)
+}
+
+func (server *Server) addSruRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	sruHandler := api.NewSruApi(server.config, opts.searchService)
+	grp.GET("/sru", sruHandler.Sru)
+	grp.POST("/sru", sruHandler.Sru)
+}
+
+func (server *Server) addPolicyRoutes(grp *gin.RouterGroup, opts *routerOpts) {
+	policiesHandler := api.NewPoliciesApi(server.config, opts.policyService)
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.GET("/policies", policiesHandler.GetPolicies)
+	adminRoutes := server.authorized(grp, model.RoleAdmin)
+	adminRoutes.POST("/policies", policiesHandler.AddPolicy)
+	adminRoutes.DELETE("/policies/:id", policiesHandler.DeletePolicy)
 }
 
 func (server *Server) addAnalyticsRoutes(grp *gin.RouterGroup, opts *routerOpts) {
-	analyticsHandler := api.NewAnalyticsApi(server.config, opts.bookService, opts.memberService, opts.analyticsService)
-	grp.GET("/analytics", analyticsHandler.GetAnalytics)
+	analyticsHandler := api.NewAnalyticsApi(server.config, opts.bookService, opts.workService, opts.memberService, opts.analyticsService)
+	librarianRoutes := server.authorized(grp, model.RoleLibrarian)
+	librarianRoutes.GET("/analytics", analyticsHandler.GetAnalytics)
 }
 
 func (server *Server) addAuthRoutes(grp *gin.RouterGroup, opts *routerOpts) {
-	authHandler := api.NewAuthApi(server.config, opts.memberService, server.tokenMaker)
-	grp.POST("/login/user", authHandler.LoginUser)
-	authRoutes := grp.Group("/").Use(middleware.AuthMiddleware(server.tokenMaker))
+	accountMails := api.NewAccountMails(server.config, opts.memberService, opts.oneTimeTokenService, server.purposeMaker, opts.taskDistributor)
+	authHandler := api.NewAuthApi(server.config, server.DB, opts.memberService, opts.sessionService, opts.credentialService, server.tokenMaker, opts.tokenCache, accountMails)
+	grp.POST("/signup", authHandler.Signup)
+	grp.POST("/login/user", middleware.LoginThrottle(server.config.LoginMaxAttempts, server.config.LoginLockoutDuration), authHandler.LoginUser)
+	grp.POST("/email/verify", authHandler.VerifyEmail)
+	grp.POST("/password/forgot", authHandler.ForgotPassword)
+	grp.POST("/password/reset", authHandler.ResetPassword)
+	grp.POST("/tokens/renew", authHandler.RenewTokens)
+	authRoutes := grp.Group("/").Use(middleware.AuthMiddleware(server.tokenMaker, opts.tokenCache))
 	authRoutes.POST("/auth/check", authHandler.CheckAuth)
+	authRoutes.POST("/logout", authHandler.Logout)
+	authRoutes.POST("/logout/all", authHandler.LogoutAll)
+	authRoutes.GET("/me/sessions", authHandler.GetMySessions)
+	authRoutes.PUT("/me/password", authHandler.ChangePassword)
+	authRoutes.POST("/me/email/verify", authHandler.ResendVerification)
+	authRoutes.DELETE("/me/sessions/:id", authHandler.DeleteMySession)
+}
+
+// authorized groups the routes open to callers logged in with the role or
+// one above it.
+func (server *Server) authorized(grp *gin.RouterGroup, role string) gin.IRoutes {
+	return grp.Group("/").Use(middleware.AuthMiddleware(server.tokenMaker, server.opts.tokenCache), middleware.PermissionMiddleware(role))
 }
@@ -0,0 +1,839 @@
+//go:build sqlite_fts5
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	service "github.com/dutt23/lms/services"
+	"github.com/dutt23/lms/token"
+	"github.com/dutt23/lms/workers"
+	"github.com/gin-gonic/gin"
+)
+
+const testAdminEmail = "admin@lms.test"
+
+// testServer runs the routes against a fresh database in a directory of its
+// own. Nothing listens on the cache port, every cached lookup falling back to
+// the database.
+type testServer struct {
+	t      *testing.T
+	server *Server
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	migrations, err := filepath.Abs("db/migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sqlite connector opens lms.db in the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	runMigrations("file://"+migrations, "sqlite3://lms.db")
+
+	cfg := &config.AppConfig{
+		TokenSymmetricKey:    "rxlpipgvqavvvkkuyipfcphlecvonfge",
+		AccessTokenDuration:  15 * time.Minute,
+		RefreshTokenDuration: 24 * time.Hour,
+		HoldPickupDuration:   72 * time.Hour,
+		AdminEmail:           testAdminEmail,
+		LoginMaxAttempts:     5,
+		LoginLockoutDuration: 15 * time.Minute,
+	}
+	cfg.CirculationConfig = config.CirculationConfig{
+		LoanPeriodDays: 14,
+		MaxLoans:       5,
+		MaxRenewals:    2,
+		GraceDays:      2,
+		DailyFine:      25,
+		MaxFine:        1000,
+		MaxBalance:     500,
+	}
+	cfg.DbConfig.MaxIdealConnection = 1
+	cfg.DbConfig.MaxOpenConnection = 1
+	cfg.CacheConfig.Host = "127.0.0.1"
+	cfg.CacheConfig.Port = 1
+
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := server.DB.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server.Cache.Connect(ctx)
+	t.Cleanup(func() { server.DB.Disconnect(ctx) })
+	return &testServer{t, server}
+}
+
+// exec runs the statements against the database, to set up what a test needs.
+func (ts *testServer) exec(statements ...string) {
+	ts.t.Helper()
+	db := ts.server.DB.DB(context.Background())
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			ts.t.Fatalf("%s: %v", statement, err)
+		}
+	}
+}
+
+// addMember adds a member with the role and returns an access token for them.
+func (ts *testServer) addMember(email, role string) (*model.Member, string) {
+	ts.t.Helper()
+	member := &model.Member{Name: email, Email: email, MemberType: "standard", Role: role, JoinDate: time.Now()}
+	if err := ts.server.DB.DB(context.Background()).Create(member).Error; err != nil {
+		ts.t.Fatal(err)
+	}
+
+	accessToken, _, err := ts.server.tokenMaker.CreateToken(email, role, token.KindAccess, time.Minute)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return member, accessToken
+}
+
+// signup registers a member with the password and logs them in, returning
+// their access and refresh tokens.
+func (ts *testServer) signup(email, password string) (string, string) {
+	ts.t.Helper()
+	if code, body := ts.do(http.MethodPost, "/v1/signup", "", gin.H{"email": email, "name": email, "password": password}); code != http.StatusCreated {
+		ts.t.Fatalf("unable to signup %d %s", code, body)
+	}
+	return ts.login(email, password)
+}
+
+func (ts *testServer) login(email, password string) (string, string) {
+	ts.t.Helper()
+	code, body := ts.do(http.MethodPost, "/v1/login/user", "", gin.H{"email": email, "password": password})
+	if code != http.StatusOK {
+		ts.t.Fatalf("unable to login %d %s", code, body)
+	}
+	return ts.tokens(body)
+}
+
+// tokens reads the access and refresh tokens of a login or renewal.
+func (ts *testServer) tokens(body string) (string, string) {
+	ts.t.Helper()
+	var resp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		ts.t.Fatal(err)
+	}
+	return resp.AccessToken, resp.RefreshToken
+}
+
+// do sends a request with the access token, when given one, and returns the
+// response status and body.
+func (ts *testServer) do(method, path, accessToken string, body any) (int, string) {
+	ts.t.Helper()
+	var reader io.Reader = strings.NewReader("{}")
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	rec := httptest.NewRecorder()
+	ts.server.E.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+// publicRoutes need no login, every other route does.
+var publicRoutes = map[string]bool{
+	"GET /v1/books":                    true,
+	"GET /v1/books/:id":                true,
+	"GET /v1/books/:id/items":          true,
+	"GET /v1/books/:id/items/:item_id": true,
+	"GET /v1/authors":                  true,
+	"GET /v1/authors/:id":              true,
+	"GET /v1/subjects":                 true,
+	"GET /v1/subjects/:id":             true,
+	"GET /v1/subjects/:id/books":       true,
+	"GET /v1/tags":                     true,
+	"GET /v1/works":                    true,
+	"GET /v1/works/:id":                true,
+	"GET /v1/search":                   true,
+	"POST /v1/signup":                  true,
+	"POST /v1/login/user":              true,
+	"POST /v1/tokens/renew":            true,
+	"POST /v1/email/verify":            true,
+	"POST /v1/password/forgot":         true,
+	"POST /v1/password/reset":          true,
+	"GET /oai":                         true,
+	"POST /oai":                        true,
+	"GET /sru":                         true,
+	"POST /sru":                        true,
+	"GET /swagger/*any":                true,
+}
+
+// librarianRoutes are closed to members, adminRoutes to librarians as well.
+var (
+	librarianRoutes = []string{
+		"POST /v1/books",
+		"POST /v1/books/lookup",
+		"PUT /v1/books/1",
+		"DELETE /v1/books/1",
+		"POST /v1/books/1/items",
+		"PUT /v1/books/1/items/1",
+		"DELETE /v1/books/1/items/1",
+		"POST /v1/authors",
+		"PUT /v1/authors/1",
+		"DELETE /v1/authors/1",
+		"POST /v1/subjects",
+		"PUT /v1/subjects/1",
+		"DELETE /v1/subjects/1",
+		"POST /v1/works",
+		"PUT /v1/works/1",
+		"DELETE /v1/works/1",
+		"POST /v1/members",
+		"GET /v1/members",
+		"PUT /v1/members/1",
+		"PUT /v1/members/1/password",
+		"POST /v1/members/1/account/payments",
+		"POST /v1/members/1/account/waivers",
+		"PUT /v1/loans/1",
+		"DELETE /v1/loans/1",
+		"POST /v1/imports/marc",
+		"GET /v1/imports/1",
+		"GET /v1/imports/1/records",
+		"POST /v1/books/import",
+		"GET /v1/books/export",
+		"POST /v1/members/import",
+		"GET /v1/members/export",
+		"GET /v1/loans/export",
+		"GET /v1/policies",
+		"GET /v1/analytics",
+	}
+	adminRoutes = []string{
+		"DELETE /v1/members/1",
+		"POST /v1/policies",
+		"DELETE /v1/policies/1",
+	}
+)
+
+func splitRoute(route string) (string, string) {
+	method, path, _ := strings.Cut(route, " ")
+	return method, path
+}
+
+func TestProtectedRoutesNeedLogin(t *testing.T) {
+	ts := newTestServer(t)
+
+	protected := 0
+	for _, route := range ts.server.E.Routes() {
+		if publicRoutes[route.Method+" "+route.Path] {
+			continue
+		}
+		protected++
+
+		path := strings.NewReplacer(":id", "1", ":item_id", "1").Replace(route.Path)
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			if code, body := ts.do(route.Method, path, "", nil); code != http.StatusUnauthorized {
+				t.Fatalf("expected %d, got %d %s", http.StatusUnauthorized, code, body)
+			}
+		})
+	}
+
+	if protected < len(librarianRoutes)+len(adminRoutes) {
+		t.Fatalf("only %d protected routes", protected)
+	}
+}
+
+func TestLibrarianRoutesRefuseMembers(t *testing.T) {
+	ts := newTestServer(t)
+	_, member := ts.addMember("member@lms.test", model.RoleMember)
+
+	for _, route := range append(librarianRoutes, adminRoutes...) {
+		method, path := splitRoute(route)
+		t.Run(route, func(t *testing.T) {
+			if code, body := ts.do(method, path, member, nil); code != http.StatusForbidden {
+				t.Fatalf("expected %d, got %d %s", http.StatusForbidden, code, body)
+			}
+		})
+	}
+}
+
+func TestAdminRoutesRefuseLibrarians(t *testing.T) {
+	ts := newTestServer(t)
+	_, librarian := ts.addMember("librarian@lms.test", model.RoleLibrarian)
+
+	for _, route := range adminRoutes {
+		method, path := splitRoute(route)
+		t.Run(route, func(t *testing.T) {
+			if code, body := ts.do(method, path, librarian, nil); code != http.StatusForbidden {
+				t.Fatalf("expected %d, got %d %s", http.StatusForbidden, code, body)
+			}
+		})
+	}
+}
+
+func TestMembersOnlyActForThemselves(t *testing.T) {
+	ts := newTestServer(t)
+	ann, annToken := ts.addMember("ann@lms.test", model.RoleMember)
+	bob, _ := ts.addMember("bob@lms.test", model.RoleMember)
+	_, librarian := ts.addMember("librarian@lms.test", model.RoleLibrarian)
+
+	ts.exec(
+		`INSERT INTO works (id, title, author) VALUES (1, 'Dune', 'Frank Herbert')`,
+		`INSERT INTO books (id, title, author, published_date, isbn, number_of_pages, language, available_copies, work_id) VALUES (1, 'Dune', 'Frank Herbert', '1965-08-01', '9780441172719', 412, 'english', 0, 1)`,
+		`INSERT INTO items (id, barcode, book_id, status) VALUES (1, 'B-1', 1, 'on_loan')`,
+		fmt.Sprintf(`INSERT INTO book_loans (id, book_id, item_id, member_id, loan_date, due_date) VALUES (1, 1, 1, %d, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`, bob.Id),
+		fmt.Sprintf(`INSERT INTO holds (id, book_id, work_id, member_id, status, placed_at) VALUES (1, 1, 1, %d, 'waiting', CURRENT_TIMESTAMP)`, bob.Id),
+	)
+
+	cases := []struct {
+		route string
+		body  any
+	}{
+		{route: fmt.Sprintf("GET /v1/members/%d", bob.Id)},
+		{route: fmt.Sprintf("GET /v1/members/%d/account", bob.Id)},
+		{route: "GET /v1/loans/1"},
+		{route: "POST /v1/loans/1/renew"},
+		{route: "POST /v1/loans", body: gin.H{"member_id": bob.Id, "barcode": "B-1"}},
+		{route: "GET /v1/holds/1"},
+		{route: "DELETE /v1/holds/1"},
+	}
+
+	for _, c := range cases {
+		method, path := splitRoute(c.route)
+		t.Run(c.route, func(t *testing.T) {
+			if code, body := ts.do(method, path, annToken, c.body); code != http.StatusForbidden {
+				t.Fatalf("expected %d, got %d %s", http.StatusForbidden, code, body)
+			}
+		})
+	}
+
+	// lists only hold the member's own loans and holds
+	for _, path := range []string{"/v1/loans", "/v1/holds"} {
+		code, body := ts.do(http.MethodGet, path, annToken, gin.H{"member_id": bob.Id})
+		if code != http.StatusOK || strings.Contains(body, fmt.Sprintf(`"member_id":%d`, bob.Id)) {
+			t.Fatalf("%s: expected only the loans and holds of member %d, got %d %s", path, ann.Id, code, body)
+		}
+	}
+
+	// staff act for any member
+	if code, body := ts.do(http.MethodGet, fmt.Sprintf("/v1/members/%d/account", bob.Id), librarian, nil); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+}
+
+func TestDeleteBookRemovesItsCopies(t *testing.T) {
+	ts := newTestServer(t)
+	member, _ := ts.addMember("member@lms.test", model.RoleMember)
+	_, librarian := ts.addMember("librarian@lms.test", model.RoleLibrarian)
+
+	book := gin.H{
+		"title": "Dune", "author": "Frank Herbert", "published_date": "1965-08-01T00:00:00Z", "isbn": "9780441172719",
+		"number_of_pages": 412, "cover_url": "https://covers.lms.test/dune.jpg", "language": "english", "available_copies": 2,
+	}
+	if code, body := ts.do(http.MethodPost, "/v1/books", librarian, book); code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d %s", http.StatusCreated, code, body)
+	}
+	ts.exec(fmt.Sprintf(`INSERT INTO holds (book_id, work_id, member_id, status, placed_at) SELECT id, work_id, %d, 'waiting', CURRENT_TIMESTAMP FROM books`, member.Id))
+
+	// a copy on loan keeps the book
+	ts.exec(`UPDATE items SET status = 'on_loan' WHERE id = (SELECT min(id) FROM items)`)
+	if code, body := ts.do(http.MethodDelete, "/v1/books/1", librarian, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d %s", http.StatusBadRequest, code, body)
+	}
+
+	ts.exec(`UPDATE items SET status = 'available'`)
+	if code, body := ts.do(http.MethodDelete, "/v1/books/1", librarian, nil); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+
+	var items, holds int64
+	db := ts.server.DB.DB(context.Background())
+	db.Raw(`SELECT count(*) FROM items`).Scan(&items)
+	db.Raw(`SELECT count(*) FROM holds`).Scan(&holds)
+	if items != 0 || holds != 0 {
+		t.Fatalf("expected the copies and holds to go with the book, %d copies and %d holds left", items, holds)
+	}
+
+	// the barcodes of the deleted copies are free again
+	if code, body := ts.do(http.MethodPost, "/v1/books", librarian, book); code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d %s", http.StatusCreated, code, body)
+	}
+}
+
+func TestUpdateBookLeavesCopiesToItems(t *testing.T) {
+	ts := newTestServer(t)
+	_, librarian := ts.addMember("librarian@lms.test", model.RoleLibrarian)
+
+	book := gin.H{
+		"title": "Dune", "author": "Frank Herbert", "published_date": "1965-08-01T00:00:00Z", "isbn": "9780441172719",
+		"number_of_pages": 412, "cover_url": "https://covers.lms.test/dune.jpg", "language": "english", "available_copies": 2,
+	}
+	if code, body := ts.do(http.MethodPost, "/v1/books", librarian, book); code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d %s", http.StatusCreated, code, body)
+	}
+
+	delete(book, "available_copies")
+	book["title"] = "Dune Messiah"
+	code, body := ts.do(http.MethodPut, "/v1/books/1", librarian, book)
+	if code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+
+	var updated model.Book
+	if err := json.Unmarshal([]byte(body), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != "Dune Messiah" || updated.AvailableCopies != 2 {
+		t.Fatalf("expected the title changed and 2 copies, got %s with %d", updated.Title, updated.AvailableCopies)
+	}
+}
+
+func TestFiltersOnUnlistedFieldsAreRefused(t *testing.T) {
+	ts := newTestServer(t)
+
+	refused := gin.H{"filter": gin.H{"field": "password_hash", "op": "eq", "value": "x"}}
+	code, body := ts.do(http.MethodGet, "/v1/books", "", refused)
+	if code != http.StatusBadRequest || !strings.Contains(body, "password_hash") {
+		t.Fatalf("expected %d naming the field, got %d %s", http.StatusBadRequest, code, body)
+	}
+}
+
+func TestHoldChangesOnlyMoveFromTheirStatus(t *testing.T) {
+	ts := newTestServer(t)
+	ann, _ := ts.addMember("ann@lms.test", model.RoleMember)
+	bob, _ := ts.addMember("bob@lms.test", model.RoleMember)
+	_, librarian := ts.addMember("librarian@lms.test", model.RoleLibrarian)
+
+	ts.exec(
+		`INSERT INTO works (id, title, author) VALUES (1, 'Dune', 'Frank Herbert')`,
+		`INSERT INTO books (id, title, author, published_date, isbn, number_of_pages, language, available_copies, work_id) VALUES (1, 'Dune', 'Frank Herbert', '1965-08-01', '9780441172719', 412, 'english', 0, 1)`,
+		`INSERT INTO items (id, barcode, book_id, status) VALUES (1, 'B-1', 1, 'on_hold_shelf'), (2, 'B-2', 1, 'on_hold_shelf')`,
+		fmt.Sprintf(`INSERT INTO holds (id, book_id, work_id, member_id, item_id, status, placed_at, ready_at, expires_at) VALUES
+			(1, 1, 1, %d, 1, 'ready', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, datetime('now', '+1 day')),
+			(2, 1, 1, %d, 2, 'ready', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, datetime('now', '-1 day'))`, ann.Id, bob.Id),
+		fmt.Sprintf(`INSERT INTO holds (id, book_id, work_id, member_id, status, placed_at) VALUES (3, 1, 1, %d, 'waiting', CURRENT_TIMESTAMP)`, ann.Id),
+	)
+
+	// a copy trapped for a hold keeps its status and stays catalogued
+	if code, body := ts.do(http.MethodPut, "/v1/books/1/items/1", librarian, gin.H{"barcode": "B-1", "condition": "good", "status": "available"}); code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d %s", http.StatusBadRequest, code, body)
+	}
+	if code, body := ts.do(http.MethodDelete, "/v1/books/1/items/1", librarian, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d %s", http.StatusBadRequest, code, body)
+	}
+
+	// ann checks her copy out while her cancel is on its way
+	ctx := context.Background()
+	var read []*model.Hold
+	for _, holdId := range []uint64{1, 3} {
+		hold, err := ts.server.opts.holdService.GetHold(ctx, holdId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read = append(read, hold)
+	}
+	ts.exec(`UPDATE holds SET status = 'fulfilled' WHERE id IN (1, 3)`, `UPDATE items SET status = 'on_loan' WHERE id = 1`)
+	for _, hold := range read {
+		err := ts.server.DB.WithinTransaction(ctx, func(ctx context.Context) error {
+			_, err := ts.server.opts.holdService.CancelHold(ctx, hold)
+			return err
+		})
+		if err == nil {
+			t.Fatalf("expected cancelling fulfilled hold %d to fail", hold.Id)
+		}
+	}
+
+	// bob's pickup has run out, his copy goes back on the shelf
+	err := ts.server.DB.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := ts.server.opts.holdService.ExpirePickups(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var statuses []string
+	db := ts.server.DB.DB(ctx)
+	db.Raw(`SELECT status FROM holds ORDER BY id`).Scan(&statuses)
+	if strings.Join(statuses, ",") != "fulfilled,expired,fulfilled" {
+		t.Fatalf("expected the holds fulfilled, expired and fulfilled, got %v", statuses)
+	}
+	statuses = nil
+	db.Raw(`SELECT status FROM items ORDER BY id`).Scan(&statuses)
+	if strings.Join(statuses, ",") != "on_loan,available" {
+		t.Fatalf("expected the copies on loan and available, got %v", statuses)
+	}
+}
+
+func TestUpdateMemberKeepsWhatIsNotGiven(t *testing.T) {
+	ts := newTestServer(t)
+	member, _ := ts.addMember("member@lms.test", model.RoleMember)
+	_, librarian := ts.addMember("librarian@lms.test", model.RoleLibrarian)
+	ts.exec(fmt.Sprintf(`UPDATE members SET member_type = 'student', join_date = '2020-01-02 00:00:00+00:00' WHERE id = %d`, member.Id))
+
+	path := fmt.Sprintf("/v1/members/%d", member.Id)
+	if code, body := ts.do(http.MethodPut, path, librarian, gin.H{"email": member.Email, "name": "Renamed"}); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+
+	var updated model.Member
+	ts.server.DB.DB(context.Background()).Take(&updated, member.Id)
+	if updated.Name != "Renamed" || updated.MemberType != "student" || updated.JoinDate.Year() != 2020 {
+		t.Fatalf("expected only the name to change, got %s %s %s", updated.Name, updated.MemberType, updated.JoinDate)
+	}
+}
+
+func TestRenewalsOnlyMoveOpenLoansOn(t *testing.T) {
+	ts := newTestServer(t)
+	member, memberToken := ts.addMember("member@lms.test", model.RoleMember)
+	ts.exec(
+		`INSERT INTO works (id, title, author) VALUES (1, 'Dune', 'Frank Herbert')`,
+		`INSERT INTO books (id, title, author, published_date, isbn, number_of_pages, language, available_copies, work_id) VALUES (1, 'Dune', 'Frank Herbert', '1965-08-01', '9780441172719', 412, 'english', 0, 1)`,
+		`INSERT INTO items (id, barcode, book_id, status) VALUES (1, 'B-1', 1, 'on_loan'), (2, 'B-2', 1, 'on_loan')`,
+		fmt.Sprintf(`INSERT INTO book_loans (id, book_id, item_id, member_id, loan_date, due_date) VALUES
+			(1, 1, 1, %d, CURRENT_TIMESTAMP, datetime('now', '+1 day')),
+			(2, 1, 2, %d, CURRENT_TIMESTAMP, datetime('now', '+1 day'))`, member.Id, member.Id),
+	)
+
+	// loan 1 is renewed and loan 2 returned after both were read
+	ctx := context.Background()
+	var read []*model.BookLoan
+	for _, loanId := range []uint64{1, 2} {
+		loan, err := ts.server.opts.loanService.GetLoan(ctx, loanId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read = append(read, loan)
+	}
+	if code, body := ts.do(http.MethodPost, "/v1/loans/1/renew", memberToken, nil); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+	ts.exec(`UPDATE book_loans SET return_date = CURRENT_TIMESTAMP WHERE id = 2`)
+
+	for _, loan := range read {
+		err := ts.server.opts.loanService.RenewLoan(ctx, loan, time.Now().Add(30*24*time.Hour))
+		var circulationErr *service.CirculationError
+		if !errors.As(err, &circulationErr) || circulationErr.Code != service.ErrCodeLoanChanged {
+			t.Fatalf("expected renewing loan %d to conflict, got %v", loan.Id, err)
+		}
+	}
+
+	var renewals []int
+	ts.server.DB.DB(ctx).Raw(`SELECT renewal_count FROM book_loans ORDER BY id`).Scan(&renewals)
+	if fmt.Sprint(renewals) != "[1 0]" {
+		t.Fatalf("expected one renewal of loan 1, got %v", renewals)
+	}
+}
+
+func TestOverdueFinesAccrueOncePerDay(t *testing.T) {
+	ts := newTestServer(t)
+	member, _ := ts.addMember("member@lms.test", model.RoleMember)
+	ts.exec(
+		`INSERT INTO works (id, title, author) VALUES (1, 'Dune', 'Frank Herbert')`,
+		`INSERT INTO books (id, title, author, published_date, isbn, number_of_pages, language, available_copies, work_id) VALUES (1, 'Dune', 'Frank Herbert', '1965-08-01', '9780441172719', 412, 'english', 0, 1)`,
+		`INSERT INTO items (id, barcode, book_id, status) VALUES (1, 'B-1', 1, 'on_loan'), (2, 'B-2', 1, 'on_loan'), (3, 'B-3', 1, 'on_loan'), (4, 'B-4', 1, 'available')`,
+		// loan 1 is 5 days overdue, loan 2 long past the cap, loan 3 within
+		// its grace days and loan 4 returned late, charged its first day
+		fmt.Sprintf(`INSERT INTO book_loans (id, book_id, item_id, member_id, loan_date, due_date, return_date) VALUES
+			(1, 1, 1, %[1]d, datetime('now', '-19 days'), datetime('now', '-5 days'), NULL),
+			(2, 1, 2, %[1]d, datetime('now', '-114 days'), datetime('now', '-100 days'), NULL),
+			(3, 1, 3, %[1]d, datetime('now', '-16 days'), datetime('now', '-2 days'), NULL),
+			(4, 1, 4, %[1]d, datetime('now', '-24 days'), datetime('now', '-10 days'), datetime('now', '-7 days'))`, member.Id),
+		fmt.Sprintf(`INSERT INTO fines (member_id, loan_id, kind, amount, accrued_on) VALUES (%d, 4, 'overdue', 25, date('now', '-7 days'))`, member.Id),
+	)
+
+	opts := ts.server.opts
+	worker := workers.NewFinesOverdueWorker(opts.loanService, opts.memberService, opts.itemService, opts.policyService, opts.fineService)
+	for run := 0; run < 2; run++ {
+		if err := worker.OverdueFinesWorker(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var charges []struct {
+		LoanId uint64
+		Days   int
+		Rows   int
+		Amount int64
+	}
+	ts.server.DB.DB(context.Background()).Raw(`SELECT loan_id, COUNT(DISTINCT accrued_on) AS days, COUNT(*) AS rows, SUM(amount) AS amount
+		FROM fines WHERE kind = 'overdue' GROUP BY loan_id ORDER BY loan_id`).Scan(&charges)
+	if got := fmt.Sprint(charges); got != "[{1 3 3 75} {2 40 40 1000} {4 1 1 25}]" {
+		t.Fatalf("expected loan 1 charged 3 days, loan 2 up to the cap and loan 4 nothing more, got %v", got)
+	}
+}
+
+func TestImportedMembersAreMailedToVerify(t *testing.T) {
+	ts := newTestServer(t)
+	_, librarian := ts.addMember("librarian@lms.test", model.RoleLibrarian)
+
+	rows := "email,name\nann@lms.test,Ann\nbob@lms.test,Bob\n"
+	for _, path := range []string{"/v1/members/import?dry_run=true", "/v1/members/import"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(rows))
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set("Authorization", "Bearer "+librarian)
+		rec := httptest.NewRecorder()
+		ts.server.E.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d %s", path, http.StatusOK, rec.Code, rec.Body.String())
+		}
+	}
+
+	// the dry run mails nobody
+	var mailed []string
+	ts.server.DB.DB(context.Background()).Raw(`SELECT members.email FROM one_time_tokens JOIN members ON members.id = one_time_tokens.member_id
+		WHERE purpose = ? ORDER BY members.email`, token.PurposeVerifyEmail).Scan(&mailed)
+	if strings.Join(mailed, ",") != "ann@lms.test,bob@lms.test" {
+		t.Fatalf("expected one verification mail for each imported member, got %v", mailed)
+	}
+}
+
+func TestFailedResetKeepsTheMailedLink(t *testing.T) {
+	ts := newTestServer(t)
+	member, _ := ts.addMember("member@lms.test", model.RoleMember)
+
+	ctx := context.Background()
+	resetToken, payload, err := ts.server.purposeMaker.CreatePurposeToken(member.Email, token.PurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &model.OneTimeToken{Id: payload.ID, MemberId: member.Id, Purpose: token.PurposeResetPassword, ExpiresAt: payload.ExpiredAt}
+	if err := ts.server.opts.oneTimeTokenService.AddToken(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+
+	reset := gin.H{"token": resetToken, "password": "correct horse"}
+	ts.exec(`ALTER TABLE credentials RENAME TO stashed_credentials`)
+	if code, body := ts.do(http.MethodPost, "/v1/password/reset", "", reset); code != http.StatusInternalServerError {
+		t.Fatalf("expected %d, got %d %s", http.StatusInternalServerError, code, body)
+	}
+	ts.exec(`ALTER TABLE stashed_credentials RENAME TO credentials`)
+
+	if code, body := ts.do(http.MethodPost, "/v1/password/reset", "", reset); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+	if code, body := ts.do(http.MethodPost, "/v1/password/reset", "", reset); code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d %s", http.StatusBadRequest, code, body)
+	}
+	ts.login(member.Email, "correct horse")
+}
+
+func TestOnlyAdminsChangeRoles(t *testing.T) {
+	ts := newTestServer(t)
+	member, _ := ts.addMember("member@lms.test", model.RoleMember)
+	_, librarian := ts.addMember("librarian@lms.test", model.RoleLibrarian)
+	_, admin := ts.addMember("root@lms.test", model.RoleAdmin)
+
+	promote := gin.H{"email": member.Email, "name": member.Name, "role": model.RoleLibrarian}
+	path := fmt.Sprintf("/v1/members/%d", member.Id)
+	if code, body := ts.do(http.MethodPut, path, librarian, promote); code != http.StatusForbidden {
+		t.Fatalf("expected %d, got %d %s", http.StatusForbidden, code, body)
+	}
+
+	newcomer := gin.H{"email": "new@lms.test", "name": "New", "role": model.RoleAdmin}
+	if code, body := ts.do(http.MethodPost, "/v1/members", librarian, newcomer); code != http.StatusForbidden {
+		t.Fatalf("expected %d, got %d %s", http.StatusForbidden, code, body)
+	}
+
+	if code, body := ts.do(http.MethodPut, path, admin, promote); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+
+	// the member logs in again to get a token with the new role
+	ts.exec(fmt.Sprintf(`INSERT INTO sessions (id, family_id, member_id, username, user_agent, client_ip, is_revoked, expires_at, created_at)
+		VALUES ('5b0e1d4e-6bd1-4c39-9a3b-7b9a2f0c5d11', '5b0e1d4e-6bd1-4c39-9a3b-7b9a2f0c5d11', %d, '%s', '', '', false, datetime('now', '+1 day'), CURRENT_TIMESTAMP)`, member.Id, member.Email))
+	if code, body := ts.do(http.MethodPut, path, admin, gin.H{"email": member.Email, "name": member.Name, "role": model.RoleMember}); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+
+	var revoked bool
+	ts.server.DB.DB(context.Background()).Raw(`SELECT is_revoked FROM sessions WHERE member_id = ?`, member.Id).Scan(&revoked)
+	if !revoked {
+		t.Fatalf("expected the sessions of member %d to be revoked", member.Id)
+	}
+}
+
+func TestOnlyAdminsUpdateStaff(t *testing.T) {
+	ts := newTestServer(t)
+	member, _ := ts.addMember("member@lms.test", model.RoleMember)
+	colleague, _ := ts.addMember("colleague@lms.test", model.RoleLibrarian)
+	root, _ := ts.addMember("root@lms.test", model.RoleAdmin)
+	owner, _ := ts.addMember(testAdminEmail, model.RoleMember)
+	_, librarian := ts.addMember("librarian@lms.test", model.RoleLibrarian)
+	_, admin := ts.addMember("boss@lms.test", model.RoleAdmin)
+
+	// with the email changed, a password reset would hand over the account
+	for _, staff := range []*model.Member{colleague, root, owner} {
+		path := fmt.Sprintf("/v1/members/%d", staff.Id)
+		takeover := gin.H{"email": "taken" + staff.Email, "name": staff.Name}
+		if code, body := ts.do(http.MethodPut, path, librarian, takeover); code != http.StatusForbidden {
+			t.Fatalf("%s: expected %d, got %d %s", staff.Email, http.StatusForbidden, code, body)
+		}
+	}
+
+	path := fmt.Sprintf("/v1/members/%d", member.Id)
+	if code, body := ts.do(http.MethodPut, path, librarian, gin.H{"email": "renamed@lms.test", "name": member.Name}); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+
+	path = fmt.Sprintf("/v1/members/%d", colleague.Id)
+	if code, body := ts.do(http.MethodPut, path, admin, gin.H{"email": "moved@lms.test", "name": colleague.Name}); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+}
+
+// loginFrom sends a login from the client IP, with the forwarded for header
+// when given one.
+func (ts *testServer) loginFrom(ip, forwardedFor, email, password string) (int, string) {
+	ts.t.Helper()
+	data, err := json.Marshal(gin.H{"email": email, "password": password})
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/login/user", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":4000"
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	rec := httptest.NewRecorder()
+	ts.server.E.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestLoginsAreThrottledByClientIp(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup("ann@lms.test", "correct horse")
+
+	// registered and unknown emails fail alike, both counting towards the limit
+	var failed string
+	for attempt := 0; attempt < ts.server.config.LoginMaxAttempts; attempt++ {
+		email := "ann@lms.test"
+		if attempt%2 == 1 {
+			email = "nobody@lms.test"
+		}
+		code, body := ts.loginFrom("192.0.2.1", "", email, "wrong password")
+		if code != http.StatusUnauthorized || (failed != "" && body != failed) {
+			t.Fatalf("%s: expected %d %s, got %d %s", email, http.StatusUnauthorized, failed, code, body)
+		}
+		failed = body
+	}
+
+	var throttled string
+	for _, email := range []string{"ann@lms.test", "nobody@lms.test"} {
+		code, body := ts.loginFrom("192.0.2.1", "", email, "correct horse")
+		if code != http.StatusTooManyRequests || (throttled != "" && body != throttled) {
+			t.Fatalf("%s: expected %d %s, got %d %s", email, http.StatusTooManyRequests, throttled, code, body)
+		}
+		throttled = body
+	}
+
+	// the header is only taken from trusted proxies
+	if code, body := ts.loginFrom("192.0.2.1", "198.51.100.7", "ann@lms.test", "correct horse"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected %d, got %d %s", http.StatusTooManyRequests, code, body)
+	}
+
+	// the account itself is not locked out, nor do logins count that succeed
+	for attempt := 0; attempt <= ts.server.config.LoginMaxAttempts; attempt++ {
+		if code, body := ts.loginFrom("192.0.2.2", "", "ann@lms.test", "correct horse"); code != http.StatusOK {
+			t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+		}
+	}
+}
+
+func TestRefreshTokensAreNotAccessTokens(t *testing.T) {
+	ts := newTestServer(t)
+	accessToken, refreshToken := ts.signup("ann@lms.test", "annpass12")
+
+	if code, body := ts.do(http.MethodGet, "/v1/me/sessions", accessToken, nil); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+
+	if code, body := ts.do(http.MethodGet, "/v1/me/sessions", refreshToken, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d %s", http.StatusUnauthorized, code, body)
+	}
+
+	if code, body := ts.do(http.MethodPost, "/v1/tokens/renew", "", gin.H{"refresh_token": accessToken}); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d %s", http.StatusUnauthorized, code, body)
+	}
+
+	// a refresh token kept after logging out of every device opens nothing
+	if code, body := ts.do(http.MethodPost, "/v1/logout/all", accessToken, nil); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+
+	if code, body := ts.do(http.MethodPost, "/v1/tokens/renew", "", gin.H{"refresh_token": refreshToken}); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d %s", http.StatusUnauthorized, code, body)
+	}
+
+	for _, route := range []string{"GET /v1/me/sessions", "GET /v1/loans", "POST /v1/auth/check"} {
+		method, path := splitRoute(route)
+		if code, body := ts.do(method, path, refreshToken, nil); code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected %d, got %d %s", route, http.StatusUnauthorized, code, body)
+		}
+	}
+}
+
+func TestReplayedRefreshTokenRevokesFamily(t *testing.T) {
+	ts := newTestServer(t)
+	_, stolen := ts.signup("ann@lms.test", "annpass12")
+
+	code, body := ts.do(http.MethodPost, "/v1/tokens/renew", "", gin.H{"refresh_token": stolen})
+	if code != http.StatusOK {
+		t.Fatalf("expected %d, got %d %s", http.StatusOK, code, body)
+	}
+	_, renewed := ts.tokens(body)
+
+	// the thief replays the rotated token, to renew or as a bearer token
+	if code, body := ts.do(http.MethodPost, "/v1/tokens/renew", "", gin.H{"refresh_token": stolen}); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d %s", http.StatusUnauthorized, code, body)
+	}
+
+	if code, body := ts.do(http.MethodGet, "/v1/me/sessions", stolen, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d %s", http.StatusUnauthorized, code, body)
+	}
+
+	// the replay revoked the whole family, the renewed token with it
+	if code, body := ts.do(http.MethodPost, "/v1/tokens/renew", "", gin.H{"refresh_token": renewed}); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d %s", http.StatusUnauthorized, code, body)
+	}
+
+	if code, body := ts.do(http.MethodGet, "/v1/me/sessions", renewed, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d %s", http.StatusUnauthorized, code, body)
+	}
+}
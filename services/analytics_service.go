@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"sort"
 
 	"github.com/dutt23/lms/cache"
 )
@@ -19,6 +21,51 @@ func (service *analyticsService) GetBookListAnalytics(ctx context.Context, bookI
 	return service.cache.GetBookAnalytics(ctx, bookIds)
 }
 
+// GetWorkListAnalytics adds up, month by month, the book analytics of the
+// editions of every work. Months come least busy first, like those of a book.
+func (service *analyticsService) GetWorkListAnalytics(ctx context.Context, editions map[uint64][]uint64) (*cache.WorkAnalytics, error) {
+	var bookIds []uint64
+	for _, ids := range editions {
+		bookIds = append(bookIds, ids...)
+	}
+
+	resp := &cache.WorkAnalytics{Analytics: make(map[string]*cache.WorkAnalytic, len(editions))}
+	if len(bookIds) == 0 {
+		return resp, nil
+	}
+
+	books, err := service.cache.GetBookAnalytics(ctx, bookIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for workId, ids := range editions {
+		counts := map[string]uint64{}
+		for _, bookId := range ids {
+			analytic, ok := books.Analytics[fmt.Sprintf("%d", bookId)]
+			if !ok {
+				continue
+			}
+			for _, freq := range analytic.BookFrequency {
+				counts[freq.Month] += freq.Count
+			}
+		}
+
+		frequency := make([]*cache.BookFreq, 0, len(counts))
+		for month, count := range counts {
+			frequency = append(frequency, &cache.BookFreq{Month: month, Count: count})
+		}
+		sort.Slice(frequency, func(i, j int) bool {
+			if frequency[i].Count != frequency[j].Count {
+				return frequency[i].Count < frequency[j].Count
+			}
+			return frequency[i].Month < frequency[j].Month
+		})
+		resp.Analytics[fmt.Sprintf("%d", workId)] = &cache.WorkAnalytic{WorkFrequency: frequency}
+	}
+	return resp, nil
+}
+
 func (service *analyticsService) GetMemberListAnalytics(ctx context.Context, memberIds []uint64) (*cache.MemberAnalytics, error) {
 	return service.memberCache.GetMemberAnalytics(ctx, memberIds)
 }
@@ -7,6 +7,8 @@ import (
 	"github.com/dutt23/lms/cache"
 	"github.com/dutt23/lms/model"
 	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/dutt23/lms/pkg/isbn"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -19,6 +21,56 @@ func NewBookService(db connectors.SqliteConnector, cache cache.BookCache) BookSe
 	return &bookService{db, cache}
 }
 
+// AddBook catalogues a new book with its credits and caches it once the
+// surrounding unit of work commits. A book without contributors is credited
+// to the names of its author statement, one without a work joins the work of
+// its title and author.
+func (service *bookService) AddBook(ctx context.Context, book *model.Book) error {
+	if len(book.Contributors) == 0 {
+		book.Contributors = model.AuthorCredits(book.Author)
+	}
+
+	err := service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		credits, err := resolveContributors(db, book.Contributors)
+		if err != nil {
+			return err
+		}
+		book.Contributors = credits
+
+		if statement := model.AuthorStatement(book.Contributors); statement != "" {
+			book.Author = statement
+		}
+
+		if err := resolveWork(db, book); err != nil {
+			return err
+		}
+
+		if err := db.Create(book).Error; err != nil {
+			return err
+		}
+		return saveCredits(db, book.Id, book.Contributors)
+	})
+	if err != nil {
+		return err
+	}
+
+	connectors.AfterCommit(ctx, func(ctx context.Context) {
+		service.cache.StoreBookMetaInCache(ctx, book)
+	})
+	return nil
+}
+
+// GetBookByIsbn finds a book by either form of its ISBN, hyphenated or not.
+func (service *bookService) GetBookByIsbn(ctx context.Context, number string) (*model.Book, error) {
+	db := service.db.DB(ctx)
+	var book *model.Book
+	if err := db.Where("isbn = ?", isbn.Normalize(number)).Last(&book).Error; err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
 func (service *bookService) GetBook(ctx context.Context, bookId uint64) (*model.Book, error) {
 	res, err := service.cache.GetBook(ctx, bookId)
 	if err == nil {
@@ -34,25 +86,60 @@ func (service *bookService) GetBook(ctx context.Context, bookId uint64) (*model.
 	return book, nil
 }
 
-func (service *bookService) ChangeAvailableCopies(ctx context.Context, bookId uint64, count int64) error {
+// ChangeAvailableCopies moves a book's available copies by delta in a single
+// conditional update, refusing to take the count below zero. Availability is
+// not part of the catalogue record, so updated_at is left alone. The cached
+// book is refreshed once the surrounding unit of work commits.
+func (service *bookService) ChangeAvailableCopies(ctx context.Context, bookId uint64, delta int64) error {
 	db := service.db.DB(ctx)
-	res, err := service.cache.GetBook(ctx, bookId)
-	if res != nil && err == nil {
-		res.AvailableCopies = res.AvailableCopies + count
-		db.Save(res)
-		service.cache.StoreBookMetaInCache(ctx, res)
-		return nil
+	tx := db.Model(&model.Book{}).
+		Where("id = ? AND available_copies + ? >= 0", bookId, delta).
+		UpdateColumn("available_copies", gorm.Expr("available_copies + ?", delta))
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return newCirculationError(ErrCodeNoCopiesAvailable, fmt.Sprintf("no copies of book %d are available", bookId))
+	}
+
+	connectors.AfterCommit(ctx, func(ctx context.Context) {
+		service.refreshCache(ctx, bookId)
+	})
+	return nil
+}
+
+// RefreshAvailableCopies recomputes a book's available copies from the status
+// of its items and refreshes the cached book.
+func (service *bookService) RefreshAvailableCopies(ctx context.Context, bookId uint64) (*model.Book, error) {
+	db := service.db.DB(ctx)
+	var available int64
+	if err := db.Model(&model.Item{}).Where("book_id = ? AND status = ?", bookId, model.ItemStatusAvailable).Count(&available).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&model.Book{}).Where("id = ?", bookId).UpdateColumn("available_copies", available).Error; err != nil {
+		return nil, err
 	}
 
 	var book *model.Book
 	if err := db.Last(&book, bookId).Error; err != nil {
-		return err
+		return nil, err
 	}
 
-	book.AvailableCopies = book.AvailableCopies + count
-	db.Save(res)
-	service.cache.StoreBookMetaInCache(ctx, res)
-	return nil
+	connectors.AfterCommit(ctx, func(ctx context.Context) {
+		service.refreshCache(ctx, bookId)
+	})
+	return book, nil
+}
+
+func (service *bookService) refreshCache(ctx context.Context, bookId uint64) {
+	var book *model.Book
+	if err := service.db.DB(ctx).Last(&book, bookId).Error; err != nil {
+		fmt.Println("unable to refresh cached book ", err)
+		return
+	}
+	service.cache.StoreBookMetaInCache(ctx, book)
 }
 
 func (service *bookService) GetBooks(ctx context.Context, lastId uint64, pageSize int) ([]*model.Book, error) {
@@ -71,3 +158,21 @@ func (service *bookService) GetBooks(ctx context.Context, lastId uint64, pageSiz
 	}
 	return books, nil
 }
+
+// ExportBooks hands the whole catalogue to fn in batches, in id order, every
+// book with its credits.
+func (service *bookService) ExportBooks(ctx context.Context, fn func([]*model.Book) error) error {
+	db := service.db.DB(ctx)
+	return eachBatch(db, func(book *model.Book) uint64 { return book.Id }, func(books []*model.Book) error {
+		if err := loadContributors(db, books); err != nil {
+			return err
+		}
+		return fn(books)
+	})
+}
+
+// GetBookFacets counts the facet buckets of the books matching where, which
+// may be nil for the whole catalogue.
+func (service *bookService) GetBookFacets(ctx context.Context, where clause.Expression, selected *model.FacetSelection) (*model.BookFacets, error) {
+	return countBookFacets(service.db.DB(ctx), where, selected)
+}
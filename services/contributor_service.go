@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dutt23/lms/cache"
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"gorm.io/gorm"
+)
+
+type contributorService struct {
+	db    connectors.SqliteConnector
+	cache cache.BookCache
+}
+
+func NewContributorService(db connectors.SqliteConnector, cache cache.BookCache) ContributorService {
+	return &contributorService{db, cache}
+}
+
+func (service *contributorService) AddContributor(ctx context.Context, contributor *model.Contributor) error {
+	return service.db.DB(ctx).Create(contributor).Error
+}
+
+func (service *contributorService) GetContributor(ctx context.Context, contributorId uint64) (*model.Contributor, error) {
+	var contributor *model.Contributor
+	if err := service.db.DB(ctx).Take(&contributor, contributorId).Error; err != nil {
+		return nil, err
+	}
+	return contributor, nil
+}
+
+// GetContributors pages through the contributors in id order, those whose
+// name contains name when it is given.
+func (service *contributorService) GetContributors(ctx context.Context, name string, lastId uint64, pageSize int) ([]*model.Contributor, error) {
+	var contributors []*model.Contributor
+	qry := service.db.DB(ctx).Model(&model.Contributor{}).Where("id > ?", lastId)
+	if name != "" {
+		qry = qry.Where("name LIKE ?", "%"+name+"%")
+	}
+
+	if err := qry.Order("id").Limit(pageSize).Find(&contributors).Error; err != nil {
+		fmt.Println("not able to find any contributors", err)
+		return nil, err
+	}
+	return contributors, nil
+}
+
+// UpdateContributor saves the contributor and rewrites the author statement
+// of the books it is credited on, dropping them from the cache once committed.
+func (service *contributorService) UpdateContributor(ctx context.Context, contributor *model.Contributor) error {
+	return service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		if err := db.Save(contributor).Error; err != nil {
+			return err
+		}
+
+		var bookIds []uint64
+		if err := db.Model(&model.BookContributor{}).Where("contributor_id = ?", contributor.Id).Distinct("book_id").Pluck("book_id", &bookIds).Error; err != nil {
+			return err
+		}
+
+		for _, bookId := range bookIds {
+			credits, err := bookCredits(db, bookId)
+			if err != nil {
+				return err
+			}
+
+			if err := updateAuthorStatement(db, bookId, credits); err != nil {
+				return err
+			}
+		}
+
+		connectors.AfterCommit(ctx, func(ctx context.Context) {
+			for _, bookId := range bookIds {
+				if err := service.cache.DeleteBook(ctx, bookId); err != nil {
+					fmt.Println("unable to remove cached book ", err)
+				}
+			}
+		})
+		return nil
+	})
+}
+
+// DeleteContributor removes a contributor. Callers check it is no longer
+// credited on any book.
+func (service *contributorService) DeleteContributor(ctx context.Context, contributorId uint64) error {
+	return service.db.DB(ctx).Delete(&model.Contributor{}, contributorId).Error
+}
+
+// GetBibliography lists the books a contributor is credited on, newest
+// first, once for every role.
+func (service *contributorService) GetBibliography(ctx context.Context, contributorId uint64) ([]*model.ContributorCredit, error) {
+	var credits []*model.ContributorCredit
+	tx := service.db.DB(ctx).Table("book_contributors").
+		Select("books.id AS book_id, books.title, books.isbn, books.published_date, book_contributors.role").
+		Joins("JOIN books ON books.id = book_contributors.book_id").
+		Where("book_contributors.contributor_id = ?", contributorId).
+		Order("books.published_date DESC, books.id, book_contributors.role").
+		Find(&credits)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return credits, nil
+}
+
+// SetBookContributors replaces the credits of a saved book with
+// book.Contributors and brings its author statement in line with them.
+func (service *contributorService) SetBookContributors(ctx context.Context, book *model.Book) error {
+	return service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		credits, err := resolveContributors(db, book.Contributors)
+		if err != nil {
+			return err
+		}
+		book.Contributors = credits
+
+		if err := saveCredits(db, book.Id, book.Contributors); err != nil {
+			return err
+		}
+
+		if err := updateAuthorStatement(db, book.Id, book.Contributors); err != nil {
+			return err
+		}
+		if statement := model.AuthorStatement(book.Contributors); statement != "" {
+			book.Author = statement
+		}
+		return nil
+	})
+}
+
+// RemoveBookContributors drops the credits of a book that is being deleted.
+func (service *contributorService) RemoveBookContributors(ctx context.Context, bookId uint64) error {
+	return service.db.DB(ctx).Where("book_id = ?", bookId).Delete(&model.BookContributor{}).Error
+}
+
+// LoadContributors reads the credits of the books, in their order.
+func (service *contributorService) LoadContributors(ctx context.Context, books []*model.Book) error {
+	return loadContributors(service.db.DB(ctx), books)
+}
+
+// resolveContributors fills in the contributor of every credit. A credit
+// naming a contributor by id takes its name, one with only a name goes to
+// the first contributor of that name, added when there is none. A contributor
+// credited twice under the same role keeps the first credit.
+func resolveContributors(db *gorm.DB, credits []*model.BookContributor) ([]*model.BookContributor, error) {
+	var resolved []*model.BookContributor
+	seen := map[string]bool{}
+	for _, credit := range credits {
+		if credit.Role == "" {
+			credit.Role = model.RoleAuthor
+		}
+
+		if credit.ContributorId != 0 {
+			var contributor *model.Contributor
+			if err := db.Take(&contributor, credit.ContributorId).Error; err != nil {
+				return nil, fmt.Errorf("unable to locate contributor with Id %d %w", credit.ContributorId, err)
+			}
+			credit.Name = contributor.Name
+		} else {
+			credit.Name = strings.TrimSpace(credit.Name)
+			if credit.Name == "" {
+				return nil, fmt.Errorf("a contributor needs an id or a name")
+			}
+
+			var contributor *model.Contributor
+			tx := db.Where("name = ?", credit.Name).Order("id").Limit(1).Find(&contributor)
+			if tx.Error != nil {
+				return nil, tx.Error
+			}
+
+			if tx.RowsAffected == 0 {
+				contributor = &model.Contributor{Name: credit.Name}
+				if err := db.Create(contributor).Error; err != nil {
+					return nil, err
+				}
+			}
+			credit.ContributorId = contributor.Id
+		}
+
+		key := fmt.Sprintf("%d/%s", credit.ContributorId, credit.Role)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		credit.Position = len(resolved)
+		resolved = append(resolved, credit)
+	}
+	return resolved, nil
+}
+
+func saveCredits(db *gorm.DB, bookId uint64, credits []*model.BookContributor) error {
+	if err := db.Where("book_id = ?", bookId).Delete(&model.BookContributor{}).Error; err != nil {
+		return err
+	}
+
+	for _, credit := range credits {
+		credit.BookId = bookId
+	}
+
+	if len(credits) == 0 {
+		return nil
+	}
+	return db.Create(credits).Error
+}
+
+func bookCredits(db *gorm.DB, bookId uint64) ([]*model.BookContributor, error) {
+	book := &model.Book{Audited: model.Audited{Id: bookId}}
+	if err := loadContributors(db, []*model.Book{book}); err != nil {
+		return nil, err
+	}
+	return book.Contributors, nil
+}
+
+func updateAuthorStatement(db *gorm.DB, bookId uint64, credits []*model.BookContributor) error {
+	statement := model.AuthorStatement(credits)
+	if statement == "" {
+		return nil
+	}
+	return db.Model(&model.Book{}).Where("id = ? AND author <> ?", bookId, statement).Update("author", statement).Error
+}
+
+func loadContributors(db *gorm.DB, books []*model.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	bookIds := make([]uint64, len(books))
+	byId := make(map[uint64]*model.Book, len(books))
+	for idx, book := range books {
+		bookIds[idx] = book.Id
+		byId[book.Id] = book
+		book.Contributors = nil
+	}
+
+	var credits []*model.BookContributor
+	tx := db.Model(&model.BookContributor{}).
+		Select("book_contributors.*, contributors.name").
+		Joins("JOIN contributors ON contributors.id = book_contributors.contributor_id").
+		Where("book_contributors.book_id IN ?", bookIds).
+		Order("book_contributors.book_id, book_contributors.position").
+		Find(&credits)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	for _, credit := range credits {
+		book := byId[credit.BookId]
+		book.Contributors = append(book.Contributors, credit)
+	}
+	return nil
+}
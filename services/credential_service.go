@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/dutt23/lms/pkg/password"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCredentials is returned for an unknown member and a wrong
+// password alike, so a login does not tell which emails are registered.
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// dummyHash is verified against when there is no credential, so a login for
+// an unknown member takes as long as a wrong password.
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := password.Hash("")
+	return hash
+})
+
+type credentialService struct {
+	db connectors.SqliteConnector
+}
+
+func NewCredentialService(db connectors.SqliteConnector) CredentialService {
+	return &credentialService{db}
+}
+
+// SetPassword gives the member a new password.
+func (service *credentialService) SetPassword(ctx context.Context, memberId uint64, pass string) error {
+	hash, err := password.Hash(pass)
+	if err != nil {
+		return err
+	}
+
+	credential := &model.Credential{MemberId: memberId, PasswordHash: hash}
+	return service.db.DB(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(credential).Error
+}
+
+// Authenticate checks the password of the member, failing with
+// ErrInvalidCredentials for a wrong one.
+func (service *credentialService) Authenticate(ctx context.Context, memberId uint64, pass string) error {
+	var credential *model.Credential
+	tx := service.db.DB(ctx).Where("member_id = ?", memberId).Limit(1).Find(&credential)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		password.Verify(pass, dummyHash())
+		return ErrInvalidCredentials
+	}
+
+	ok, err := password.Verify(pass, credential.PasswordHash)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package service
+
+const (
+	ErrCodeLoanNotPermitted    = "LOAN_NOT_PERMITTED"
+	ErrCodeMaxLoansExceeded    = "MAX_LOANS_EXCEEDED"
+	ErrCodeDueDateBeyondPolicy = "DUE_DATE_BEYOND_POLICY"
+	ErrCodeBalanceExceeded     = "BALANCE_LIMIT_EXCEEDED"
+	ErrCodeLoanReturned        = "LOAN_ALREADY_RETURNED"
+	ErrCodeMaxRenewalsReached  = "MAX_RENEWALS_REACHED"
+	ErrCodeOnHold              = "ON_HOLD_FOR_ANOTHER_MEMBER"
+	ErrCodeItemUnavailable     = "ITEM_NOT_AVAILABLE"
+	ErrCodeNoCopiesAvailable   = "NO_COPIES_AVAILABLE"
+	ErrCodeCopiesCirculating   = "COPIES_IN_CIRCULATION"
+	ErrCodeHoldChanged         = "HOLD_STATUS_CHANGED"
+	ErrCodeLoanChanged         = "LOAN_CHANGED"
+)
+
+// CirculationError is returned when a circulation policy refuses an action.
+// Code is stable and meant for clients, Message for people.
+type CirculationError struct {
+	Code    string `json:"code"`
+	Message string `json:"error"`
+}
+
+func (err *CirculationError) Error() string {
+	return err.Message
+}
+
+func newCirculationError(code, message string) *CirculationError {
+	return &CirculationError{Code: code, Message: message}
+}
@@ -0,0 +1,30 @@
+package service
+
+import "gorm.io/gorm"
+
+// exportBatchSize bounds how many rows an export holds in memory at once.
+const exportBatchSize = 500
+
+// eachBatch pages through a table in id order and hands every batch to fn.
+func eachBatch[T any](db *gorm.DB, id func(*T) uint64, fn func([]*T) error) error {
+	var lastId uint64
+	for {
+		var batch []*T
+		if err := db.Where("id > ?", lastId).Order("id").Limit(exportBatchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		lastId = id(batch[len(batch)-1])
+	}
+}
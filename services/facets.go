@@ -0,0 +1,106 @@
+package service
+
+import (
+	"strconv"
+
+	"github.com/dutt23/lms/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	facetAuthor       = "author"
+	facetLanguage     = "language"
+	facetDecade       = "decade"
+	facetAvailability = "availability"
+
+	maxFacetBuckets = 20
+)
+
+const (
+	decadeExpression       = "CAST((CAST(strftime('%Y', books.published_date) AS INTEGER) / 10) * 10 AS TEXT)"
+	availabilityExpression = "CASE WHEN books.available_copies > 0 THEN 'available' ELSE 'unavailable' END"
+)
+
+// BookFacetFilter narrows a books query down to the selected facet values.
+// It returns nil when nothing is selected.
+func BookFacetFilter(selected *model.FacetSelection) clause.Expression {
+	return facetFilter(selected, "")
+}
+
+// facetFilter builds the condition for every selected facet but except.
+func facetFilter(selected *model.FacetSelection, except string) clause.Expression {
+	if selected == nil {
+		return nil
+	}
+
+	var exprs []clause.Expression
+	if len(selected.Author) > 0 && except != facetAuthor {
+		exprs = append(exprs, clause.IN{Column: clause.Column{Table: "books", Name: "author"}, Values: toValues(selected.Author)})
+	}
+
+	if len(selected.Language) > 0 && except != facetLanguage {
+		exprs = append(exprs, clause.IN{Column: clause.Column{Table: "books", Name: "language"}, Values: toValues(selected.Language)})
+	}
+
+	if len(selected.Decade) > 0 && except != facetDecade {
+		decades := make([]string, len(selected.Decade))
+		for idx, decade := range selected.Decade {
+			decades[idx] = strconv.Itoa(decade / 10 * 10)
+		}
+		exprs = append(exprs, clause.Expr{SQL: decadeExpression + " IN ?", Vars: []interface{}{decades}})
+	}
+
+	if len(selected.Availability) > 0 && except != facetAvailability {
+		exprs = append(exprs, clause.Expr{SQL: availabilityExpression + " IN ?", Vars: []interface{}{selected.Availability}})
+	}
+
+	if len(exprs) == 0 {
+		return nil
+	}
+	return clause.And(exprs...)
+}
+
+// countBookFacets counts the facet buckets of the books matching base, which
+// may be nil for the whole catalogue.
+func countBookFacets(db *gorm.DB, base clause.Expression, selected *model.FacetSelection) (*model.BookFacets, error) {
+	facets := &model.BookFacets{}
+	counts := []struct {
+		name    string
+		value   string
+		order   string
+		buckets *[]*model.FacetBucket
+	}{
+		{facetAuthor, "books.author", "count DESC, value", &facets.Author},
+		{facetLanguage, "books.language", "count DESC, value", &facets.Language},
+		{facetDecade, decadeExpression, "value", &facets.Decade},
+		{facetAvailability, availabilityExpression, "value", &facets.Availability},
+	}
+
+	for _, count := range counts {
+		qry := db.Session(&gorm.Session{NewDB: true}).Table("books").
+			Select(count.value + " AS value, COUNT(*) AS count")
+
+		if base != nil {
+			qry = qry.Where(base)
+		}
+
+		if where := facetFilter(selected, count.name); where != nil {
+			qry = qry.Where(where)
+		}
+
+		err := qry.Group("value").Order(count.order).Limit(maxFacetBuckets).Scan(count.buckets).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return facets, nil
+}
+
+func toValues(values []string) []interface{} {
+	res := make([]interface{}, len(values))
+	for idx, value := range values {
+		res[idx] = value
+	}
+	return res
+}
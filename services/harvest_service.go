@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"gorm.io/gorm"
+)
+
+// HarvestQuery selects the books a harvester asked for. From and Until bound
+// updated_at, from inclusive and until exclusive, Language narrows to a set.
+type HarvestQuery struct {
+	From     *time.Time
+	Until    *time.Time
+	Language string
+}
+
+type harvestService struct {
+	db connectors.SqliteConnector
+}
+
+func NewHarvestService(db connectors.SqliteConnector) HarvestService {
+	return &harvestService{db}
+}
+
+// GetBook reads the book and its credits from the database, the cached copy
+// may predate its last change.
+func (service *harvestService) GetBook(ctx context.Context, bookId uint64) (*model.Book, error) {
+	db := service.db.DB(ctx)
+	var book *model.Book
+	if err := db.Take(&book, bookId).Error; err != nil {
+		return nil, err
+	}
+
+	if err := loadContributors(db, []*model.Book{book}); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// GetBooks pages through the books matching the query in id order, every
+// book with its credits.
+func (service *harvestService) GetBooks(ctx context.Context, query *HarvestQuery, lastId uint64, pageSize int) ([]*model.Book, error) {
+	db := service.db.DB(ctx)
+	var books []*model.Book
+	tx := harvestScope(db, query).Where("id > ?", lastId).Order("id").Limit(pageSize).Find(&books)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	if err := loadContributors(db, books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (service *harvestService) CountBooks(ctx context.Context, query *HarvestQuery) (int64, error) {
+	var count int64
+	if err := harvestScope(service.db.DB(ctx), query).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// EarliestDatestamp is the oldest updated_at, or the current time for an
+// empty catalogue.
+func (service *harvestService) EarliestDatestamp(ctx context.Context) (time.Time, error) {
+	var book *model.Book
+	tx := service.db.DB(ctx).Order("updated_at").Limit(1).Find(&book)
+	if tx.Error != nil {
+		return time.Time{}, tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return time.Now(), nil
+	}
+	return book.UpdatedAt, nil
+}
+
+// GetLanguages lists the languages books are catalogued in.
+func (service *harvestService) GetLanguages(ctx context.Context) ([]string, error) {
+	var languages []string
+	tx := service.db.DB(ctx).Model(&model.Book{}).Distinct("language").Order("language").Pluck("language", &languages)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return languages, nil
+}
+
+func harvestScope(db *gorm.DB, query *HarvestQuery) *gorm.DB {
+	qry := db.Model(&model.Book{})
+	if query.From != nil {
+		qry = qry.Where("updated_at >= ?", query.From.UTC())
+	}
+
+	if query.Until != nil {
+		qry = qry.Where("updated_at < ?", query.Until.UTC())
+	}
+
+	if query.Language != "" {
+		qry = qry.Where("language = ?", query.Language)
+	}
+	return qry
+}
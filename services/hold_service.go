@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"gorm.io/gorm/clause"
+)
+
+// holdsOnBook matches the holds a copy of the book can meet, those on the
+// book itself and those on any edition of its work.
+func holdsOnBook(bookId uint64) clause.Expression {
+	return clause.Expr{
+		SQL:  "(holds.book_id = ? OR (holds.book_id IS NULL AND holds.work_id = (SELECT books.work_id FROM books WHERE books.id = ?)))",
+		Vars: []interface{}{bookId, bookId},
+	}
+}
+
+type holdService struct {
+	db             connectors.SqliteConnector
+	itemService    ItemService
+	pickupDuration time.Duration
+}
+
+func NewHoldService(db connectors.SqliteConnector, itemService ItemService, pickupDuration time.Duration) HoldService {
+	return &holdService{db, itemService, pickupDuration}
+}
+
+// PlaceHold queues a member for the next copy of a book.
+func (service *holdService) PlaceHold(ctx context.Context, memberId, bookId uint64) (*model.Hold, error) {
+	db := service.db.DB(ctx)
+	var book *model.Book
+	if err := db.Select("id", "work_id").Take(&book, bookId).Error; err != nil {
+		return nil, err
+	}
+
+	hold := &model.Hold{
+		BookId:   &book.Id,
+		WorkId:   book.WorkId,
+		MemberId: memberId,
+		Status:   model.HoldStatusWaiting,
+		PlacedAt: time.Now(),
+	}
+
+	if err := db.Create(hold).Error; err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// PlaceWorkHold queues a member for the next copy of any edition of a work.
+func (service *holdService) PlaceWorkHold(ctx context.Context, memberId, workId uint64) (*model.Hold, error) {
+	hold := &model.Hold{
+		WorkId:   workId,
+		MemberId: memberId,
+		Status:   model.HoldStatusWaiting,
+		PlacedAt: time.Now(),
+	}
+
+	if err := service.db.DB(ctx).Create(hold).Error; err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (service *holdService) GetHold(ctx context.Context, holdId uint64) (*model.Hold, error) {
+	db := service.db.DB(ctx)
+	var hold *model.Hold
+	if err := db.Last(&hold, holdId).Error; err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (service *holdService) GetHolds(ctx context.Context, memberId, bookId, workId, lastId uint64, pageSize int) ([]*model.Hold, error) {
+	db := service.db.DB(ctx)
+	var holds []*model.Hold
+	qry := db.Model(model.Hold{}).Where("id > ?", lastId).Limit(pageSize)
+
+	if memberId > 0 {
+		qry = qry.Where("member_id = ?", memberId)
+	}
+
+	if bookId > 0 {
+		qry = qry.Where("book_id = ?", bookId)
+	}
+
+	if workId > 0 {
+		qry = qry.Where("work_id = ?", workId)
+	}
+
+	if tx := qry.Order("id").Find(&holds); tx.Error != nil {
+		fmt.Println("not able to find any holds", tx.Error)
+		return nil, tx.Error
+	}
+	return holds, nil
+}
+
+// HasActiveHold tells whether the member has an active hold on the work or
+// on any of its editions.
+func (service *holdService) HasActiveHold(ctx context.Context, memberId, workId uint64) (bool, error) {
+	db := service.db.DB(ctx)
+	var count int64
+	err := db.Model(&model.Hold{}).
+		Where("member_id = ? AND work_id = ? AND status IN ?", memberId, workId, []string{model.HoldStatusWaiting, model.HoldStatusReady}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// HasWaitingHolds tells whether a copy of the book would be trapped for a
+// hold on its return.
+func (service *holdService) HasWaitingHolds(ctx context.Context, bookId uint64) (bool, error) {
+	db := service.db.DB(ctx)
+	var count int64
+	err := db.Model(&model.Hold{}).Where(holdsOnBook(bookId)).Where("status = ?", model.HoldStatusWaiting).Count(&count).Error
+	return count > 0, err
+}
+
+// QueuePosition is the 1-based position of a waiting hold in its queue, or 0
+// once the hold has left the queue. A hold on a book queues behind the older
+// holds on the book and on its work, a hold on a work behind the older holds
+// on any of its editions.
+func (service *holdService) QueuePosition(ctx context.Context, hold *model.Hold) (int64, error) {
+	if hold.Status != model.HoldStatusWaiting {
+		return 0, nil
+	}
+
+	db := service.db.DB(ctx)
+	qry := db.Model(&model.Hold{}).Where("status = ? AND id < ?", model.HoldStatusWaiting, hold.Id)
+	if hold.BookId != nil {
+		qry = qry.Where(holdsOnBook(*hold.BookId))
+	} else {
+		qry = qry.Where("work_id = ?", hold.WorkId)
+	}
+
+	var ahead int64
+	err := qry.Count(&ahead).Error
+	return ahead + 1, err
+}
+
+// CancelHold cancels a hold, failing when it is no longer in the status it
+// was read with. If a copy was already trapped for it the copy is passed on to
+// the next hold in the queue and returned. It must run inside the unit of work
+// refreshing the available copies of the copy's book.
+func (service *holdService) CancelHold(ctx context.Context, hold *model.Hold) (*model.Item, error) {
+	trapped := hold.Status == model.HoldStatusReady && hold.ItemId != nil
+
+	if err := service.changeHoldStatus(ctx, hold, model.HoldStatusCancelled); err != nil {
+		return nil, err
+	}
+
+	if !trapped {
+		return nil, nil
+	}
+	return service.releaseItem(ctx, *hold.ItemId)
+}
+
+// TrapItem assigns a returned item to the oldest waiting hold on its book or
+// on the book's work and puts it on the hold shelf. Without waiting holds the item goes back on
+// the shelf as available and no hold is returned. It fails when the item is no
+// longer in the status it was read with.
+func (service *holdService) TrapItem(ctx context.Context, item *model.Item) (*model.Hold, error) {
+	db := service.db.DB(ctx)
+	var holds []*model.Hold
+	err := db.Where(holdsOnBook(item.BookId)).Where("status = ?", model.HoldStatusWaiting).
+		Order("placed_at, id").Limit(1).Find(&holds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(holds) == 0 {
+		if err := service.itemService.ChangeItemStatus(ctx, item.Id, item.Status, model.ItemStatusAvailable); err != nil {
+			return nil, err
+		}
+		item.Status = model.ItemStatusAvailable
+		return nil, nil
+	}
+
+	hold := holds[0]
+	t := time.Now()
+	expiresAt := t.Add(service.pickupDuration)
+	hold.ItemId = &item.Id
+	hold.ReadyAt = &t
+	hold.ExpiresAt = &expiresAt
+	if err := service.changeHoldStatus(ctx, hold, model.HoldStatusReady, "item_id", "ready_at", "expires_at"); err != nil {
+		return nil, err
+	}
+
+	if err := service.itemService.ChangeItemStatus(ctx, item.Id, item.Status, model.ItemStatusOnHold); err != nil {
+		return nil, err
+	}
+	item.Status = model.ItemStatusOnHold
+	return hold, nil
+}
+
+// GetReadyHold returns the hold an item on the hold shelf is trapped for.
+func (service *holdService) GetReadyHold(ctx context.Context, itemId uint64) (*model.Hold, error) {
+	db := service.db.DB(ctx)
+	var hold *model.Hold
+	err := db.Where("item_id = ? AND status = ?", itemId, model.HoldStatusReady).Last(&hold).Error
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// FulfillHold closes the member's active hold on the item's book, or on its
+// work, when the member checks the item out. A copy trapped for the hold other
+// than the one checked out is passed on to the next hold in the queue and
+// returned.
+func (service *holdService) FulfillHold(ctx context.Context, memberId uint64, item *model.Item) ([]*model.Item, error) {
+	db := service.db.DB(ctx)
+	var holds []*model.Hold
+	err := db.Where(holdsOnBook(item.BookId)).
+		Where("member_id = ? AND status IN ?", memberId, []string{model.HoldStatusWaiting, model.HoldStatusReady}).
+		Find(&holds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*model.Item, 0)
+	for _, hold := range holds {
+		if err := service.changeHoldStatus(ctx, hold, model.HoldStatusFulfilled); err != nil {
+			return items, err
+		}
+
+		if hold.ItemId == nil || *hold.ItemId == item.Id {
+			continue
+		}
+
+		released, err := service.releaseItem(ctx, *hold.ItemId)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, released)
+	}
+	return items, nil
+}
+
+// ExpirePickups expires ready holds whose pickup window has passed and rolls
+// their items to the next hold in line. It returns the released items and
+// must run inside the unit of work refreshing their books' available copies.
+func (service *holdService) ExpirePickups(ctx context.Context) ([]*model.Item, error) {
+	db := service.db.DB(ctx)
+	var holds []*model.Hold
+	err := db.Where("status = ? AND expires_at < ?", model.HoldStatusReady, time.Now()).Find(&holds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*model.Item, 0, len(holds))
+	for _, hold := range holds {
+		if err := service.changeHoldStatus(ctx, hold, model.HoldStatusExpired); err != nil {
+			return items, err
+		}
+
+		if hold.ItemId == nil {
+			continue
+		}
+
+		item, err := service.releaseItem(ctx, *hold.ItemId)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// RemoveBookHolds drops the waiting holds on a book that is being deleted.
+// Holds on its work stay queued for the other editions.
+func (service *holdService) RemoveBookHolds(ctx context.Context, bookId uint64) error {
+	db := service.db.DB(ctx)
+	return db.Where("book_id = ? AND status = ?", bookId, model.HoldStatusWaiting).Delete(&model.Hold{}).Error
+}
+
+// changeHoldStatus moves a hold on from the status it was read with, saving
+// the columns given along with it. It fails when the hold has moved on since.
+func (service *holdService) changeHoldStatus(ctx context.Context, hold *model.Hold, to string, columns ...string) error {
+	db := service.db.DB(ctx)
+	from := hold.Status
+	hold.Status = to
+	tx := db.Model(hold).Where("status = ?", from).Select(append(columns, "status")).Updates(hold)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return newCirculationError(ErrCodeHoldChanged, fmt.Sprintf("hold %d is no longer %s", hold.Id, from))
+	}
+	return nil
+}
+
+// releaseItem passes a copy off the hold shelf on to the next hold in line.
+func (service *holdService) releaseItem(ctx context.Context, itemId uint64) (*model.Item, error) {
+	var item *model.Item
+	if err := service.db.DB(ctx).Last(&item, itemId).Error; err != nil {
+		return nil, err
+	}
+
+	if item.Status != model.ItemStatusOnHold {
+		return nil, newCirculationError(ErrCodeItemUnavailable, fmt.Sprintf("item %d is no longer %s", itemId, model.ItemStatusOnHold))
+	}
+
+	if _, err := service.TrapItem(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"gorm.io/gorm"
+)
+
+type importService struct {
+	db connectors.SqliteConnector
+}
+
+func NewImportService(db connectors.SqliteConnector) ImportService {
+	return &importService{db}
+}
+
+func (service *importService) CreateImport(ctx context.Context, imp *model.Import) error {
+	return service.db.DB(ctx).Create(imp).Error
+}
+
+func (service *importService) GetImport(ctx context.Context, importId uint64) (*model.Import, error) {
+	db := service.db.DB(ctx)
+	var imp *model.Import
+	if err := db.Last(&imp, importId).Error; err != nil {
+		return nil, err
+	}
+	return imp, nil
+}
+
+func (service *importService) UpdateImport(ctx context.Context, imp *model.Import) error {
+	return service.db.DB(ctx).Save(imp).Error
+}
+
+// LastImportedPosition is the position of the last record with an outcome,
+// so a retried import can carry on after it.
+func (service *importService) LastImportedPosition(ctx context.Context, importId uint64) (int64, error) {
+	db := service.db.DB(ctx)
+	var position int64
+	err := db.Model(&model.ImportRecord{}).Where("import_id = ?", importId).
+		Select("COALESCE(MAX(position), 0)").Scan(&position).Error
+	return position, err
+}
+
+// AddImportRecord stores the outcome of a record and counts it on the import.
+func (service *importService) AddImportRecord(ctx context.Context, imp *model.Import, record *model.ImportRecord) error {
+	record.ImportId = imp.Id
+	return service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		if err := db.Create(record).Error; err != nil {
+			return err
+		}
+
+		column := "succeeded"
+		if record.Status == model.ImportRecordFailed {
+			column = "failed"
+		}
+
+		err := db.Model(&model.Import{}).Where("id = ?", imp.Id).Updates(map[string]interface{}{
+			"total": gorm.Expr("total + 1"),
+			column:  gorm.Expr(column + " + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		connectors.AfterCommit(ctx, func(ctx context.Context) {
+			imp.Total++
+			if record.Status == model.ImportRecordFailed {
+				imp.Failed++
+			} else {
+				imp.Succeeded++
+			}
+		})
+		return nil
+	})
+}
+
+func (service *importService) GetImportRecords(ctx context.Context, importId uint64, status string, lastId uint64, pageSize int) ([]*model.ImportRecord, error) {
+	db := service.db.DB(ctx)
+	var records []*model.ImportRecord
+	qry := db.Model(model.ImportRecord{}).Where("import_id = ? AND id > ?", importId, lastId).Limit(pageSize)
+
+	if status != "" {
+		qry = qry.Where("status = ?", status)
+	}
+
+	if tx := qry.Order("id").Find(&records); tx.Error != nil {
+		fmt.Println("not able to find any import records", tx.Error)
+		return nil, tx.Error
+	}
+	return records, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+)
+
+type itemService struct {
+	db connectors.SqliteConnector
+}
+
+func NewItemService(db connectors.SqliteConnector) ItemService {
+	return &itemService{db}
+}
+
+func (service *itemService) AddItem(ctx context.Context, item *model.Item) error {
+	if item.Status == "" {
+		item.Status = model.ItemStatusAvailable
+	}
+	if item.AcquisitionDate.IsZero() {
+		item.AcquisitionDate = time.Now()
+	}
+	return service.db.DB(ctx).Create(item).Error
+}
+
+// AddItems creates count available copies of a newly catalogued book,
+// barcoded as <isbn>-<copy number>.
+func (service *itemService) AddItems(ctx context.Context, book *model.Book, count int64) ([]*model.Item, error) {
+	items := make([]*model.Item, count)
+	t := time.Now()
+	for idx := range items {
+		items[idx] = &model.Item{
+			Barcode:         fmt.Sprintf("%s-%03d", book.Isbn, idx+1),
+			BookId:          book.Id,
+			ItemType:        model.DefaultItemType,
+			Status:          model.ItemStatusAvailable,
+			AcquisitionDate: t,
+			Condition:       "good",
+		}
+	}
+
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	if err := service.db.DB(ctx).Create(items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (service *itemService) GetItem(ctx context.Context, bookId, itemId uint64) (*model.Item, error) {
+	db := service.db.DB(ctx)
+	var item *model.Item
+	if err := db.Where("book_id = ?", bookId).Last(&item, itemId).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (service *itemService) GetItemByBarcode(ctx context.Context, barcode string) (*model.Item, error) {
+	db := service.db.DB(ctx)
+	var item *model.Item
+	if err := db.Where("barcode = ?", barcode).Last(&item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (service *itemService) GetItems(ctx context.Context, bookId, lastId uint64, pageSize int) ([]*model.Item, error) {
+	db := service.db.DB(ctx)
+	var items []*model.Item
+	tx := db.Model(model.Item{}).Where("book_id = ? AND id > ?", bookId, lastId).Limit(pageSize).Order("id").Find(&items)
+
+	if tx.Error != nil {
+		fmt.Println("not able to find any items", tx.Error)
+		return nil, tx.Error
+	}
+	return items, nil
+}
+
+func (service *itemService) UpdateItem(ctx context.Context, item *model.Item) error {
+	return service.db.DB(ctx).Save(item).Error
+}
+
+func (service *itemService) DeleteItem(ctx context.Context, bookId, itemId uint64) error {
+	db := service.db.DB(ctx)
+	return db.Where("book_id = ?", bookId).Delete(&model.Item{}, itemId).Error
+}
+
+// RemoveBookItems drops the copies of a book that is being deleted, refusing
+// while any of them is on loan or on the hold shelf.
+func (service *itemService) RemoveBookItems(ctx context.Context, bookId uint64) error {
+	db := service.db.DB(ctx)
+	var circulating int64
+	err := db.Model(&model.Item{}).
+		Where("book_id = ? AND status IN ?", bookId, []string{model.ItemStatusOnLoan, model.ItemStatusOnHold}).
+		Count(&circulating).Error
+	if err != nil {
+		return err
+	}
+
+	if circulating > 0 {
+		return newCirculationError(ErrCodeCopiesCirculating, fmt.Sprintf("%d copies of book %d are on loan or on hold", circulating, bookId))
+	}
+	return db.Where("book_id = ?", bookId).Delete(&model.Item{}).Error
+}
+
+// ChangeItemStatus moves an item from one status to another, failing when
+// the item is no longer in the expected status.
+func (service *itemService) ChangeItemStatus(ctx context.Context, itemId uint64, from, to string) error {
+	db := service.db.DB(ctx)
+	tx := db.Model(&model.Item{}).Where("id = ? AND status = ?", itemId, from).Update("status", to)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return newCirculationError(ErrCodeItemUnavailable, fmt.Sprintf("item %d is no longer %s", itemId, from))
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/dutt23/lms/model"
 	"github.com/dutt23/lms/pkg/connectors"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -18,13 +19,15 @@ func NewLoanService(db connectors.SqliteConnector) LoanService {
 	return &loanService{db}
 }
 
-func (service *loanService) SaveLoan(ctx context.Context, memberId, bookId uint64, returnDate *time.Time) (*model.BookLoan, error) {
+func (service *loanService) SaveLoan(ctx context.Context, memberId uint64, item *model.Item, dueDate time.Time) (*model.BookLoan, error) {
 	t := time.Now()
 
 	loan := &model.BookLoan{
-		BookId:   bookId,
+		BookId:   item.BookId,
+		ItemId:   item.Id,
 		MemberId: memberId,
 		LoanDate: t,
+		DueDate:  dueDate,
 	}
 
 	//TODO: Add retry logic here
@@ -44,15 +47,17 @@ func (service *loanService) GetLoan(ctx context.Context, loanId uint64) (*model.
 	return loan, nil
 }
 
-func (service *loanService) GetLoans(ctx context.Context, lastId uint64, pageSize int) ([]*model.BookLoan, error) {
+// GetLoans pages through loans matching an optional compiled filter.
+func (service *loanService) GetLoans(ctx context.Context, where clause.Expression, lastId uint64, pageSize int) ([]*model.BookLoan, error) {
 	db := service.db.DB(ctx)
 	var loans []*model.BookLoan
 	qry := db.Model(model.BookLoan{}).Where("id > ?", lastId).Limit(pageSize)
 
-	tx := qry.Order(clause.OrderByColumn{
-		Column: clause.Column{Name: "published_date"},
-		Desc:   true,
-	}).Find(&loans)
+	if where != nil {
+		qry = qry.Where(where)
+	}
+
+	tx := qry.Order("id").Find(&loans)
 
 	if tx.Error != nil {
 		fmt.Println("not able to find any loans", tx.Error)
@@ -61,18 +66,81 @@ func (service *loanService) GetLoans(ctx context.Context, lastId uint64, pageSiz
 	return loans, nil
 }
 
+// GetOverdueLoans pages through open loans due before the given time.
+func (service *loanService) GetOverdueLoans(ctx context.Context, dueBefore time.Time, lastId uint64, pageSize int) ([]*model.BookLoan, error) {
+	db := service.db.DB(ctx)
+	var loans []*model.BookLoan
+	tx := db.Where("id > ? AND return_date IS NULL AND due_date < ?", lastId, dueBefore).
+		Order("id").Limit(pageSize).Find(&loans)
+
+	if tx.Error != nil {
+		fmt.Println("not able to find any overdue loans", tx.Error)
+		return nil, tx.Error
+	}
+	return loans, nil
+}
+
+func (service *loanService) GetOpenLoans(ctx context.Context, memberId uint64) ([]*model.BookLoan, error) {
+	db := service.db.DB(ctx)
+	var loans []*model.BookLoan
+	if err := db.Where("member_id = ? AND return_date IS NULL", memberId).Order("id").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+// ExportLoans hands every loan, open or returned, to fn in batches, in id
+// order.
+func (service *loanService) ExportLoans(ctx context.Context, fn func([]*model.BookLoan) error) error {
+	return eachBatch(service.db.DB(ctx), func(loan *model.BookLoan) uint64 { return loan.Id }, fn)
+}
+
+// RenewLoan pushes the due date of an open loan. A loan that was returned or
+// renewed since it was read is refused.
+func (service *loanService) RenewLoan(ctx context.Context, loan *model.BookLoan, dueDate time.Time) error {
+	db := service.db.DB(ctx)
+	tx := db.Model(&model.BookLoan{}).
+		Where("id = ? AND return_date IS NULL AND renewal_count = ?", loan.Id, loan.RenewalCount).
+		Updates(map[string]interface{}{"due_date": dueDate, "renewal_count": loan.RenewalCount + 1})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return newCirculationError(ErrCodeLoanChanged, "loan has been returned or renewed meanwhile")
+	}
+
+	loan.DueDate = dueDate
+	loan.RenewalCount++
+	return nil
+}
+
+// CompleteLoan stamps the return date on an open loan. A loan that was
+// returned in the meantime is refused.
 func (service *loanService) CompleteLoan(ctx context.Context, loanId uint64) error {
 	db := service.db.DB(ctx)
-	var loan *model.BookLoan
-	if err := db.Last(&loan, loanId).Error; err != nil {
-		return err
+	tx := db.Model(&model.BookLoan{}).
+		Where("id = ? AND return_date IS NULL", loanId).
+		Update("return_date", time.Now())
+	if tx.Error != nil {
+		return tx.Error
 	}
 
-	loan.ReturnDate = time.Now()
-	return db.Save(loan).Error
+	if tx.RowsAffected == 0 {
+		return newCirculationError(ErrCodeLoanReturned, "loan has already been returned")
+	}
+	return nil
 }
 
 func (service *loanService) DeleteLoan(ctx context.Context, loanId uint64) error {
 	db := service.db.DB(ctx)
-	return db.Delete(&model.BookLoan{}, loanId).Error
+	tx := db.Delete(&model.BookLoan{}, loanId)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/dutt23/lms/cache"
 	"github.com/dutt23/lms/model"
@@ -19,6 +20,17 @@ func NewMemberService(db connectors.SqliteConnector, cache cache.MemberCache) Me
 	return &memberService{db, cache}
 }
 
+func (service *memberService) AddMember(ctx context.Context, member *model.Member) error {
+	if err := service.db.DB(ctx).Create(member).Error; err != nil {
+		return err
+	}
+
+	connectors.AfterCommit(ctx, func(ctx context.Context) {
+		service.cache.StoreMemberMetaInCache(ctx, member)
+	})
+	return nil
+}
+
 func (service *memberService) GetMember(ctx context.Context, memberId uint64) (*model.Member, error) {
 	res := service.cache.GetMember(ctx, memberId)
 	if res != nil {
@@ -50,6 +62,11 @@ func (service *memberService) GetMembers(ctx context.Context, lastId uint64, pag
 	return members, nil
 }
 
+// ExportMembers hands every member to fn in batches, in id order.
+func (service *memberService) ExportMembers(ctx context.Context, fn func([]*model.Member) error) error {
+	return eachBatch(service.db.DB(ctx), func(member *model.Member) uint64 { return member.Id }, fn)
+}
+
 func (service *memberService) GetMemberByEmail(ctx context.Context, email string) (*model.Member, error) {
 	db := service.db.DB(ctx)
 	var member *model.Member
@@ -58,3 +75,72 @@ func (service *memberService) GetMemberByEmail(ctx context.Context, email string
 	}
 	return member, nil
 }
+
+// GetAccount returns the member's fines balance with the ledger lines, newest
+// first.
+func (service *memberService) GetAccount(ctx context.Context, memberId uint64) (*model.MemberAccount, error) {
+	db := service.db.DB(ctx)
+	var lines []*model.Fine
+	if err := db.Where("member_id = ?", memberId).Order("id DESC").Find(&lines).Error; err != nil {
+		return nil, err
+	}
+
+	account := &model.MemberAccount{MemberId: memberId, Lines: lines}
+	for _, line := range lines {
+		account.Balance += line.Amount
+	}
+	return account, nil
+}
+
+func (service *memberService) GetBalance(ctx context.Context, memberId uint64) (int64, error) {
+	return memberBalance(service.db.DB(ctx), memberId)
+}
+
+// RecordPayment credits a full or partial payment to the member's account.
+func (service *memberService) RecordPayment(ctx context.Context, memberId uint64, amount int64, method, reference string) (*model.Fine, error) {
+	return service.credit(ctx, &model.Fine{
+		MemberId:  memberId,
+		Kind:      model.FineKindPayment,
+		Amount:    -amount,
+		Method:    method,
+		Reference: reference,
+	})
+}
+
+// RecordWaiver writes off part or all of the member's balance.
+func (service *memberService) RecordWaiver(ctx context.Context, memberId uint64, amount int64, reasonCode, note string) (*model.Fine, error) {
+	return service.credit(ctx, &model.Fine{
+		MemberId:   memberId,
+		Kind:       model.FineKindWaiver,
+		Amount:     -amount,
+		ReasonCode: reasonCode,
+		Note:       note,
+	})
+}
+
+// VerifyEmail records that the member followed the link mailed to them,
+// keeping the time they first did.
+func (service *memberService) VerifyEmail(ctx context.Context, memberId uint64) error {
+	err := service.db.DB(ctx).Model(&model.Member{}).
+		Where("id = ? AND email_verified_at IS NULL", memberId).
+		Update("email_verified_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+
+	connectors.AfterCommit(ctx, func(ctx context.Context) {
+		service.cache.DeleteMember(ctx, memberId)
+	})
+	return nil
+}
+
+func (service *memberService) credit(ctx context.Context, line *model.Fine) (*model.Fine, error) {
+	t := time.Now()
+	line.AccruedOn = t.UTC().Format(time.DateOnly)
+	line.CreatedAt = t
+
+	if err := service.db.DB(ctx).Create(line).Error; err != nil {
+		return nil, err
+	}
+	return line, nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dutt23/lms/cache"
+	"github.com/dutt23/lms/model"
+)
+
+type metadataService struct {
+	cache     cache.BookCache
+	providers []MetadataProvider
+}
+
+// NewMetadataService consults the providers in order, the first with a
+// record for the ISBN winning. Records found are cached, so a provider is
+// asked about an ISBN at most once a day.
+func NewMetadataService(cache cache.BookCache, providers ...MetadataProvider) MetadataProvider {
+	return &metadataService{cache, providers}
+}
+
+// LookupIsbn returns nil metadata when no provider has a record. A failing
+// provider is skipped, its error only returned when no other has a record.
+func (service *metadataService) LookupIsbn(ctx context.Context, isbn string) (*model.BookMetadata, error) {
+	if metadata, err := service.cache.GetBookMetadata(ctx, isbn); err == nil {
+		return metadata, nil
+	}
+
+	var lastErr error
+	for _, provider := range service.providers {
+		metadata, err := provider.LookupIsbn(ctx, isbn)
+		if err != nil {
+			fmt.Println("unable to look up isbn ", isbn, err)
+			lastErr = err
+			continue
+		}
+
+		if metadata == nil {
+			continue
+		}
+
+		if err := service.cache.StoreBookMetadata(ctx, metadata); err != nil {
+			fmt.Println("unable to cache book metadata ", err)
+		}
+		return metadata, nil
+	}
+	return nil, lastErr
+}
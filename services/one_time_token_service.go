@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/google/uuid"
+)
+
+// ErrTokenUsed is returned for a one time token that was used already, or
+// replaced by a newer one for the same purpose.
+var ErrTokenUsed = errors.New("token has already been used")
+
+type oneTimeTokenService struct {
+	db connectors.SqliteConnector
+}
+
+func NewOneTimeTokenService(db connectors.SqliteConnector) OneTimeTokenService {
+	return &oneTimeTokenService{db}
+}
+
+// AddToken records a token mailed to a member, using up the ones mailed to
+// them before for the same purpose so only the latest mail works.
+func (service *oneTimeTokenService) AddToken(ctx context.Context, token *model.OneTimeToken) error {
+	return service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		err := db.Model(&model.OneTimeToken{}).
+			Where("member_id = ? AND purpose = ? AND used_at IS NULL", token.MemberId, token.Purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		return db.Create(token).Error
+	})
+}
+
+// UseToken uses up the token, failing with ErrTokenUsed when it was used
+// before or is unknown.
+func (service *oneTimeTokenService) UseToken(ctx context.Context, tokenId uuid.UUID, purpose string) (*model.OneTimeToken, error) {
+	var token *model.OneTimeToken
+	err := service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		tx := db.Where("id = ? AND purpose = ?", tokenId, purpose).Limit(1).Find(&token)
+		if tx.Error != nil {
+			return tx.Error
+		}
+
+		if tx.RowsAffected == 0 {
+			return ErrTokenUsed
+		}
+
+		now := time.Now()
+		tx = db.Model(&model.OneTimeToken{}).Where("id = ? AND used_at IS NULL", tokenId).Update("used_at", now)
+		if tx.Error != nil {
+			return tx.Error
+		}
+
+		if tx.RowsAffected == 0 {
+			return ErrTokenUsed
+		}
+		token.UsedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
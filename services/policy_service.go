@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dutt23/lms/config"
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"gorm.io/gorm/clause"
+)
+
+type policyService struct {
+	db       connectors.SqliteConnector
+	defaults config.CirculationConfig
+}
+
+func NewPolicyService(db connectors.SqliteConnector, defaults config.CirculationConfig) PolicyService {
+	return &policyService{db, defaults}
+}
+
+// GetPolicy resolves the policy for a member type and item type. An exact
+// match wins over a member type match, which wins over an item type match and
+// then the catch-all row. Without any row the configured defaults apply.
+func (service *policyService) GetPolicy(ctx context.Context, memberType, itemType string) (*model.CirculationPolicy, error) {
+	db := service.db.DB(ctx)
+	var policies []*model.CirculationPolicy
+	err := db.Where("member_type IN ? AND item_type IN ?", []string{memberType, model.PolicyWildcard}, []string{itemType, model.PolicyWildcard}).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "member_type = ?, item_type = ?", Vars: []interface{}{model.PolicyWildcard, model.PolicyWildcard}, WithoutParentheses: true}}).
+		Limit(1).Find(&policies).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(policies) > 0 {
+		return policies[0], nil
+	}
+
+	return &model.CirculationPolicy{
+		MemberType:     model.PolicyWildcard,
+		ItemType:       model.PolicyWildcard,
+		LoanPeriodDays: service.defaults.LoanPeriodDays,
+		MaxLoans:       service.defaults.MaxLoans,
+		MaxRenewals:    service.defaults.MaxRenewals,
+		GraceDays:      service.defaults.GraceDays,
+		DailyFine:      service.defaults.DailyFine,
+		MaxFine:        service.defaults.MaxFine,
+	}, nil
+}
+
+func (service *policyService) GetPolicies(ctx context.Context) ([]*model.CirculationPolicy, error) {
+	db := service.db.DB(ctx)
+	var policies []*model.CirculationPolicy
+	if err := db.Order("member_type, item_type").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// SavePolicy creates the policy or replaces the one for the same member type
+// and item type.
+func (service *policyService) SavePolicy(ctx context.Context, policy *model.CirculationPolicy) error {
+	db := service.db.DB(ctx)
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "member_type"}, {Name: "item_type"}},
+		UpdateAll: true,
+	}).Create(policy).Error
+}
+
+func (service *policyService) DeletePolicy(ctx context.Context, policyId uint64) error {
+	db := service.db.DB(ctx)
+	return db.Delete(&model.CirculationPolicy{}, policyId).Error
+}
+
+// CheckCheckout applies the member's policy to a checkout of the item and
+// returns the due date of the new loan. A requested due date may shorten the
+// loan but never extend it past the policy's loan period.
+func (service *policyService) CheckCheckout(ctx context.Context, member *model.Member, item *model.Item, requestedDueDate *time.Time) (time.Time, error) {
+	policy, err := service.GetPolicy(ctx, member.MemberType, item.ItemType)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if policy.MaxLoans == 0 {
+		return time.Time{}, newCirculationError(ErrCodeLoanNotPermitted,
+			fmt.Sprintf("%s members cannot borrow %s items", member.MemberType, item.ItemType))
+	}
+
+	balance, err := memberBalance(service.db.DB(ctx), member.Id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if balance > service.defaults.MaxBalance {
+		return time.Time{}, newCirculationError(ErrCodeBalanceExceeded,
+			fmt.Sprintf("outstanding fines of %d exceed the limit of %d", balance, service.defaults.MaxBalance))
+	}
+
+	var openLoans int64
+	err = service.db.DB(ctx).Model(&model.BookLoan{}).
+		Where("member_id = ? AND return_date IS NULL", member.Id).
+		Count(&openLoans).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if openLoans >= policy.MaxLoans {
+		return time.Time{}, newCirculationError(ErrCodeMaxLoansExceeded,
+			fmt.Sprintf("member already has %d of %d allowed loans", openLoans, policy.MaxLoans))
+	}
+
+	dueDate := policy.DueDate(time.Now())
+	if requestedDueDate == nil {
+		return dueDate, nil
+	}
+
+	if requestedDueDate.After(dueDate) {
+		return time.Time{}, newCirculationError(ErrCodeDueDateBeyondPolicy,
+			fmt.Sprintf("due date cannot be later than %s", dueDate.Format(time.DateOnly)))
+	}
+	return *requestedDueDate, nil
+}
+
+// CheckRenewal applies the member's policy to a renewal of the loan and
+// returns the new due date. Loans of titles other members are waiting for
+// cannot be renewed.
+func (service *policyService) CheckRenewal(ctx context.Context, member *model.Member, item *model.Item, loan *model.BookLoan) (time.Time, error) {
+	if loan.ReturnDate != nil {
+		return time.Time{}, newCirculationError(ErrCodeLoanReturned, "loan has already been returned")
+	}
+
+	policy, err := service.GetPolicy(ctx, member.MemberType, item.ItemType)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if loan.RenewalCount >= policy.MaxRenewals {
+		return time.Time{}, newCirculationError(ErrCodeMaxRenewalsReached,
+			fmt.Sprintf("loan has been renewed %d of %d allowed times", loan.RenewalCount, policy.MaxRenewals))
+	}
+
+	var holds int64
+	err = service.db.DB(ctx).Model(&model.Hold{}).
+		Where(holdsOnBook(loan.BookId)).
+		Where("status = ? AND member_id <> ?", model.HoldStatusWaiting, member.Id).
+		Count(&holds).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if holds > 0 {
+		return time.Time{}, newCirculationError(ErrCodeOnHold, "another member has a hold on this title")
+	}
+
+	dueDate := policy.DueDate(time.Now())
+	if dueDate.Before(loan.DueDate) {
+		dueDate = loan.DueDate
+	}
+	return dueDate, nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/dutt23/lms/pkg/isbn"
+	"gorm.io/gorm/clause"
+)
+
+var ErrEmptySearchQuery = errors.New("search query has no terms")
+
+// SearchCursor marks the last hit of a search page. The next page starts
+// right after it in (score, id) order.
+type SearchCursor struct {
+	Score float64 `json:"s"`
+	Id    uint64  `json:"id"`
+}
+
+// Matched terms are wrapped in private use runes by FTS5, turned into <mark>
+// tags only once the catalogue text around them is HTML escaped.
+const (
+	markOpen  = "\uE000"
+	markClose = "\uE001"
+)
+
+var markReplacer = strings.NewReplacer(markOpen, "<mark>", markClose, "</mark>")
+
+// Column weights for bm25, in books_fts column order: title, author, isbn,
+// language, subjects.
+const searchColumns = `books.*,
+	bm25(books_fts, 10.0, 5.0, 2.0, 1.0, 3.0) AS score,
+	highlight(books_fts, 0, '` + markOpen + `', '` + markClose + `') AS title_highlight,
+	highlight(books_fts, 1, '` + markOpen + `', '` + markClose + `') AS author_highlight,
+	snippet(books_fts, -1, '` + markOpen + `', '` + markClose + `', '…', 16) AS snippet`
+
+type searchService struct {
+	db connectors.SqliteConnector
+}
+
+func NewSearchService(db connectors.SqliteConnector) SearchService {
+	return &searchService{db}
+}
+
+func (service *searchService) SearchBooks(ctx context.Context, query string, selected *model.FacetSelection, after *SearchCursor, pageSize int) ([]*model.BookSearchHit, error) {
+	match, err := matchExpression(query)
+	if err != nil {
+		return nil, err
+	}
+
+	db := service.db.DB(ctx)
+	matches := db.Table("books_fts").Select(searchColumns).
+		Joins("JOIN books ON books.id = books_fts.rowid").
+		Where("books_fts MATCH ?", match)
+
+	if where := BookFacetFilter(selected); where != nil {
+		matches = matches.Where(where)
+	}
+
+	qry := db.Table("(?) AS hits", matches)
+
+	if after != nil {
+		qry = qry.Where("score > ? OR (score = ? AND id > ?)", after.Score, after.Score, after.Id)
+	}
+
+	var hits []*model.BookSearchHit
+	if err := qry.Order("score, id").Limit(pageSize).Scan(&hits).Error; err != nil {
+		fmt.Println("unable to search books ", err)
+		return nil, err
+	}
+
+	for _, hit := range hits {
+		hit.TitleHighlight = markHTML(hit.TitleHighlight)
+		hit.AuthorHighlight = markHTML(hit.AuthorHighlight)
+		hit.Snippet = markHTML(hit.Snippet)
+	}
+	return hits, nil
+}
+
+// markHTML escapes a highlighted fragment and marks its matched terms.
+func markHTML(fragment string) string {
+	return markReplacer.Replace(html.EscapeString(fragment))
+}
+
+// SearchFacets counts the facet buckets of every book matching the query.
+func (service *searchService) SearchFacets(ctx context.Context, query string, selected *model.FacetSelection) (*model.BookFacets, error) {
+	match, err := matchExpression(query)
+	if err != nil {
+		return nil, err
+	}
+
+	base := clause.Expr{SQL: "books.id IN (SELECT rowid FROM books_fts WHERE books_fts MATCH ?)", Vars: []interface{}{match}}
+	return countBookFacets(service.db.DB(ctx), base, selected)
+}
+
+// FindBooks pages through the books matching a compiled query in id order,
+// offset rows in, every book with its credits.
+func (service *searchService) FindBooks(ctx context.Context, where clause.Expression, offset, limit int) ([]*model.Book, error) {
+	db := service.db.DB(ctx)
+	var books []*model.Book
+	tx := db.Model(&model.Book{}).Where(where).Order("id").Offset(offset).Limit(limit).Find(&books)
+	if tx.Error != nil {
+		fmt.Println("unable to find books ", tx.Error)
+		return nil, tx.Error
+	}
+
+	if err := loadContributors(db, books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (service *searchService) CountBooks(ctx context.Context, where clause.Expression) (int64, error) {
+	var count int64
+	if err := service.db.DB(ctx).Model(&model.Book{}).Where(where).Count(&count).Error; err != nil {
+		fmt.Println("unable to count books ", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// matchExpression turns a user query into an FTS5 match expression. Every
+// term is quoted so no FTS5 syntax can leak through; "double quoted" text is
+// kept as a phrase and a trailing * makes a term or phrase a prefix match.
+// Terms are combined with AND.
+func matchExpression(query string) (string, error) {
+	var terms []string
+	runes := []rune(query)
+
+	for idx := 0; idx < len(runes); {
+		if unicode.IsSpace(runes[idx]) {
+			idx++
+			continue
+		}
+
+		var term []rune
+		if runes[idx] == '"' {
+			end := idx + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			term = runes[idx+1 : end]
+			idx = end + 1
+		} else {
+			end := idx
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			term = runes[idx:end]
+			idx = end
+		}
+
+		prefix := false
+		if idx < len(runes) && runes[idx] == '*' {
+			prefix = true
+			idx++
+		}
+
+		text := string(term)
+		if strings.HasSuffix(text, "*") {
+			prefix = true
+			text = strings.TrimRight(text, "*")
+		}
+
+		// books are indexed under their ISBN-13, whichever form is searched
+		if canonical, err := isbn.Parse(text); err == nil && !prefix {
+			text = canonical
+		}
+
+		if !hasSearchableRune(text) {
+			continue
+		}
+
+		quoted := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+		if prefix {
+			quoted += "*"
+		}
+		terms = append(terms, quoted)
+	}
+
+	if len(terms) == 0 {
+		return "", ErrEmptySearchQuery
+	}
+	return strings.Join(terms, " "), nil
+}
+
+func hasSearchableRune(text string) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
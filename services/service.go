@@ -6,28 +6,175 @@ import (
 
 	"github.com/dutt23/lms/cache"
 	"github.com/dutt23/lms/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
 )
 
 type BookService interface {
+	AddBook(ctx context.Context, book *model.Book) error
 	GetBook(ctx context.Context, bookId uint64) (*model.Book, error)
-	ChangeAvailableCopies(ctx context.Context, bookId uint64, count int64) error
+	GetBookByIsbn(ctx context.Context, isbn string) (*model.Book, error)
+	ChangeAvailableCopies(ctx context.Context, bookId uint64, delta int64) error
+	RefreshAvailableCopies(ctx context.Context, bookId uint64) (*model.Book, error)
 	GetBooks(ctx context.Context, lastId uint64, pageSize int) ([]*model.Book, error)
+	GetBookFacets(ctx context.Context, where clause.Expression, selected *model.FacetSelection) (*model.BookFacets, error)
+	ExportBooks(ctx context.Context, fn func([]*model.Book) error) error
 }
+type ContributorService interface {
+	AddContributor(ctx context.Context, contributor *model.Contributor) error
+	GetContributor(ctx context.Context, contributorId uint64) (*model.Contributor, error)
+	GetContributors(ctx context.Context, name string, lastId uint64, pageSize int) ([]*model.Contributor, error)
+	UpdateContributor(ctx context.Context, contributor *model.Contributor) error
+	DeleteContributor(ctx context.Context, contributorId uint64) error
+	GetBibliography(ctx context.Context, contributorId uint64) ([]*model.ContributorCredit, error)
+	SetBookContributors(ctx context.Context, book *model.Book) error
+	RemoveBookContributors(ctx context.Context, bookId uint64) error
+	LoadContributors(ctx context.Context, books []*model.Book) error
+}
+
+type SubjectService interface {
+	AddSubject(ctx context.Context, subject *model.Subject) error
+	GetSubject(ctx context.Context, subjectId uint64) (*model.Subject, error)
+	GetSubjectTree(ctx context.Context, subjectId uint64) ([]*model.Subject, error)
+	GetSubjectPath(ctx context.Context, subjectId uint64) ([]*model.Subject, error)
+	UpdateSubject(ctx context.Context, subject *model.Subject) error
+	DeleteSubject(ctx context.Context, subjectId uint64) error
+	GetSubjectBooks(ctx context.Context, subjectId, lastId uint64, pageSize int) ([]*model.Book, error)
+	SetBookSubjects(ctx context.Context, book *model.Book, subjectIds []uint64) error
+	SetBookTags(ctx context.Context, book *model.Book, tags []string) error
+	RemoveBookClassification(ctx context.Context, bookId uint64) error
+	LoadClassification(ctx context.Context, books []*model.Book) error
+	GetTags(ctx context.Context) ([]*model.TagCount, error)
+}
+
+type WorkService interface {
+	AddWork(ctx context.Context, work *model.Work) error
+	GetWork(ctx context.Context, workId uint64) (*model.Work, error)
+	GetWorks(ctx context.Context, title string, lastId uint64, pageSize int) ([]*model.Work, error)
+	UpdateWork(ctx context.Context, work *model.Work) error
+	DeleteWork(ctx context.Context, workId uint64) error
+	GetEditions(ctx context.Context, workId uint64) ([]*model.Book, error)
+	GetEditionIds(ctx context.Context, workIds []uint64) (map[uint64][]uint64, error)
+}
+
 type MemberService interface {
+	AddMember(ctx context.Context, member *model.Member) error
 	GetMember(ctx context.Context, memberId uint64) (*model.Member, error)
 	GetMemberByEmail(ctx context.Context, email string) (*model.Member, error)
 	GetMembers(ctx context.Context, lastId uint64, pageSize int) ([]*model.Member, error)
+	ExportMembers(ctx context.Context, fn func([]*model.Member) error) error
+	GetAccount(ctx context.Context, memberId uint64) (*model.MemberAccount, error)
+	GetBalance(ctx context.Context, memberId uint64) (int64, error)
+	RecordPayment(ctx context.Context, memberId uint64, amount int64, method, reference string) (*model.Fine, error)
+	RecordWaiver(ctx context.Context, memberId uint64, amount int64, reasonCode, note string) (*model.Fine, error)
+	VerifyEmail(ctx context.Context, memberId uint64) error
+}
+
+type SessionService interface {
+	AddSession(ctx context.Context, session *model.Session) error
+	GetSession(ctx context.Context, sessionId uuid.UUID) (*model.Session, error)
+	GetSessionByAccessToken(ctx context.Context, tokenId uuid.UUID) (*model.Session, error)
+	GetActiveSessions(ctx context.Context, username string) ([]*model.Session, error)
+	RotateSession(ctx context.Context, session, next *model.Session) error
+	RevokeFamily(ctx context.Context, familyId uuid.UUID) ([]*model.Session, error)
+	RevokeUserSessions(ctx context.Context, username string, keepFamilyId uuid.UUID) ([]*model.Session, error)
+}
+
+type OneTimeTokenService interface {
+	AddToken(ctx context.Context, token *model.OneTimeToken) error
+	UseToken(ctx context.Context, tokenId uuid.UUID, purpose string) (*model.OneTimeToken, error)
+}
+
+type CredentialService interface {
+	SetPassword(ctx context.Context, memberId uint64, password string) error
+	Authenticate(ctx context.Context, memberId uint64, password string) error
 }
 
 type LoanService interface {
-	SaveLoan(ctx context.Context, memberId, bookId uint64, returnDate *time.Time) (*model.BookLoan, error)
+	SaveLoan(ctx context.Context, memberId uint64, item *model.Item, dueDate time.Time) (*model.BookLoan, error)
 	GetLoan(ctx context.Context, loanId uint64) (*model.BookLoan, error)
-	GetLoans(ctx context.Context, lastId uint64, pageSize int) ([]*model.BookLoan, error)
+	GetLoans(ctx context.Context, where clause.Expression, lastId uint64, pageSize int) ([]*model.BookLoan, error)
+	GetOverdueLoans(ctx context.Context, dueBefore time.Time, lastId uint64, pageSize int) ([]*model.BookLoan, error)
+	GetOpenLoans(ctx context.Context, memberId uint64) ([]*model.BookLoan, error)
+	ExportLoans(ctx context.Context, fn func([]*model.BookLoan) error) error
+	RenewLoan(ctx context.Context, loan *model.BookLoan, dueDate time.Time) error
 	CompleteLoan(ctx context.Context, loanId uint64) error
 	DeleteLoan(ctx context.Context, loanId uint64) error
 }
 
+type PolicyService interface {
+	GetPolicy(ctx context.Context, memberType, itemType string) (*model.CirculationPolicy, error)
+	GetPolicies(ctx context.Context) ([]*model.CirculationPolicy, error)
+	SavePolicy(ctx context.Context, policy *model.CirculationPolicy) error
+	DeletePolicy(ctx context.Context, policyId uint64) error
+	CheckCheckout(ctx context.Context, member *model.Member, item *model.Item, requestedDueDate *time.Time) (time.Time, error)
+	CheckRenewal(ctx context.Context, member *model.Member, item *model.Item, loan *model.BookLoan) (time.Time, error)
+}
+
+type FineService interface {
+	AccrueOverdueFines(ctx context.Context, loan *model.BookLoan, policy *model.CirculationPolicy, now time.Time) (int64, error)
+}
+
+type HoldService interface {
+	PlaceHold(ctx context.Context, memberId, bookId uint64) (*model.Hold, error)
+	PlaceWorkHold(ctx context.Context, memberId, workId uint64) (*model.Hold, error)
+	GetHold(ctx context.Context, holdId uint64) (*model.Hold, error)
+	GetHolds(ctx context.Context, memberId, bookId, workId, lastId uint64, pageSize int) ([]*model.Hold, error)
+	HasActiveHold(ctx context.Context, memberId, workId uint64) (bool, error)
+	HasWaitingHolds(ctx context.Context, bookId uint64) (bool, error)
+	QueuePosition(ctx context.Context, hold *model.Hold) (int64, error)
+	CancelHold(ctx context.Context, hold *model.Hold) (*model.Item, error)
+	TrapItem(ctx context.Context, item *model.Item) (*model.Hold, error)
+	GetReadyHold(ctx context.Context, itemId uint64) (*model.Hold, error)
+	FulfillHold(ctx context.Context, memberId uint64, item *model.Item) ([]*model.Item, error)
+	ExpirePickups(ctx context.Context) ([]*model.Item, error)
+	RemoveBookHolds(ctx context.Context, bookId uint64) error
+}
+
+type ImportService interface {
+	CreateImport(ctx context.Context, imp *model.Import) error
+	GetImport(ctx context.Context, importId uint64) (*model.Import, error)
+	UpdateImport(ctx context.Context, imp *model.Import) error
+	LastImportedPosition(ctx context.Context, importId uint64) (int64, error)
+	AddImportRecord(ctx context.Context, imp *model.Import, record *model.ImportRecord) error
+	GetImportRecords(ctx context.Context, importId uint64, status string, lastId uint64, pageSize int) ([]*model.ImportRecord, error)
+}
+
+type SearchService interface {
+	SearchBooks(ctx context.Context, query string, selected *model.FacetSelection, after *SearchCursor, pageSize int) ([]*model.BookSearchHit, error)
+	SearchFacets(ctx context.Context, query string, selected *model.FacetSelection) (*model.BookFacets, error)
+	FindBooks(ctx context.Context, where clause.Expression, offset, limit int) ([]*model.Book, error)
+	CountBooks(ctx context.Context, where clause.Expression) (int64, error)
+}
+
+type HarvestService interface {
+	GetBook(ctx context.Context, bookId uint64) (*model.Book, error)
+	GetBooks(ctx context.Context, query *HarvestQuery, lastId uint64, pageSize int) ([]*model.Book, error)
+	CountBooks(ctx context.Context, query *HarvestQuery) (int64, error)
+	EarliestDatestamp(ctx context.Context) (time.Time, error)
+	GetLanguages(ctx context.Context) ([]string, error)
+}
+
+// MetadataProvider is a source of bibliographic metadata for an ISBN. A
+// provider without a record for it returns nil metadata and no error.
+type MetadataProvider interface {
+	LookupIsbn(ctx context.Context, isbn string) (*model.BookMetadata, error)
+}
+
+type ItemService interface {
+	AddItem(ctx context.Context, item *model.Item) error
+	AddItems(ctx context.Context, book *model.Book, count int64) ([]*model.Item, error)
+	GetItem(ctx context.Context, bookId, itemId uint64) (*model.Item, error)
+	GetItemByBarcode(ctx context.Context, barcode string) (*model.Item, error)
+	GetItems(ctx context.Context, bookId, lastId uint64, pageSize int) ([]*model.Item, error)
+	UpdateItem(ctx context.Context, item *model.Item) error
+	DeleteItem(ctx context.Context, bookId, itemId uint64) error
+	RemoveBookItems(ctx context.Context, bookId uint64) error
+	ChangeItemStatus(ctx context.Context, itemId uint64, from, to string) error
+}
+
 type AnalyticsService interface {
 	GetBookListAnalytics(ctx context.Context, bookIds []uint64) (*cache.BookAnalytics, error)
+	GetWorkListAnalytics(ctx context.Context, editions map[uint64][]uint64) (*cache.WorkAnalytics, error)
 	GetMemberListAnalytics(ctx context.Context, memberIds []uint64) (*cache.MemberAnalytics, error)
 }
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/google/uuid"
+)
+
+// ErrSessionRotated is returned when the refresh token of a session was
+// already exchanged for a new one.
+var ErrSessionRotated = errors.New("refresh token has already been used")
+
+type sessionService struct {
+	db connectors.SqliteConnector
+}
+
+func NewSessionService(db connectors.SqliteConnector) SessionService {
+	return &sessionService{db}
+}
+
+func (service *sessionService) AddSession(ctx context.Context, session *model.Session) error {
+	return service.db.DB(ctx).Create(session).Error
+}
+
+func (service *sessionService) GetSession(ctx context.Context, sessionId uuid.UUID) (*model.Session, error) {
+	var session *model.Session
+	if err := service.db.DB(ctx).Take(&session, "id = ?", sessionId).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetSessionByAccessToken finds the session the access token was issued with.
+func (service *sessionService) GetSessionByAccessToken(ctx context.Context, tokenId uuid.UUID) (*model.Session, error) {
+	var session *model.Session
+	if err := service.db.DB(ctx).Take(&session, "access_token_id = ?", tokenId).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetActiveSessions lists the sessions the user can still renew, one for each
+// device logged in, newest first.
+func (service *sessionService) GetActiveSessions(ctx context.Context, username string) ([]*model.Session, error) {
+	var sessions []*model.Session
+	tx := service.db.DB(ctx).
+		Where("username = ? AND is_revoked = ? AND rotated_at IS NULL AND expires_at > ?", username, false, time.Now()).
+		Order("created_at DESC").Find(&sessions)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return sessions, nil
+}
+
+// RotateSession marks the session used and stores next in its family. It
+// fails with ErrSessionRotated when another renewal got to the session first.
+func (service *sessionService) RotateSession(ctx context.Context, session, next *model.Session) error {
+	next.FamilyId = session.FamilyId
+	return service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		now := time.Now()
+		tx := db.Model(&model.Session{}).
+			Where("id = ? AND rotated_at IS NULL AND is_revoked = ?", session.Id, false).
+			Update("rotated_at", now)
+		if tx.Error != nil {
+			return tx.Error
+		}
+
+		if tx.RowsAffected == 0 {
+			return ErrSessionRotated
+		}
+
+		if err := db.Create(next).Error; err != nil {
+			return err
+		}
+
+		connectors.AfterCommit(ctx, func(ctx context.Context) {
+			session.RotatedAt = &now
+		})
+		return nil
+	})
+}
+
+// RevokeFamily revokes every session descending from the same login, returning
+// the ones it revoked.
+func (service *sessionService) RevokeFamily(ctx context.Context, familyId uuid.UUID) ([]*model.Session, error) {
+	return service.revokeSessions(ctx, "family_id = ?", familyId)
+}
+
+// RevokeUserSessions logs the user out of every device but the one of the
+// keepFamilyId login, returning the sessions it revoked.
+func (service *sessionService) RevokeUserSessions(ctx context.Context, username string, keepFamilyId uuid.UUID) ([]*model.Session, error) {
+	return service.revokeSessions(ctx, "username = ? AND family_id <> ?", username, keepFamilyId)
+}
+
+func (service *sessionService) revokeSessions(ctx context.Context, query string, args ...interface{}) ([]*model.Session, error) {
+	var sessions []*model.Session
+	err := service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		tx := db.Where(query, args...).Where("is_revoked = ?", false).Find(&sessions)
+		if tx.Error != nil {
+			return tx.Error
+		}
+
+		return db.Model(&model.Session{}).Where(query, args...).Update("is_revoked", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
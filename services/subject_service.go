@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// subjectTreeSQL selects the ids of the subjects meeting a condition and of
+// all their descendants.
+const subjectTreeSQL = `WITH RECURSIVE "subject_tree" ("id") AS (
+	SELECT "subjects"."id" FROM "subjects" WHERE ?
+	UNION
+	SELECT "subjects"."id" FROM "subjects" JOIN "subject_tree" ON "subjects"."parent_id" = "subject_tree"."id"
+) SELECT "id" FROM "subject_tree"`
+
+// BooksUnderSubject matches the books classed under a subject meeting the
+// condition or under any of its descendants.
+func BooksUnderSubject(condition clause.Expression) clause.Expression {
+	return clause.Expr{
+		SQL:  `books.id IN (SELECT book_subjects.book_id FROM book_subjects WHERE book_subjects.subject_id IN (` + subjectTreeSQL + `))`,
+		Vars: []interface{}{condition},
+	}
+}
+
+type subjectService struct {
+	db connectors.SqliteConnector
+}
+
+func NewSubjectService(db connectors.SqliteConnector) SubjectService {
+	return &subjectService{db}
+}
+
+func (service *subjectService) AddSubject(ctx context.Context, subject *model.Subject) error {
+	return service.db.DB(ctx).Create(subject).Error
+}
+
+func (service *subjectService) GetSubject(ctx context.Context, subjectId uint64) (*model.Subject, error) {
+	var subject *model.Subject
+	if err := service.db.DB(ctx).Take(&subject, subjectId).Error; err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+// GetSubjectTree returns the children of a subject, or the roots for 0,
+// each with its own children down to the leaves, in name order.
+func (service *subjectService) GetSubjectTree(ctx context.Context, subjectId uint64) ([]*model.Subject, error) {
+	var subjects []*model.Subject
+	if err := service.db.DB(ctx).Order("name, id").Find(&subjects).Error; err != nil {
+		return nil, err
+	}
+
+	children := map[uint64][]*model.Subject{}
+	for _, subject := range subjects {
+		var parentId uint64
+		if subject.ParentId != nil {
+			parentId = *subject.ParentId
+		}
+		children[parentId] = append(children[parentId], subject)
+	}
+
+	for _, subject := range subjects {
+		subject.Children = children[subject.Id]
+	}
+	return children[subjectId], nil
+}
+
+// GetSubjectPath lists a subject's ancestors from the root down, ending with
+// the subject itself.
+func (service *subjectService) GetSubjectPath(ctx context.Context, subjectId uint64) ([]*model.Subject, error) {
+	var path []*model.Subject
+	tx := service.db.DB(ctx).Raw(`WITH RECURSIVE "path" AS (
+		SELECT "subjects".*, 0 AS "depth" FROM "subjects" WHERE "id" = ?
+		UNION ALL
+		SELECT "subjects".*, "path"."depth" + 1 FROM "subjects" JOIN "path" ON "subjects"."id" = "path"."parent_id"
+	) SELECT "id", "parent_id", "name", "kind", "dewey", "lcc" FROM "path" ORDER BY "depth" DESC`, subjectId).Scan(&path)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return path, nil
+}
+
+func (service *subjectService) UpdateSubject(ctx context.Context, subject *model.Subject) error {
+	return service.db.DB(ctx).Save(subject).Error
+}
+
+// DeleteSubject removes a subject. Callers check it has no children and no
+// books classed under it.
+func (service *subjectService) DeleteSubject(ctx context.Context, subjectId uint64) error {
+	return service.db.DB(ctx).Delete(&model.Subject{}, subjectId).Error
+}
+
+// GetSubjectBooks pages through the books classed under the subject or any
+// of its descendants, in id order.
+func (service *subjectService) GetSubjectBooks(ctx context.Context, subjectId, lastId uint64, pageSize int) ([]*model.Book, error) {
+	db := service.db.DB(ctx)
+	var books []*model.Book
+	tx := db.Model(&model.Book{}).
+		Where(BooksUnderSubject(clause.Eq{Column: clause.Column{Table: "subjects", Name: "id"}, Value: subjectId})).
+		Where("id > ?", lastId).Order("id").Limit(pageSize).Find(&books)
+	if tx.Error != nil {
+		fmt.Println("not able to find any books", tx.Error)
+		return nil, tx.Error
+	}
+	return books, nil
+}
+
+// SetBookSubjects classes a saved book under exactly the subjects given.
+func (service *subjectService) SetBookSubjects(ctx context.Context, book *model.Book, subjectIds []uint64) error {
+	return service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		var subjects []*model.Subject
+		if len(subjectIds) > 0 {
+			if err := db.Where("id IN ?", subjectIds).Order("name, id").Find(&subjects).Error; err != nil {
+				return err
+			}
+		}
+
+		found := map[uint64]bool{}
+		for _, subject := range subjects {
+			found[subject.Id] = true
+		}
+
+		for _, subjectId := range subjectIds {
+			if !found[subjectId] {
+				return fmt.Errorf("unable to locate subject with Id %d %w", subjectId, gorm.ErrRecordNotFound)
+			}
+		}
+
+		if err := db.Where("book_id = ?", book.Id).Delete(&model.BookSubject{}).Error; err != nil {
+			return err
+		}
+
+		rows := make([]*model.BookSubject, len(subjects))
+		for idx, subject := range subjects {
+			rows[idx] = &model.BookSubject{BookId: book.Id, SubjectId: subject.Id}
+		}
+
+		if len(rows) > 0 {
+			if err := db.Create(rows).Error; err != nil {
+				return err
+			}
+		}
+		book.Classification = subjects
+		return nil
+	})
+}
+
+// SetBookTags replaces the tags of a saved book, adding the tags no book
+// carried before. Tags are trimmed and lower cased.
+func (service *subjectService) SetBookTags(ctx context.Context, book *model.Book, tags []string) error {
+	names := normalizeTags(tags)
+	return service.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		db := service.db.DB(ctx)
+		if err := db.Where("book_id = ?", book.Id).Delete(&model.BookTag{}).Error; err != nil {
+			return err
+		}
+
+		if len(names) == 0 {
+			book.Tags = nil
+			return nil
+		}
+
+		rows := make([]*model.Tag, len(names))
+		for idx, name := range names {
+			rows[idx] = &model.Tag{Name: name}
+		}
+
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(rows).Error; err != nil {
+			return err
+		}
+
+		var tagIds []uint64
+		if err := db.Model(&model.Tag{}).Where("name IN ?", names).Pluck("id", &tagIds).Error; err != nil {
+			return err
+		}
+
+		bookTags := make([]*model.BookTag, len(tagIds))
+		for idx, tagId := range tagIds {
+			bookTags[idx] = &model.BookTag{BookId: book.Id, TagId: tagId}
+		}
+
+		if err := db.Create(bookTags).Error; err != nil {
+			return err
+		}
+		book.Tags = names
+		return nil
+	})
+}
+
+// RemoveBookClassification drops the subjects and tags of a book that is
+// being deleted.
+func (service *subjectService) RemoveBookClassification(ctx context.Context, bookId uint64) error {
+	db := service.db.DB(ctx)
+	if err := db.Where("book_id = ?", bookId).Delete(&model.BookSubject{}).Error; err != nil {
+		return err
+	}
+	return db.Where("book_id = ?", bookId).Delete(&model.BookTag{}).Error
+}
+
+// LoadClassification reads the subjects and tags of the books.
+func (service *subjectService) LoadClassification(ctx context.Context, books []*model.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	db := service.db.DB(ctx)
+	bookIds := make([]uint64, len(books))
+	byId := make(map[uint64]*model.Book, len(books))
+	for idx, book := range books {
+		bookIds[idx] = book.Id
+		byId[book.Id] = book
+		book.Classification = nil
+		book.Tags = nil
+	}
+
+	var subjects []struct {
+		BookId uint64
+		model.Subject
+	}
+	tx := db.Table("book_subjects").
+		Select("book_subjects.book_id, subjects.*").
+		Joins("JOIN subjects ON subjects.id = book_subjects.subject_id").
+		Where("book_subjects.book_id IN ?", bookIds).
+		Order("subjects.name, subjects.id").
+		Find(&subjects)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	for idx := range subjects {
+		book := byId[subjects[idx].BookId]
+		book.Classification = append(book.Classification, &subjects[idx].Subject)
+	}
+
+	var tags []struct {
+		BookId uint64
+		Name   string
+	}
+	tx = db.Table("book_tags").
+		Select("book_tags.book_id, tags.name").
+		Joins("JOIN tags ON tags.id = book_tags.tag_id").
+		Where("book_tags.book_id IN ?", bookIds).
+		Order("tags.name").
+		Find(&tags)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	for _, tag := range tags {
+		book := byId[tag.BookId]
+		book.Tags = append(book.Tags, tag.Name)
+	}
+	return nil
+}
+
+// GetTags lists the tags in use with the number of books carrying each, the
+// most used first.
+func (service *subjectService) GetTags(ctx context.Context) ([]*model.TagCount, error) {
+	var tags []*model.TagCount
+	tx := service.db.DB(ctx).Table("tags").
+		Select("tags.name, COUNT(*) AS count").
+		Joins("JOIN book_tags ON book_tags.tag_id = tags.id").
+		Group("tags.name").
+		Order("count DESC, tags.name").
+		Find(&tags)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return tags, nil
+}
+
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, tag := range tags {
+		name := strings.ToLower(strings.TrimSpace(tag))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"gorm.io/gorm"
+)
+
+type workService struct {
+	db connectors.SqliteConnector
+}
+
+func NewWorkService(db connectors.SqliteConnector) WorkService {
+	return &workService{db}
+}
+
+func (service *workService) AddWork(ctx context.Context, work *model.Work) error {
+	return service.db.DB(ctx).Create(work).Error
+}
+
+func (service *workService) GetWork(ctx context.Context, workId uint64) (*model.Work, error) {
+	var work *model.Work
+	if err := service.db.DB(ctx).Take(&work, workId).Error; err != nil {
+		return nil, err
+	}
+	return work, nil
+}
+
+// GetWorks pages through the works in id order, those whose title contains
+// title when it is given.
+func (service *workService) GetWorks(ctx context.Context, title string, lastId uint64, pageSize int) ([]*model.Work, error) {
+	var works []*model.Work
+	qry := service.db.DB(ctx).Model(&model.Work{}).Where("id > ?", lastId)
+	if title != "" {
+		qry = qry.Where("title LIKE ?", "%"+title+"%")
+	}
+
+	if err := qry.Order("id").Limit(pageSize).Find(&works).Error; err != nil {
+		fmt.Println("not able to find any works", err)
+		return nil, err
+	}
+	return works, nil
+}
+
+func (service *workService) UpdateWork(ctx context.Context, work *model.Work) error {
+	return service.db.DB(ctx).Save(work).Error
+}
+
+// DeleteWork removes a work. Callers check no edition belongs to it anymore.
+func (service *workService) DeleteWork(ctx context.Context, workId uint64) error {
+	return service.db.DB(ctx).Delete(&model.Work{}, workId).Error
+}
+
+// GetEditions lists the books of a work, oldest edition first.
+func (service *workService) GetEditions(ctx context.Context, workId uint64) ([]*model.Book, error) {
+	var books []*model.Book
+	tx := service.db.DB(ctx).Where("work_id = ?", workId).Order("published_date, id").Find(&books)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return books, nil
+}
+
+// GetEditionIds maps each of the works to the ids of its books.
+func (service *workService) GetEditionIds(ctx context.Context, workIds []uint64) (map[uint64][]uint64, error) {
+	editions := make(map[uint64][]uint64, len(workIds))
+	if len(workIds) == 0 {
+		return editions, nil
+	}
+
+	var books []*model.Book
+	tx := service.db.DB(ctx).Select("id", "work_id").Where("work_id IN ?", workIds).Order("id").Find(&books)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	for _, book := range books {
+		editions[book.WorkId] = append(editions[book.WorkId], book.Id)
+	}
+	return editions, nil
+}
+
+// resolveWork puts a book in its work. A book naming no work joins the first
+// one with the same title, whatever its case, and author statement, added
+// when there is none.
+func resolveWork(db *gorm.DB, book *model.Book) error {
+	if book.WorkId != 0 {
+		var work *model.Work
+		if err := db.Take(&work, book.WorkId).Error; err != nil {
+			return fmt.Errorf("unable to locate work with Id %d %w", book.WorkId, err)
+		}
+		return nil
+	}
+
+	var work *model.Work
+	tx := db.Where("lower(title) = lower(?) AND author = ?", book.Title, book.Author).Order("id").Limit(1).Find(&work)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		work = &model.Work{Title: book.Title, Author: book.Author}
+		if err := db.Create(work).Error; err != nil {
+			return err
+		}
+	}
+	book.WorkId = work.Id
+	return nil
+}
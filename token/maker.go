@@ -3,7 +3,7 @@ package token
 import "time"
 
 type Maker interface {
-	CreateToken(username string, duration time.Duration) (string, *Payload, error)
+	CreateToken(username, role, kind string, duration time.Duration) (string, *Payload, error)
 
-	Validate(token string) (*Payload, error)
+	Validate(token, kind string) (*Payload, error)
 }
@@ -27,8 +27,8 @@ func NewPasetoMaker(symmetricKey string) (Maker, error) {
 	return maker, nil
 }
 
-func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(username, duration)
+func (maker *PasetoMaker) CreateToken(username, role, kind string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, kind, duration)
 
 	if err != nil {
 		return "", payload, err
@@ -38,7 +38,7 @@ func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (
 	return token, payload, err
 }
 
-func (maker *PasetoMaker) Validate(token string) (*Payload, error) {
+func (maker *PasetoMaker) Validate(token, kind string) (*Payload, error) {
 	payload := &Payload{}
 
 	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
@@ -47,7 +47,7 @@ func (maker *PasetoMaker) Validate(token string) (*Payload, error) {
 		return nil, errors.New("invalid token")
 	}
 
-	err = payload.Valid()
+	err = payload.Valid(kind)
 
 	if err != nil {
 		return nil, err
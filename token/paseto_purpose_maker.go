@@ -0,0 +1,69 @@
+package token
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aead/chacha20poly1305"
+	"github.com/o1egl/paseto"
+	"golang.org/x/crypto/hkdf"
+)
+
+// purposeKeyInfo derives the key of purpose tokens from the symmetric key of
+// the access tokens, so the two kinds never decrypt with each other's key.
+const purposeKeyInfo = "lms purpose tokens"
+
+type PasetoPurposeMaker struct {
+	paseto       *paseto.V2
+	symmetricKey []byte
+}
+
+func NewPasetoPurposeMaker(symmetricKey string) (PurposeMaker, error) {
+	if len(symmetricKey) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("invalid key size %d should be %d", len(symmetricKey), chacha20poly1305.KeySize)
+	}
+
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(symmetricKey), nil, []byte(purposeKeyInfo)), key); err != nil {
+		return nil, err
+	}
+
+	maker := &PasetoPurposeMaker{
+		paseto:       paseto.NewV2(),
+		symmetricKey: key,
+	}
+
+	return maker, nil
+}
+
+func (maker *PasetoPurposeMaker) CreatePurposeToken(subject, purpose string, duration time.Duration) (string, *PurposePayload, error) {
+	payload, err := NewPurposePayload(subject, purpose, duration)
+
+	if err != nil {
+		return "", payload, err
+	}
+
+	token, err := maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+	return token, payload, err
+}
+
+func (maker *PasetoPurposeMaker) ValidatePurpose(token, purpose string) (*PurposePayload, error) {
+	payload := &PurposePayload{}
+
+	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
+
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+
+	err = payload.Valid(purpose)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
@@ -2,19 +2,29 @@ package token
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Tokens come in two kinds, the access tokens sent with every request and the
+// refresh tokens only good for getting new ones.
+const (
+	KindAccess  = "access"
+	KindRefresh = "refresh"
+)
+
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
 	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Kind      string    `json:"kind"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
 
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+func NewPayload(username, role, kind string, duration time.Duration) (*Payload, error) {
 	tokenId, err := uuid.NewRandom()
 
 	if err != nil {
@@ -24,6 +34,8 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	payload := &Payload{
 		ID:        tokenId,
 		Username:  username,
+		Role:      role,
+		Kind:      kind,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
 	}
@@ -31,7 +43,11 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	return payload, nil
 }
 
-func (payload *Payload) Valid() error {
+func (payload *Payload) Valid(kind string) error {
+	if payload.Kind != kind {
+		return fmt.Errorf("%s token required", kind)
+	}
+
 	if time.Now().After(payload.ExpiredAt) {
 		return errors.New("token has expired")
 	}
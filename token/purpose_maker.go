@@ -0,0 +1,61 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+// PurposeMaker makes the tokens mailed to members, each good for a single
+// purpose such as resetting a password. They are never accepted by a Maker,
+// nor its tokens by a PurposeMaker.
+type PurposeMaker interface {
+	CreatePurposeToken(subject, purpose string, duration time.Duration) (string, *PurposePayload, error)
+
+	ValidatePurpose(token, purpose string) (*PurposePayload, error)
+}
+
+// PurposePayload is what a purpose token carries, Subject being the email it
+// was mailed to.
+type PurposePayload struct {
+	ID        uuid.UUID `json:"id"`
+	Subject   string    `json:"subject"`
+	Purpose   string    `json:"purpose"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+func NewPurposePayload(subject, purpose string, duration time.Duration) (*PurposePayload, error) {
+	tokenId, err := uuid.NewRandom()
+
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &PurposePayload{
+		ID:        tokenId,
+		Subject:   subject,
+		Purpose:   purpose,
+		IssuedAt:  time.Now(),
+		ExpiredAt: time.Now().Add(duration),
+	}
+
+	return payload, nil
+}
+
+func (payload *PurposePayload) Valid(purpose string) error {
+	if payload.Purpose != purpose {
+		return errors.New("token is not meant for this")
+	}
+
+	if time.Now().After(payload.ExpiredAt) {
+		return errors.New("token has expired")
+	}
+	return nil
+}
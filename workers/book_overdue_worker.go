@@ -1,17 +1,98 @@
 package workers
 
 import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dutt23/lms/model"
 	service "github.com/dutt23/lms/services"
+	"github.com/hibiken/asynq"
 )
 
+const taskOverdueFines = "task:overdue_fines"
+
 type finesOverdueWorker struct {
-	loanService *service.LoanService
+	loanService   service.LoanService
+	memberService service.MemberService
+	itemService   service.ItemService
+	policyService service.PolicyService
+	fineService   service.FineService
 }
 
-func NewFinesOverdueWorker(loanService *service.LoanService) finesOverdueWorker {
+func NewFinesOverdueWorker(loanService service.LoanService,
+	memberService service.MemberService,
+	itemService service.ItemService,
+	policyService service.PolicyService,
+	fineService service.FineService,
+) finesOverdueWorker {
 	return finesOverdueWorker{
 		loanService,
+		memberService,
+		itemService,
+		policyService,
+		fineService,
+	}
+}
+
+// OverdueFinesWorker scans the open overdue loans and accrues their fines
+// according to the circulation policy of each loan.
+func (worker *finesOverdueWorker) OverdueFinesWorker(ctx context.Context, task *asynq.Task) error {
+	now := time.Now()
+	policies := make(map[string]*model.CirculationPolicy)
+	var lastId uint64
+	var accrued int64
+
+	for {
+		loans, err := worker.loanService.GetOverdueLoans(ctx, now, lastId, 100)
+		if err != nil {
+			return fmt.Errorf("unable to find overdue loans %w", err)
+		}
+
+		if len(loans) == 0 {
+			break
+		}
+
+		for _, loan := range loans {
+			lastId = loan.Id
+			policy, err := worker.loanPolicy(ctx, loan, policies)
+			if err != nil {
+				fmt.Println("unable to resolve policy for loan ", loan.Id, err)
+				continue
+			}
+
+			amount, err := worker.fineService.AccrueOverdueFines(ctx, loan, policy, now)
+			if err != nil {
+				return fmt.Errorf("unable to accrue fines for loan %d %w", loan.Id, err)
+			}
+			accrued += amount
+		}
 	}
+
+	fmt.Println("accrued overdue fines ", accrued)
+	return nil
 }
 
-func (worker *finesOverdueWorker) OverdueFinesWorker() {}
+func (worker *finesOverdueWorker) loanPolicy(ctx context.Context, loan *model.BookLoan, policies map[string]*model.CirculationPolicy) (*model.CirculationPolicy, error) {
+	member, err := worker.memberService.GetMember(ctx, loan.MemberId)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := worker.itemService.GetItem(ctx, loan.BookId, loan.ItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	key := member.MemberType + "/" + item.ItemType
+	if policy, ok := policies[key]; ok {
+		return policy, nil
+	}
+
+	policy, err := worker.policyService.GetPolicy(ctx, member.MemberType, item.ItemType)
+	if err != nil {
+		return nil, err
+	}
+	policies[key] = policy
+	return policy, nil
+}
@@ -8,6 +8,8 @@ import (
 
 type TaskDistributor interface {
 	DistributeBooksAnalyticsPayload(ctx context.Context, payload *BookAnalyticsPayload, opts ...asynq.Option) error
+	DistributeMarcImport(ctx context.Context, payload *MarcImportPayload, opts ...asynq.Option) error
+	DistributeMail(ctx context.Context, payload *MailPayload, opts ...asynq.Option) error
 }
 
 type RedisTaskDistributor struct {
@@ -0,0 +1,53 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	service "github.com/dutt23/lms/services"
+	"github.com/hibiken/asynq"
+)
+
+const taskExpireHoldPickups = "task:expire_hold_pickups"
+
+type holdsExpiryWorker struct {
+	db          connectors.SqliteConnector
+	holdService service.HoldService
+	bookService service.BookService
+}
+
+func NewHoldsExpiryWorker(db connectors.SqliteConnector, holdService service.HoldService, bookService service.BookService) holdsExpiryWorker {
+	return holdsExpiryWorker{
+		db,
+		holdService,
+		bookService,
+	}
+}
+
+// ExpireHoldPickups expires uncollected holds and rolls their copies on to
+// the next member in line.
+func (worker *holdsExpiryWorker) ExpireHoldPickups(ctx context.Context, task *asynq.Task) error {
+	var items []*model.Item
+	err := worker.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		items, err = worker.holdService.ExpirePickups(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to expire hold pickups %w", err)
+		}
+
+		for _, item := range items {
+			if _, err := worker.bookService.RefreshAvailableCopies(ctx, item.BookId); err != nil {
+				return fmt.Errorf("unable to refresh available copies of book %d %w", item.BookId, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("expired hold pickups, released items ", len(items))
+	return nil
+}
@@ -0,0 +1,51 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dutt23/lms/config"
+	"github.com/hibiken/asynq"
+)
+
+const ImportQueue = "imports"
+
+// importTaskProcessor works through uploaded catalogue imports. It listens
+// on ImportQueue only, one import at a time, so long imports never hold up
+// analytics or maintenance tasks.
+type importTaskProcessor struct {
+	server *asynq.Server
+	mux    *asynq.ServeMux
+}
+
+func NewImportTaskProcessor(config *config.AppConfig, marcWorker marcImportWorker) Proccessor {
+	redisOpts := asynq.RedisClientOpt{
+		Addr: "0.0.0.0:6379",
+	}
+	server := asynq.NewServer(redisOpts, asynq.Config{
+		Concurrency: 1,
+		Queues: map[string]int{
+			ImportQueue: 1,
+		},
+		ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
+			fmt.Println("Import task processing has failed with error ", err)
+		}),
+		Logger: NewLogger(),
+	})
+
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(taskImportMarc, marcWorker.ImportMarc)
+
+	return &importTaskProcessor{
+		server,
+		mux,
+	}
+}
+
+func (processor *importTaskProcessor) Process(ctx context.Context, task *asynq.Task) error {
+	return processor.mux.ProcessTask(ctx, task)
+}
+
+func (processor *importTaskProcessor) Start() error {
+	return processor.server.Start(processor.mux)
+}
@@ -0,0 +1,50 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dutt23/lms/config"
+	"github.com/hibiken/asynq"
+)
+
+const MailQueue = "mail"
+
+// mailTaskProcessor sends the mails to members. It listens on MailQueue only,
+// so a slow or unreachable SMTP server never holds up the other tasks.
+type mailTaskProcessor struct {
+	server *asynq.Server
+	mux    *asynq.ServeMux
+}
+
+func NewMailTaskProcessor(config *config.AppConfig, mailWorker mailWorker) Proccessor {
+	redisOpts := asynq.RedisClientOpt{
+		Addr: "0.0.0.0:6379",
+	}
+	server := asynq.NewServer(redisOpts, asynq.Config{
+		Concurrency: 2,
+		Queues: map[string]int{
+			MailQueue: 1,
+		},
+		ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
+			fmt.Println("Mail task processing has failed with error ", err)
+		}),
+		Logger: NewLogger(),
+	})
+
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(taskSendMail, mailWorker.SendMail)
+
+	return &mailTaskProcessor{
+		server,
+		mux,
+	}
+}
+
+func (processor *mailTaskProcessor) Process(ctx context.Context, task *asynq.Task) error {
+	return processor.mux.ProcessTask(ctx, task)
+}
+
+func (processor *mailTaskProcessor) Start() error {
+	return processor.server.Start(processor.mux)
+}
@@ -0,0 +1,53 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dutt23/lms/pkg/mail"
+	"github.com/hibiken/asynq"
+)
+
+const taskSendMail = "task:send_mail"
+
+type MailPayload struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+func (distributor RedisTaskDistributor) DistributeMail(ctx context.Context, payload *MailPayload, opts ...asynq.Option) error {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload for mail")
+	}
+
+	task := asynq.NewTask(taskSendMail, jsonPayload, opts...)
+	if _, err := distributor.client.EnqueueContext(ctx, task); err != nil {
+		return fmt.Errorf("failed to enqueue mail task %w", err)
+	}
+	return nil
+}
+
+type mailWorker struct {
+	sender mail.Sender
+}
+
+func NewMailWorker(sender mail.Sender) mailWorker {
+	return mailWorker{sender}
+}
+
+// SendMail hands a mail to the SMTP server, retried while the server is down.
+func (worker *mailWorker) SendMail(ctx context.Context, task *asynq.Task) error {
+	var payload MailPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("unable to un-marshal json for task %w", asynq.SkipRetry)
+	}
+
+	msg := &mail.Message{To: payload.To, Subject: payload.Subject, Body: payload.Body}
+	if err := worker.sender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("unable to send mail to %s %w", payload.To, err)
+	}
+	return nil
+}
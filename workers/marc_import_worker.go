@@ -0,0 +1,203 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dutt23/lms/model"
+	"github.com/dutt23/lms/pkg/connectors"
+	"github.com/dutt23/lms/pkg/marc"
+	service "github.com/dutt23/lms/services"
+	"github.com/hibiken/asynq"
+)
+
+const taskImportMarc = "task:import_marc"
+
+type MarcImportPayload struct {
+	ImportId uint64 `json:"import_id"`
+}
+
+func (distributor RedisTaskDistributor) DistributeMarcImport(ctx context.Context, payload *MarcImportPayload, opts ...asynq.Option) error {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload for marc import")
+	}
+
+	task := asynq.NewTask(taskImportMarc, jsonPayload, opts...)
+	if _, err := distributor.client.EnqueueContext(ctx, task); err != nil {
+		return fmt.Errorf("failed to enqueue marc import task %w", err)
+	}
+	return nil
+}
+
+type marcImportWorker struct {
+	db            connectors.SqliteConnector
+	importService service.ImportService
+	bookService   service.BookService
+	itemService   service.ItemService
+}
+
+func NewMarcImportWorker(db connectors.SqliteConnector,
+	importService service.ImportService,
+	bookService service.BookService,
+	itemService service.ItemService,
+) marcImportWorker {
+	return marcImportWorker{
+		db,
+		importService,
+		bookService,
+		itemService,
+	}
+}
+
+// ImportMarc catalogues every record of an uploaded MARC file, recording the
+// outcome per record. A retried task resumes after the last recorded record.
+func (worker *marcImportWorker) ImportMarc(ctx context.Context, task *asynq.Task) error {
+	var payload MarcImportPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("unable to un-marshal json for task %w", asynq.SkipRetry)
+	}
+
+	imp, err := worker.importService.GetImport(ctx, payload.ImportId)
+	if err != nil {
+		return fmt.Errorf("unable to find import %d %w", payload.ImportId, err)
+	}
+
+	if imp.Status == model.ImportStatusCompleted || imp.Status == model.ImportStatusFailed {
+		return nil
+	}
+
+	imp.Status = model.ImportStatusRunning
+	if err := worker.importService.UpdateImport(ctx, imp); err != nil {
+		return err
+	}
+
+	if err := worker.importRecords(ctx, imp); err != nil {
+		var fatal *importError
+		if !errors.As(err, &fatal) {
+			return err
+		}
+
+		imp.Status = model.ImportStatusFailed
+		imp.Error = fatal.Error()
+	} else {
+		imp.Status = model.ImportStatusCompleted
+	}
+
+	t := time.Now()
+	imp.FinishedAt = &t
+	if err := worker.importService.UpdateImport(ctx, imp); err != nil {
+		return err
+	}
+
+	os.Remove(imp.Path)
+	fmt.Println("finished marc import ", imp.Id, imp.Status, imp.Succeeded, imp.Failed)
+	return nil
+}
+
+// importError ends an import for good, retrying would not get any further.
+type importError struct {
+	err error
+}
+
+func (err *importError) Error() string {
+	return err.err.Error()
+}
+
+func (worker *marcImportWorker) importRecords(ctx context.Context, imp *model.Import) error {
+	file, err := os.Open(imp.Path)
+	if err != nil {
+		return &importError{fmt.Errorf("unable to open uploaded file %w", err)}
+	}
+	defer file.Close()
+
+	reader, err := marc.NewRecordReader(file)
+	if err != nil {
+		return &importError{err}
+	}
+
+	done, err := worker.importService.LastImportedPosition(ctx, imp.Id)
+	if err != nil {
+		return err
+	}
+
+	for position := int64(1); ; position++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var recordErr *marc.RecordError
+		if err != nil && !errors.As(err, &recordErr) {
+			return &importError{fmt.Errorf("unable to read record %d %w", position, err)}
+		}
+
+		if position <= done {
+			continue
+		}
+
+		result := &model.ImportRecord{Position: position}
+		if err != nil {
+			result.Status = model.ImportRecordFailed
+			result.Error = err.Error()
+		} else {
+			result.ControlNumber = record.ControlField("001")
+			worker.importRecord(ctx, imp, record, result)
+		}
+
+		if result.Status == model.ImportRecordFailed {
+			if err := worker.importService.AddImportRecord(ctx, imp, result); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// importRecord catalogues one record together with its copies and its
+// outcome. Failures are recorded on result for the caller to store.
+func (worker *marcImportWorker) importRecord(ctx context.Context, imp *model.Import, record *marc.Record, result *model.ImportRecord) {
+	book, err := marc.ToBook(record)
+	if err != nil {
+		result.Status = model.ImportRecordFailed
+		result.Error = err.Error()
+		return
+	}
+
+	result.Isbn = book.Isbn
+	result.Title = book.Title
+	if existing, err := worker.bookService.GetBookByIsbn(ctx, book.Isbn); err == nil && existing != nil {
+		result.Status = model.ImportRecordFailed
+		result.Error = fmt.Sprintf("book with isbn %s already exists as %d", book.Isbn, existing.Id)
+		return
+	}
+
+	book.AvailableCopies = imp.Copies
+	err = worker.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := worker.bookService.AddBook(ctx, book); err != nil {
+			return err
+		}
+
+		if _, err := worker.itemService.AddItems(ctx, book, imp.Copies); err != nil {
+			return err
+		}
+
+		result.Status = model.ImportRecordSucceeded
+		result.BookId = &book.Id
+		return worker.importService.AddImportRecord(ctx, imp, result)
+	})
+	if err != nil {
+		*result = model.ImportRecord{
+			Position:      result.Position,
+			ControlNumber: result.ControlNumber,
+			Status:        model.ImportRecordFailed,
+			Isbn:          result.Isbn,
+			Title:         result.Title,
+			Error:         err.Error(),
+		}
+	}
+}
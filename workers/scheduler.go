@@ -0,0 +1,67 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dutt23/lms/config"
+	"github.com/hibiken/asynq"
+)
+
+const MaintenanceQueue = "maintenance"
+
+// maintenanceTaskProcessor runs the periodic housekeeping tasks. It owns the
+// scheduler enqueuing them and a server listening on MaintenanceQueue only,
+// so the tasks never reach the analytics processor.
+type maintenanceTaskProcessor struct {
+	server    *asynq.Server
+	scheduler *asynq.Scheduler
+	mux       *asynq.ServeMux
+}
+
+func NewMaintenanceTaskProcessor(config *config.AppConfig, holdsWorker holdsExpiryWorker, finesWorker finesOverdueWorker) Proccessor {
+	redisOpts := asynq.RedisClientOpt{
+		Addr: "0.0.0.0:6379",
+	}
+	server := asynq.NewServer(redisOpts, asynq.Config{
+		Queues: map[string]int{
+			MaintenanceQueue: 1,
+		},
+		ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
+			fmt.Println("Maintenance task processing has failed with error ", err)
+		}),
+		Logger: NewLogger(),
+	})
+	scheduler := asynq.NewScheduler(redisOpts, &asynq.SchedulerOpts{
+		Logger: NewLogger(),
+	})
+
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(taskExpireHoldPickups, holdsWorker.ExpireHoldPickups)
+	mux.HandleFunc(taskOverdueFines, finesWorker.OverdueFinesWorker)
+
+	return &maintenanceTaskProcessor{
+		server,
+		scheduler,
+		mux,
+	}
+}
+
+func (processor *maintenanceTaskProcessor) Process(ctx context.Context, task *asynq.Task) error {
+	return processor.mux.ProcessTask(ctx, task)
+}
+
+func (processor *maintenanceTaskProcessor) Start() error {
+	if _, err := processor.scheduler.Register("@every 15m", asynq.NewTask(taskExpireHoldPickups, nil, asynq.Queue(MaintenanceQueue))); err != nil {
+		return fmt.Errorf("unable to schedule hold expiry task %w", err)
+	}
+
+	if _, err := processor.scheduler.Register("@daily", asynq.NewTask(taskOverdueFines, nil, asynq.Queue(MaintenanceQueue))); err != nil {
+		return fmt.Errorf("unable to schedule overdue fines task %w", err)
+	}
+
+	if err := processor.scheduler.Start(); err != nil {
+		return err
+	}
+	return processor.server.Start(processor.mux)
+}